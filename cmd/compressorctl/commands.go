@@ -0,0 +1,664 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/infrastructure/datamigration"
+	"github.com/AFK068/compressor/internal/infrastructure/linkio"
+	"github.com/AFK068/compressor/internal/infrastructure/repository/inmemoryrepo"
+	"github.com/AFK068/compressor/internal/migration/migratecmd"
+	"github.com/AFK068/compressor/pkg/shortener"
+	"github.com/AFK068/compressor/pkg/storage"
+)
+
+const (
+	DefaultListLimit = 100
+
+	// ImportFormatLines is a plain list of URLs, each getting a new code.
+	ImportFormatLines = "lines"
+
+	// SnapshotLatest restores the newest snapshot.
+	SnapshotLatest = "latest"
+
+	DefaultMigrationCheckpoint = "migrate-data.checkpoint"
+	DefaultVerificationSample  = 1000
+)
+
+func newFlagSet(app *App, name, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(app.Err)
+	flags.Usage = func() {
+		fmt.Fprintf(app.Err, "Usage: compressorctl %s %s\n", name, usage)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// parseArgs parses flags and requires exactly want positional arguments.
+func parseArgs(flags *flag.FlagSet, args []string, want int) error {
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if flags.NArg() != want {
+		flags.Usage()
+		return errUsage
+	}
+
+	return nil
+}
+
+func runCreate(ctx context.Context, app *App, args []string) error {
+	flags := newFlagSet(app, "create", "[flags] <url>")
+	owner := flags.String("owner", "", "owner of the link")
+	expiresIn := flags.Duration("expires-in", 0, "expire the link after this duration, e.g. 720h")
+
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+
+	if err := validateDestination(flags.Arg(0)); err != nil {
+		return err
+	}
+
+	link := &domain.Link{Destination: flags.Arg(0), Owner: *owner}
+
+	if *expiresIn > 0 {
+		expiresAt := time.Now().Add(*expiresIn)
+		link.ExpiresAt = &expiresAt
+	}
+
+	repo, err := app.Repository(ctx)
+	if err != nil {
+		return err
+	}
+
+	created, err := repo.CreateLink(ctx, link)
+	if err != nil {
+		return err
+	}
+
+	return app.PrintLinks(created)
+}
+
+func runResolve(ctx context.Context, app *App, args []string) error {
+	flags := newFlagSet(app, "resolve", "<code>")
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+
+	repo, err := app.Repository(ctx)
+	if err != nil {
+		return err
+	}
+
+	link, err := repo.GetLink(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	return app.PrintLinks(link)
+}
+
+func runList(ctx context.Context, app *App, args []string) error {
+	flags := newFlagSet(app, "list", "[flags]")
+	fromID := flags.Uint64("from-id", 0, "smallest link id to list")
+	limit := flags.Int("limit", DefaultListLimit, "maximum number of links to list, 0 lists all links")
+
+	if err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	repo, err := app.Repository(ctx)
+	if err != nil {
+		return err
+	}
+
+	var links []*domain.Link
+
+	params := domain.ListLinksParams{FromID: *fromID, Limit: DefaultListLimit}
+
+	for *limit == 0 || len(links) < *limit {
+		if *limit > 0 {
+			params.Limit = min(DefaultListLimit, *limit-len(links))
+		}
+
+		page, err := repo.ListLinks(ctx, params)
+		if err != nil {
+			return err
+		}
+
+		links = append(links, page...)
+
+		if len(page) < params.Limit {
+			break
+		}
+
+		params.FromID = page[len(page)-1].ID + 1
+	}
+
+	return app.PrintLinks(links...)
+}
+
+func runDisable(ctx context.Context, app *App, args []string) error {
+	flags := newFlagSet(app, "disable", "<code>")
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+
+	repo, err := app.Repository(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := repo.DisableLink(ctx, flags.Arg(0)); err != nil {
+		return err
+	}
+
+	return app.PrintMessage("disabled " + flags.Arg(0))
+}
+
+func runDelete(ctx context.Context, app *App, args []string) error {
+	flags := newFlagSet(app, "delete", "<code>")
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+
+	repo, err := app.Repository(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := repo.DeleteLink(ctx, flags.Arg(0)); err != nil {
+		return err
+	}
+
+	return app.PrintMessage("deleted " + flags.Arg(0))
+}
+
+func runImport(ctx context.Context, app *App, args []string) error {
+	flags := newFlagSet(app, "import", "[flags]")
+	file := flags.String("file", "-", "file to import, - reads stdin")
+	format := flags.String("format", ImportFormatLines, "lines (one URL per line), csv or jsonl; csv and jsonl keep codes")
+	owner := flags.String("owner", "", "owner of the created links, only used by the lines format")
+
+	if err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	var linkFormat linkio.Format
+
+	if *format != ImportFormatLines {
+		var err error
+		if linkFormat, err = linkio.ParseFormat(*format); err != nil {
+			return err
+		}
+	}
+
+	input, closeInput, err := openInput(*file)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	repo, err := app.Repository(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *format == ImportFormatLines {
+		return importLines(ctx, app, repo, input, *owner)
+	}
+
+	report, err := linkio.Import(ctx, repo, linkio.NewReader(input, linkFormat), func(rowErr *linkio.RowError) {
+		fmt.Fprintln(app.Err, rowErr)
+	})
+	if err != nil {
+		return fmt.Errorf("import stopped after %d links: %w", report.Imported, err)
+	}
+
+	if err := app.PrintMessage(fmt.Sprintf("imported %d links, skipped %d", report.Imported, report.Failed)); err != nil {
+		return err
+	}
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d links failed to import", report.Failed, report.Failed+report.Imported)
+	}
+
+	return nil
+}
+
+func importLines(ctx context.Context, app *App, repo domain.Repository, input io.Reader, owner string) error {
+	var (
+		links  []*domain.Link
+		failed int
+	)
+
+	scanner := bufio.NewScanner(input)
+	for line := 1; scanner.Scan(); line++ {
+		destination := strings.TrimSpace(scanner.Text())
+		if destination == "" || strings.HasPrefix(destination, "#") {
+			continue
+		}
+
+		if err := validateDestination(destination); err != nil {
+			fmt.Fprintf(app.Err, "line %d: %v\n", line, err)
+			failed++
+
+			continue
+		}
+
+		link, err := repo.CreateLink(ctx, &domain.Link{Destination: destination, Owner: owner})
+		if err != nil {
+			fmt.Fprintf(app.Err, "line %d: %v\n", line, err)
+			failed++
+
+			continue
+		}
+
+		links = append(links, link)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if err := app.PrintLinks(links...); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d links failed to import", failed, failed+len(links))
+	}
+
+	return nil
+}
+
+func runExport(ctx context.Context, app *App, args []string) error {
+	flags := newFlagSet(app, "export", "[flags]")
+	file := flags.String("file", "-", "file to write, - writes stdout")
+	format := flags.String("format", string(linkio.FormatCSV), "csv or jsonl")
+
+	if err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	linkFormat, err := linkio.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	repo, err := app.Repository(ctx)
+	if err != nil {
+		return err
+	}
+
+	output, closeOutput, err := openOutput(app, *file)
+	if err != nil {
+		return err
+	}
+
+	exported, err := linkio.Export(ctx, repo, linkio.NewWriter(output, linkFormat))
+	if err := errors.Join(err, closeOutput()); err != nil {
+		return fmt.Errorf("export stopped after %d links: %w", exported, err)
+	}
+
+	if *file != "-" {
+		return app.PrintMessage(fmt.Sprintf("exported %d links", exported))
+	}
+
+	return nil
+}
+
+func runStats(ctx context.Context, app *App, args []string) error {
+	flags := newFlagSet(app, "stats", "")
+	if err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	repo, err := app.Repository(ctx)
+	if err != nil {
+		return err
+	}
+
+	stats, err := repo.Stats(ctx)
+	if err != nil {
+		return err
+	}
+
+	return app.PrintStats(stats)
+}
+
+func runMigrate(_ context.Context, app *App, args []string) error {
+	command := &migratecmd.Command{Program: "compressorctl", Err: app.Err, Printer: app, Open: app.Migrator}
+
+	err := command.Run(args)
+	if errors.Is(err, migratecmd.ErrUsage) {
+		return errUsage
+	}
+
+	return err
+}
+
+func runSnapshot(ctx context.Context, app *App, args []string) error {
+	flags := newFlagSet(app, "snapshot", "(create | list | restore (ID | latest))")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	snapshot, ok := snapshotActions[flags.Arg(0)]
+	if !ok {
+		flags.Usage()
+		return errUsage
+	}
+
+	return snapshot(ctx, app, flags.Args()[1:])
+}
+
+type snapshotAction func(ctx context.Context, app *App, args []string) error
+
+var snapshotActions = map[string]snapshotAction{
+	"create":  snapshotCreate,
+	"list":    snapshotList,
+	"restore": snapshotRestore,
+}
+
+func snapshotCreate(ctx context.Context, app *App, args []string) error {
+	flags := newFlagSet(app, "snapshot create", "")
+	if err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	store, err := app.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	repo, err := app.Repository(ctx)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := store.Create(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	return app.PrintSnapshots(manifest)
+}
+
+func snapshotList(ctx context.Context, app *App, args []string) error {
+	flags := newFlagSet(app, "snapshot list", "")
+	if err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	store, err := app.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	manifests, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	return app.PrintSnapshots(manifests...)
+}
+
+func snapshotRestore(ctx context.Context, app *App, args []string) error {
+	flags := newFlagSet(app, "snapshot restore", "(ID | latest)")
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+
+	store, err := app.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	id := flags.Arg(0)
+
+	if id == SnapshotLatest {
+		manifests, err := store.List(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(manifests) == 0 {
+			return errors.New("no snapshots to restore")
+		}
+
+		id = manifests[len(manifests)-1].ID
+	}
+
+	repo, err := app.Repository(ctx)
+	if err != nil {
+		return err
+	}
+
+	report, err := store.Restore(ctx, id, repo, func(rowErr *linkio.RowError) {
+		fmt.Fprintln(app.Err, rowErr)
+	})
+	if err != nil {
+		return fmt.Errorf("restore stopped after %d links: %w", report.Imported, err)
+	}
+
+	message := fmt.Sprintf("restored %d links from snapshot %s, skipped %d", report.Imported, id, report.Failed)
+	if err := app.PrintMessage(message); err != nil {
+		return err
+	}
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d links failed to restore", report.Failed, report.Failed+report.Imported)
+	}
+
+	return nil
+}
+
+// runMigrateData copies the links of the configured storage, or of the export given by --from-file,
+// into the storage configured by --to.
+func runMigrateData(ctx context.Context, app *App, args []string) error {
+	flags := newFlagSet(app, "migrate-data", "--to <config> [flags]")
+	to := flags.String("to", "", "configuration file of the destination storage")
+	fromFile := flags.String("from-file", "", "export file to migrate instead of the configured storage, e.g. of an inmemory server")
+	format := flags.String("format", string(linkio.FormatCSV), "format of --from-file: csv or jsonl")
+	checkpoint := flags.String("checkpoint", DefaultMigrationCheckpoint, "file keeping the progress, delete it to start over")
+	pageSize := flags.Int("page-size", datamigration.DefaultPageSize, "number of links read at once")
+	sample := flags.Int("sample", DefaultVerificationSample, "number of random links looked up in the destination, 0 skips the lookups")
+	verifyOnly := flags.Bool("verify-only", false, "only compare the storages")
+
+	if err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	if *to == "" || *pageSize <= 0 || *sample < 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	// The environment configures the source, so the destination is only read from its file.
+	destinationConfig, err := config.NewFileConfig(*to)
+	if err != nil {
+		return fmt.Errorf("reading destination config: %w", err)
+	}
+
+	if err := checkMigration(app.Config, destinationConfig, *fromFile != ""); err != nil {
+		return err
+	}
+
+	var source domain.Repository
+
+	if *fromFile != "" {
+		linkFormat, err := linkio.ParseFormat(*format)
+		if err != nil {
+			return err
+		}
+
+		if source, err = loadExport(ctx, app, *fromFile, linkFormat); err != nil {
+			return err
+		}
+	} else if source, err = app.Repository(ctx); err != nil {
+		return err
+	}
+
+	destination, closeDestination, err := openDestination(ctx, app, destinationConfig)
+	if err != nil {
+		return err
+	}
+	defer closeDestination()
+
+	migrator := datamigration.New(source, destination,
+		datamigration.WithPageSize(*pageSize), datamigration.WithCheckpointFile(*checkpoint))
+
+	if !*verifyOnly {
+		progress, err := migrator.Run(ctx, func(linkErr *datamigration.LinkError) {
+			fmt.Fprintln(app.Err, linkErr)
+		})
+		if err != nil {
+			return fmt.Errorf("migration stopped before link %d, run it again to resume: %w", progress.NextID, err)
+		}
+
+		if err := app.PrintMigration(progress); err != nil {
+			return err
+		}
+
+		if progress.Failed > 0 {
+			return fmt.Errorf("%d links failed to migrate", progress.Failed)
+		}
+	}
+
+	report, err := migrator.Verify(ctx, *sample)
+	if err != nil {
+		return fmt.Errorf("verifying: %w", err)
+	}
+
+	for _, mismatch := range report.Mismatches {
+		fmt.Fprintln(app.Err, mismatch)
+	}
+
+	if err := app.PrintVerification(report); err != nil {
+		return err
+	}
+
+	if !report.OK() {
+		return errors.New("verification failed")
+	}
+
+	return nil
+}
+
+// checkMigration refuses migrations that cannot keep the codes or whose source or destination only
+// lives as long as the command. fromFile tells that the links are read from an export instead of the
+// configured storage.
+func checkMigration(source, destination *config.Config, fromFile bool) error {
+	switch {
+	case source.Storage.Type == domain.InMemoryRepository && !fromFile:
+		return errors.New("the links of the inmemory storage only live in the server process, " +
+			"export them from the server and pass the file with --from-file")
+	case destination.Storage.Type == domain.InMemoryRepository:
+		return errors.New("the destination storage is inmemory, the links would be lost when the command exits")
+	case !fromFile && config.SameStorage(source, destination):
+		return errors.New("the source and the destination are the same storage")
+	case source.Shortener.Alphabet != destination.Shortener.Alphabet || source.Shortener.Length != destination.Shortener.Length:
+		return errors.New("the destination has another alphabet or length, the codes would change")
+	default:
+		return nil
+	}
+}
+
+// loadExport reads the links of an export into an inmemory repository, so that they can be migrated
+// like the links of any storage. Rows that cannot be loaded are reported and fail the migration.
+func loadExport(ctx context.Context, app *App, path string, format linkio.Format) (domain.Repository, error) {
+	s, err := shortener.NewShortener(app.Config.Shortener.Alphabet, app.Config.Shortener.Length)
+	if err != nil {
+		return nil, err
+	}
+
+	input, closeInput, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeInput()
+
+	repo := inmemoryrepo.New(s, app.Config.Storage.MaxSize)
+
+	report, err := linkio.Import(ctx, repo, linkio.NewReader(input, format), func(rowErr *linkio.RowError) {
+		fmt.Fprintln(app.Err, rowErr)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	if report.Failed > 0 {
+		return nil, fmt.Errorf("%d of %d links of %s could not be read", report.Failed, report.Failed+report.Imported, path)
+	}
+
+	return repo, nil
+}
+
+func openDestination(ctx context.Context, app *App, cfg *config.Config) (storage.Backend, func(), error) {
+	s, err := shortener.NewShortener(cfg.Shortener.Alphabet, cfg.Shortener.Length)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	destination, closeDestination, err := storage.Open(ctx, cfg, s, app.Logger)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening destination: %w", err)
+	}
+
+	return destination, closeDestination, nil
+}
+
+func openInput(path string) (io.Reader, func(), error) {
+	if path == "-" {
+		return os.Stdin, func() {}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, func() { _ = f.Close() }, nil
+}
+
+func openOutput(app *App, path string) (io.Writer, func() error, error) {
+	if path == "-" {
+		return app.Out, func() error { return nil }, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, f.Close, nil
+}
+
+func validateDestination(destination string) error {
+	u, err := url.ParseRequestURI(destination)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("destination %q is not an absolute URL", destination)
+	}
+
+	return nil
+}
@@ -0,0 +1,7 @@
+package main
+
+// The storage drivers built into the binary. Import the package of another driver here to make it
+// available as a storage type.
+import (
+	_ "github.com/AFK068/compressor/internal/infrastructure/repository"
+)
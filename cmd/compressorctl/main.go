@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/infrastructure/snapshot"
+	"github.com/AFK068/compressor/internal/migration"
+	"github.com/AFK068/compressor/internal/migration/migratecmd"
+	"github.com/AFK068/compressor/pkg/logger"
+	"github.com/AFK068/compressor/pkg/shortener"
+	"github.com/AFK068/compressor/pkg/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultConfigPath = "config/dev.yaml"
+
+	usage = `Usage: compressorctl [flags] <command> [command flags] [args]
+
+Commands:
+  create <url>     create a short link
+  resolve <code>   show the link behind a code
+  list             list links
+  disable <code>   stop a link from resolving
+  delete <code>    delete a link
+  import           import links from a URL list, CSV or JSON Lines file
+  export           export all links as CSV or JSON Lines
+  stats            print link statistics
+  migrate          apply, roll back or inspect database changesets:
+                     migrate up [--dry-run] [N]   apply the next N (default all) pending changesets
+                     migrate down (N | --all)     roll back the last N changesets
+                     migrate goto V               migrate up or down to version V
+                     migrate version              print the applied version
+                     migrate force V              set the version without migrating, -1 for none
+  migrate-data     copy all links, keeping their ids and codes, into the storage configured by --to
+                   and verify them, resuming from --checkpoint when interrupted; --from-file reads
+                   the links of an export instead of the configured storage
+  snapshot         back up links to or restore them from the snapshot bucket:
+                     snapshot create              export all links into a new snapshot
+                     snapshot list                list the complete snapshots, the oldest first
+                     snapshot restore (ID | latest)
+                                                  import the links of a snapshot, keeping their codes
+
+Flags:
+`
+)
+
+var errUsage = errors.New("usage")
+
+// App holds what every command needs: the configuration, the opened repository and the output.
+type App struct {
+	Config *config.Config
+	Out    io.Writer
+	Err    io.Writer
+	Format OutputFormat
+	Logger *zap.Logger
+
+	repository storage.Backend
+	close      func()
+	migrator   *migration.Migrator
+	snapshots  *snapshot.Store
+}
+
+type command func(ctx context.Context, app *App, args []string) error
+
+var commands = map[string]command{
+	"create":       runCreate,
+	"resolve":      runResolve,
+	"list":         runList,
+	"disable":      runDisable,
+	"delete":       runDelete,
+	"import":       runImport,
+	"export":       runExport,
+	"stats":        runStats,
+	"migrate":      runMigrate,
+	"migrate-data": runMigrateData,
+	"snapshot":     runSnapshot,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("compressorctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	configPath := flags.String("config", DefaultConfigPath, "path to the configuration file")
+	output := flags.String("output", string(OutputTable), "output format: table or json")
+	verbose := flags.Bool("verbose", false, "log to stderr")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	format, err := ParseOutputFormat(*output)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := config.NewConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "reading config: %v\n", err)
+		return 1
+	}
+
+	log := zap.NewNop()
+	if *verbose {
+		log = logger.New()
+	}
+
+	app := &App{Config: cfg, Out: stdout, Err: stderr, Format: format, Logger: log}
+	defer app.Close()
+
+	if err := cmd(ctx, app, flags.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+
+		fmt.Fprintf(stderr, "%s: %v\n", flags.Arg(0), err)
+
+		return 1
+	}
+
+	return 0
+}
+
+// Repository opens the configured repository on first use.
+func (a *App) Repository(ctx context.Context) (storage.Backend, error) {
+	if a.repository != nil {
+		return a.repository, nil
+	}
+
+	if a.Config.Storage.Type == domain.InMemoryRepository {
+		fmt.Fprintln(a.Err, "warning: storage type is inmemory, changes are lost when the command exits")
+	}
+
+	s, err := shortener.NewShortener(a.Config.Shortener.Alphabet, a.Config.Shortener.Length)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, closeRepo, err := storage.Open(ctx, a.Config, s, a.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	a.repository, a.close = repo, closeRepo
+
+	return repo, nil
+}
+
+// Migrator opens the migrator of the configured database on first use.
+func (a *App) Migrator() (*migration.Migrator, error) {
+	if a.migrator != nil {
+		return a.migrator, nil
+	}
+
+	migrator, err := migratecmd.Open(a.Config, a.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	a.migrator = migrator
+
+	return migrator, nil
+}
+
+// Snapshots connects to the configured snapshot bucket on first use.
+func (a *App) Snapshots() (*snapshot.Store, error) {
+	if a.snapshots != nil {
+		return a.snapshots, nil
+	}
+
+	if a.Config.Snapshots.Bucket == "" {
+		return nil, errors.New("no snapshot bucket configured, set snapshots.bucket")
+	}
+
+	store, err := snapshot.New(a.Config.Snapshots)
+	if err != nil {
+		return nil, err
+	}
+
+	a.snapshots = store
+
+	return store, nil
+}
+
+func (a *App) Close() {
+	if a.close != nil {
+		a.close()
+	}
+
+	if a.migrator != nil {
+		a.migrator.Close()
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/infrastructure/datamigration"
+	"github.com/AFK068/compressor/internal/infrastructure/snapshot"
+	"github.com/AFK068/compressor/internal/migration"
+	"github.com/AFK068/compressor/internal/migration/migratecmd"
+)
+
+type OutputFormat string
+
+const (
+	OutputTable OutputFormat = "table"
+	OutputJSON  OutputFormat = "json"
+)
+
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	switch format := OutputFormat(s); format {
+	case OutputTable, OutputJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown output format %q, expected table or json", s)
+	}
+}
+
+type migrationView struct {
+	NextID   uint64 `json:"next_id"`
+	Migrated int    `json:"migrated"`
+	Present  int    `json:"present"`
+	Failed   int    `json:"failed"`
+}
+
+type verificationView struct {
+	Source      statsView `json:"source"`
+	Destination statsView `json:"destination"`
+	Sampled     int       `json:"sampled"`
+	Mismatches  []string  `json:"mismatches"`
+	OK          bool      `json:"ok"`
+}
+
+type linkView struct {
+	ID          uint64     `json:"id"`
+	Code        string     `json:"code"`
+	Destination string     `json:"destination"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Owner       string     `json:"owner,omitempty"`
+	Disabled    bool       `json:"disabled"`
+}
+
+type statsView struct {
+	Total    uint64 `json:"total"`
+	Active   uint64 `json:"active"`
+	Disabled uint64 `json:"disabled"`
+	Expired  uint64 `json:"expired"`
+	Capacity uint64 `json:"capacity"`
+}
+
+type changesetView struct {
+	Version    uint   `json:"version"`
+	Identifier string `json:"changeset"`
+}
+
+type migrationVersionView struct {
+	Version *uint `json:"version"`
+	Dirty   bool  `json:"dirty"`
+}
+
+func (a *App) PrintLinks(links ...*domain.Link) error {
+	views := make([]linkView, 0, len(links))
+	for _, link := range links {
+		views = append(views, linkView(*link))
+	}
+
+	if a.Format == OutputJSON {
+		return a.printJSON(views)
+	}
+
+	w := tabwriter.NewWriter(a.Out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCODE\tDESTINATION\tCREATED\tEXPIRES\tOWNER\tDISABLED")
+
+	for _, view := range views {
+		expires := "-"
+		if view.ExpiresAt != nil {
+			expires = view.ExpiresAt.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%t\n",
+			view.ID, view.Code, view.Destination, view.CreatedAt.Format(time.RFC3339), expires, view.Owner, view.Disabled)
+	}
+
+	return w.Flush()
+}
+
+func (a *App) PrintStats(stats *domain.Stats) error {
+	view := statsView(*stats)
+
+	if a.Format == OutputJSON {
+		return a.printJSON(view)
+	}
+
+	w := tabwriter.NewWriter(a.Out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "total\t%d\n", view.Total)
+	fmt.Fprintf(w, "active\t%d\n", view.Active)
+	fmt.Fprintf(w, "disabled\t%d\n", view.Disabled)
+	fmt.Fprintf(w, "expired\t%d\n", view.Expired)
+	fmt.Fprintf(w, "capacity\t%d\n", view.Capacity)
+
+	return w.Flush()
+}
+
+func (a *App) PrintChangesets(changesets []migration.Changeset) error {
+	if a.Format != OutputJSON {
+		return (&migratecmd.TextPrinter{Out: a.Out}).PrintChangesets(changesets)
+	}
+
+	views := make([]changesetView, 0, len(changesets))
+	for _, changeset := range changesets {
+		views = append(views, changesetView(changeset))
+	}
+
+	return a.printJSON(views)
+}
+
+// PrintMigrationVersion prints the applied version, nil when no changeset has been applied.
+func (a *App) PrintMigrationVersion(version *uint, dirty bool) error {
+	if a.Format != OutputJSON {
+		return (&migratecmd.TextPrinter{Out: a.Out}).PrintMigrationVersion(version, dirty)
+	}
+
+	return a.printJSON(migrationVersionView{Version: version, Dirty: dirty})
+}
+
+func (a *App) PrintMigration(checkpoint *datamigration.Checkpoint) error {
+	view := migrationView{
+		NextID:   checkpoint.NextID,
+		Migrated: checkpoint.Migrated,
+		Present:  checkpoint.Present,
+		Failed:   checkpoint.Failed,
+	}
+
+	if a.Format == OutputJSON {
+		return a.printJSON(view)
+	}
+
+	return a.PrintMessage(fmt.Sprintf("migrated %d links, %d already present, skipped %d",
+		view.Migrated, view.Present, view.Failed))
+}
+
+func (a *App) PrintVerification(report *datamigration.VerifyReport) error {
+	view := verificationView{
+		Source:      statsView(*report.Source),
+		Destination: statsView(*report.Destination),
+		Sampled:     report.Sampled,
+		Mismatches:  make([]string, 0, len(report.Mismatches)),
+		OK:          report.OK(),
+	}
+
+	for _, mismatch := range report.Mismatches {
+		view.Mismatches = append(view.Mismatches, mismatch.Code)
+	}
+
+	if a.Format == OutputJSON {
+		return a.printJSON(view)
+	}
+
+	w := tabwriter.NewWriter(a.Out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tSOURCE\tDESTINATION")
+	fmt.Fprintf(w, "total\t%d\t%d\n", view.Source.Total, view.Destination.Total)
+	fmt.Fprintf(w, "disabled\t%d\t%d\n", view.Source.Disabled, view.Destination.Disabled)
+	fmt.Fprintf(w, "sampled\t%d\t%d mismatched\n", view.Sampled, len(view.Mismatches))
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if view.OK {
+		return a.PrintMessage("verified")
+	}
+
+	return a.PrintMessage("verification failed")
+}
+
+func (a *App) PrintSnapshots(manifests ...*snapshot.Manifest) error {
+	if a.Format == OutputJSON {
+		if manifests == nil {
+			manifests = []*snapshot.Manifest{}
+		}
+
+		return a.printJSON(manifests)
+	}
+
+	if len(manifests) == 0 {
+		return a.PrintMessage("no snapshots")
+	}
+
+	w := tabwriter.NewWriter(a.Out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCREATED\tLINKS\tSIZE\tSHA256")
+
+	for _, manifest := range manifests {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n",
+			manifest.ID, manifest.CreatedAt.Format(time.RFC3339), manifest.Links, manifest.Size, manifest.SHA256)
+	}
+
+	return w.Flush()
+}
+
+func (a *App) PrintMessage(message string) error {
+	if a.Format == OutputJSON {
+		return a.printJSON(map[string]string{"message": message})
+	}
+
+	_, err := fmt.Fprintln(a.Out, message)
+
+	return err
+}
+
+func (a *App) printJSON(v any) error {
+	encoder := json.NewEncoder(a.Out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
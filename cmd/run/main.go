@@ -2,79 +2,324 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
 
 	"github.com/AFK068/compressor/internal/config"
 	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/infrastructure/grpcapi"
 	"github.com/AFK068/compressor/internal/infrastructure/httpapi/compressorapi"
-	"github.com/AFK068/compressor/internal/infrastructure/repository/inmemoryrepo"
-	"github.com/AFK068/compressor/internal/infrastructure/repository/postgresdb"
+	"github.com/AFK068/compressor/internal/infrastructure/snapshot"
 	"github.com/AFK068/compressor/internal/migration"
+	"github.com/AFK068/compressor/internal/migration/migratecmd"
 	"github.com/AFK068/compressor/internal/server"
 	"github.com/AFK068/compressor/pkg/logger"
 	"github.com/AFK068/compressor/pkg/shortener"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/AFK068/compressor/pkg/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
 )
 
 const (
-	DevConfigPath = "config/dev.yaml"
+	DefaultConfigPath = "config/dev.yaml"
+
+	usage = `Usage: run [flags] [command]
+
+Commands:
+  serve          start the HTTP and gRPC servers (default)
+  migrate        apply, roll back or inspect database changesets and exit, see migrate --help
+  check-config   validate the configuration and print it with secrets redacted
+
+Flags take precedence over environment variables, which take precedence over the config file.
+
+Flags:
+`
 )
 
-func NewPostgreDB(cfg *config.Config, shortener domain.Shortener, log *zap.Logger, lc fx.Lifecycle) (domain.Repository, error) {
-	if cfg.Storage.Type == domain.InMemoryRepository {
-		return inmemoryrepo.New(shortener, cfg.Storage.MaxSize), nil
-	}
+var errUsage = errors.New("usage")
 
-	err := migration.RunMigration(cfg, log)
-	if err != nil {
-		log.Fatal("failed to run migration", zap.Error(err))
-	}
+type Repositories struct {
+	fx.Out
 
-	dbPool, err := pgxpool.New(context.Background(), cfg.GetPostgresConnectionString())
+	Repository  domain.Repository
+	Idempotency domain.IdempotencyStore
+	Transactor  domain.Transactor
+}
+
+// NewStorage opens the backend of the driver registered as storage.type, see drivers.go.
+func NewStorage(cfg *config.Config, shortener domain.Shortener, log *zap.Logger, lc fx.Lifecycle) (Repositories, error) {
+	backend, closeBackend, err := storage.Open(context.Background(), cfg, shortener, log)
 	if err != nil {
-		log.Fatal("failed to connect to database", zap.Error(err))
+		return Repositories{}, err
 	}
 
 	lc.Append(fx.Hook{
 		OnStop: func(_ context.Context) error {
-			dbPool.Close()
+			closeBackend()
 			return nil
 		},
 	})
 
-	return postgresdb.New(dbPool, shortener, cfg.Storage.MaxSize), nil
+	return Repositories{Repository: backend, Idempotency: backend, Transactor: backend}, nil
+}
+
+// NewMetrics collects the runtime metrics of the process and the metrics of the repository, e.g.
+// the connection pool statistics of the Postgres backend.
+func NewMetrics(repo domain.Repository) (*prometheus.Registry, error) {
+	registry := prometheus.NewRegistry()
+
+	collected := []prometheus.Collector{
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	}
+
+	if collector, ok := repo.(prometheus.Collector); ok {
+		collected = append(collected, collector)
+	}
+
+	for _, collector := range collected {
+		if err := registry.Register(collector); err != nil {
+			return nil, fmt.Errorf("registering metrics: %w", err)
+		}
+	}
+
+	return registry, nil
 }
 
 func main() {
-	fx.New(
-		fx.Provide(
-			// Logger.
-			logger.New,
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	configPath := flags.String("config", DefaultConfigPath, "path to the configuration file")
+	storageType := flags.String("storage", "", fmt.Sprintf("storage type, one of %v (overrides STORAGE_TYPE)", config.StorageTypes()))
+	port := flags.String("port", "", "HTTP port (overrides SHORTENER_PORT)")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	opts := configOptions(*storageType, *port)
+
+	command, commandArgs := "serve", flags.Args()
+	if len(commandArgs) > 0 {
+		command, commandArgs = commandArgs[0], commandArgs[1:]
+	}
+
+	var err error
+
+	switch command {
+	case "serve":
+		err = serve(*configPath, opts, commandArgs)
+	case "migrate":
+		err = migrate(*configPath, opts, commandArgs, stdout, stderr)
+	case "check-config":
+		err = checkConfig(*configPath, opts, commandArgs, stdout)
+	default:
+		err = errUsage
+	}
+
+	// The migrate command prints its own usage.
+	if errors.Is(err, migratecmd.ErrUsage) {
+		return 2
+	}
+
+	if errors.Is(err, errUsage) {
+		flags.Usage()
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", command, err)
+		return 1
+	}
+
+	return 0
+}
+
+// configOptions turns the override flags into config options, their values are validated with the rest of the config.
+func configOptions(storage, port string) []config.Option {
+	var opts []config.Option
+
+	if storage != "" {
+		opts = append(opts, config.WithStorageType(domain.RepositoryType(storage)))
+	}
+
+	if port != "" {
+		opts = append(opts, config.WithPort(port))
+	}
+
+	return opts
+}
+
+func serve(configPath string, opts []config.Option, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
 
+	app := fx.New(
+		fx.Provide(
 			// Config.
 			func() (*config.Config, error) {
-				return config.NewConfig(DevConfigPath)
+				return config.NewConfig(configPath, opts...)
+			},
+			func(cfg *config.Config, log *zap.Logger) *config.Reloader {
+				return config.NewReloader(configPath, cfg, log, opts...)
 			},
 
+			// Logger.
+			func(cfg *config.Config) (zap.AtomicLevel, error) {
+				return zap.ParseAtomicLevel(cfg.Log.Level)
+			},
+			logger.NewWithLevel,
+
 			// Shortener.
 			func(cfg *config.Config) (domain.Shortener, error) {
 				return shortener.NewShortener(cfg.Shortener.Alphabet, cfg.Shortener.Length)
 			},
 
 			// Repository.
-			NewPostgreDB,
+			NewStorage,
+
+			// Metrics.
+			NewMetrics,
 
-			// Handler.
+			// Handlers.
+			compressorapi.NewShortURLBuilder,
 			compressorapi.NewHandler,
+			compressorapi.NewLinksHandler,
+			compressorapi.NewAdminHandler,
+			compressorapi.NewIdempotency,
+
+			// gRPC service.
+			grpcapi.NewService,
 
-			// Server.
+			// Servers.
 			server.NewCompressor,
+			server.NewGRPC,
 		),
 		fx.Invoke(
 			func(s *server.Compressor, lc fx.Lifecycle, log *zap.Logger) {
 				s.RegisterHooks(lc, log)
 			},
+			func(s *server.GRPC, lc fx.Lifecycle, log *zap.Logger) {
+				s.RegisterHooks(lc, log)
+			},
+			subscribeToReloads,
+			scheduleSnapshots,
 		),
-	).Run()
+	)
+
+	app.Run()
+
+	return app.Err()
+}
+
+// subscribeToReloads applies the settings that can change while running, see config.ImmutableChanges
+// for the ones that cannot.
+func subscribeToReloads(
+	reloader *config.Reloader,
+	level zap.AtomicLevel,
+	builder *compressorapi.ShortURLBuilder,
+	idempotency *compressorapi.Idempotency,
+	service *grpcapi.Service,
+	lc fx.Lifecycle,
+	log *zap.Logger,
+) {
+	reloader.Subscribe(func(cfg *config.Config) {
+		if err := level.UnmarshalText([]byte(cfg.Log.Level)); err != nil {
+			log.Error("Failed to apply log level", zap.Error(err))
+		}
+
+		if err := builder.Reconfigure(cfg); err != nil {
+			log.Error("Failed to apply public base URL", zap.Error(err))
+		}
+
+		idempotency.Reconfigure(cfg)
+		service.Reconfigure(cfg)
+	})
+
+	reloader.RegisterHooks(lc, log)
+}
+
+// scheduleSnapshots exports the links to the snapshot bucket every snapshots.interval, when a
+// bucket is configured.
+func scheduleSnapshots(cfg *config.Config, repo domain.Repository, lc fx.Lifecycle, log *zap.Logger) error {
+	if cfg.Snapshots.Bucket == "" || cfg.Snapshots.Interval == 0 {
+		return nil
+	}
+
+	store, err := snapshot.New(cfg.Snapshots)
+	if err != nil {
+		return err
+	}
+
+	snapshot.NewScheduler(store, repo, cfg.Snapshots.Interval, cfg.Snapshots.Keep).RegisterHooks(lc, log)
+
+	return nil
+}
+
+func migrate(configPath string, opts []config.Option, args []string, stdout, stderr io.Writer) error {
+	cfg, err := config.NewConfig(configPath, opts...)
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+
+	var migrator *migration.Migrator
+
+	command := &migratecmd.Command{
+		Program: "run",
+		Err:     stderr,
+		Printer: &migratecmd.TextPrinter{Out: stdout},
+		Open: func() (*migration.Migrator, error) {
+			migrator, err = migratecmd.Open(cfg, logger.New())
+			return migrator, err
+		},
+	}
+
+	err = command.Run(args)
+
+	if migrator != nil {
+		migrator.Close()
+	}
+
+	return err
+}
+
+// checkConfig builds everything derived from the configuration without connecting anywhere.
+func checkConfig(configPath string, opts []config.Option, args []string, stdout io.Writer) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	cfg, err := config.NewConfig(configPath, opts...)
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+
+	if _, err := compressorapi.NewShortURLBuilder(cfg); err != nil {
+		return err
+	}
+
+	encoder := yaml.NewEncoder(stdout)
+	encoder.SetIndent(4)
+
+	if err := encoder.Encode(cfg.Redacted()); err != nil {
+		return err
+	}
+
+	return encoder.Close()
 }
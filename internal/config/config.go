@@ -2,59 +2,228 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"reflect"
+	"time"
 
 	"github.com/AFK068/compressor/internal/domain"
 	"github.com/ilyakaznacheev/cleanenv"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Storage   Storage   `yaml:"storage" env-required:"true"`
-	Migration Migration `yaml:"migrations" env-required:"true"`
-	Shortener Shortener `yaml:"shortener" env-required:"true"`
+	Storage     Storage     `yaml:"storage" env-required:"true"`
+	Migration   Migration   `yaml:"migrations"`
+	Shortener   Shortener   `yaml:"shortener" env-required:"true"`
+	Idempotency Idempotency `yaml:"idempotency"`
+	Admin       Admin       `yaml:"admin"`
+	Log         Log         `yaml:"log"`
+	Snapshots   Snapshots   `yaml:"snapshots"`
 }
 
 type Storage struct {
-	Type         domain.RepositoryType `yaml:"type" env:"STORAGE_TYPE" env-default:"postgres"`
-	MaxSize      uint64                `yaml:"max_size" env:"MAX_SIZE" env-default:"100"`
-	Host         string                `yaml:"host" env:"POSTGRES_HOST" env-required:"true"`
-	Port         string                `yaml:"port" env:"POSTGRES_PORT" env-required:"true"`
-	DatabaseName string                `yaml:"database_name" env:"POSTGRES_DATABASE_NAME" env-required:"true"`
-	User         string                `yaml:"user" env:"POSTGRES_USER" env-required:"true"`
-	Password     string                `yaml:"password" env:"POSTGRES_PASSWORD" env-required:"true"`
+	Type    domain.RepositoryType `yaml:"type" env:"STORAGE_TYPE" env-default:"postgres"`
+	MaxSize uint64                `yaml:"max_size" env:"MAX_SIZE" env-default:"100"`
+	IDs     IDAllocation          `yaml:"ids"`
+
+	// DriverSettings are the settings of the driver of the storage type, read from storage.<type>,
+	// see RegisterStorageType. Nil for drivers without settings.
+	DriverSettings any `yaml:"-"`
+}
+
+// MarshalYAML writes the driver settings under storage.<type>, where they are read from.
+func (s Storage) MarshalYAML() (any, error) {
+	type plain Storage
+
+	out := struct {
+		plain    `yaml:",inline"`
+		Settings map[string]any `yaml:",inline"`
+	}{plain: plain(s)}
+
+	if s.DriverSettings != nil {
+		out.Settings = map[string]any{string(s.Type): s.DriverSettings}
+	}
+
+	return out, nil
+}
+
+// IDAllocation configures how the ids of new links are leased, in blocks from where the storage
+// keeps them, e.g. the id_allocator table for postgres.
+type IDAllocation struct {
+	// BlockSize is how many ids are leased at once. Unused ids of a block are given back on graceful
+	// shutdown and skipped otherwise.
+	BlockSize uint64 `yaml:"block_size" env:"ID_BLOCK_SIZE" env-default:"100"`
 }
 
 type Migration struct {
-	MigrationsPath string `yaml:"migrations_path" env:"MIGRATIONS_PATH" env-required:"true"`
+	// MigrationsPath overrides the changesets embedded into the binary with the ones in this directory.
+	MigrationsPath string `yaml:"migrations_path" env:"MIGRATIONS_PATH"`
+	// DisableAutoMigrate stops pending changesets from being applied on startup, they are then
+	// applied with `compressorctl migrate`.
+	DisableAutoMigrate bool `yaml:"disable_auto_migrate" env:"DISABLE_AUTO_MIGRATE" env-default:"false"`
 }
 
 type Shortener struct {
 	Port     string `yaml:"port" env:"SHORTENER_PORT" env-default:"8080"`
+	GRPCPort string `yaml:"grpc_port" env:"SHORTENER_GRPC_PORT" env-default:"9090"`
 	Alphabet string `yaml:"alphabet" env:"ALPHABET" env-required:"true"`
 	Length   uint64 `yaml:"length" env:"LENGTH" env-required:"true"`
+
+	// PublicBaseURL is prepended to codes to build short URLs. When empty, the base is derived from the request.
+	PublicBaseURL string `yaml:"public_base_url" env:"PUBLIC_BASE_URL"`
+	// TrustProxy enables X-Forwarded-Host and X-Forwarded-Proto when deriving the base from the request.
+	TrustProxy bool `yaml:"trust_proxy" env:"TRUST_PROXY" env-default:"false"`
+}
+
+type Idempotency struct {
+	// TTL is how long the first response to an Idempotency-Key is replayed.
+	TTL time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" env-default:"24h"`
+}
+
+type Admin struct {
+	// Token is the bearer token of the admin API, which is only served when the token is set.
+	Token string `yaml:"token" env:"ADMIN_TOKEN"`
+}
+
+// Snapshots configures the snapshots of the links in an S3-compatible bucket. Credentials are read
+// like by the AWS CLI, from the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY variables, the shared
+// credentials file or the role of the instance.
+type Snapshots struct {
+	// Bucket holds the snapshots. Snapshots are disabled when empty.
+	Bucket string `yaml:"bucket" env:"SNAPSHOT_BUCKET"`
+	Prefix string `yaml:"prefix" env:"SNAPSHOT_PREFIX" env-default:"snapshots/"`
+	// Interval is how often the service creates a snapshot, 0 only creates them with compressorctl.
+	Interval time.Duration `yaml:"interval" env:"SNAPSHOT_INTERVAL" env-default:"24h"`
+	// Keep is how many of the newest snapshots are kept when the service creates one, 0 keeps all.
+	Keep   int    `yaml:"keep" env:"SNAPSHOT_KEEP" env-default:"7"`
+	Region string `yaml:"region" env:"SNAPSHOT_REGION" env-default:"us-east-1"`
+	// Endpoint replaces the endpoint of the region, e.g. with the URL of a MinIO server.
+	Endpoint string `yaml:"endpoint" env:"SNAPSHOT_ENDPOINT"`
+	// PathStyle puts the bucket in the path of requests instead of the host name, which MinIO and
+	// most S3-compatible storages need.
+	PathStyle bool `yaml:"path_style" env:"SNAPSHOT_PATH_STYLE" env-default:"false"`
+}
+
+type Log struct {
+	// Level is the minimum level written, one of debug, info, warn or error.
+	Level string `yaml:"level" env:"LOG_LEVEL" env-default:"info"`
+}
+
+const (
+	RedactedValue = "REDACTED"
+)
+
+// Option overrides a value read from the file and the environment, e.g. with a command-line flag.
+type Option func(cfg *Config)
+
+func WithStorageType(storageType domain.RepositoryType) Option {
+	return func(cfg *Config) {
+		cfg.Storage.Type = storageType
+	}
+}
+
+func WithPort(port string) Option {
+	return func(cfg *Config) {
+		cfg.Shortener.Port = port
+	}
+}
+
+// NewConfig reads the file at filePath, then the environment, then applies opts, so a value set by
+// a later source takes precedence.
+func NewConfig(filePath string, opts ...Option) (*Config, error) {
+	return newConfig(filePath, true, opts)
+}
+
+// NewFileConfig reads the file at filePath like NewConfig but ignores the environment, values the
+// file does not set get their defaults. It reads the configuration of another deployment, e.g. the
+// destination of compressorctl migrate-data, which the environment of this one must not change.
+func NewFileConfig(filePath string, opts ...Option) (*Config, error) {
+	return newConfig(filePath, false, opts)
 }
 
-func NewConfig(filePath string) (*Config, error) {
+func newConfig(filePath string, readEnv bool, opts []Option) (*Config, error) {
 	config := &Config{}
 
-	if err := cleanenv.ReadConfig(filePath, config); err != nil {
+	if readEnv {
+		if err := cleanenv.ReadConfig(filePath, config); err != nil {
+			return nil, err
+		}
+	} else if err := readFileOnly(filePath, config); err != nil {
+		return nil, err
+	}
+
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if err := config.readDriverSettings(filePath, readEnv); err != nil {
 		return nil, err
 	}
 
-	switch config.Storage.Type {
-	case domain.InMemoryRepository, domain.PostgresRepository:
-	default:
-		config.Storage.Type = domain.PostgresRepository
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
 
 	return config, nil
 }
 
-func (cfg *Config) GetPostgresConnectionString() string {
-	return fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=disable",
-		cfg.Storage.User,
-		cfg.Storage.Password,
-		cfg.Storage.Host,
-		cfg.Storage.Port,
-		cfg.Storage.DatabaseName,
-	)
+// readFileOnly reads the yaml file at filePath into v and applies the defaults of the fields it does
+// not set, without the environment.
+func readFileOnly(filePath string, v any) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("config file parsing error: %w", err)
+	}
+
+	return applyDefaults(reflect.ValueOf(v).Elem())
+}
+
+// applyDefaults sets the zero fields of the struct v to their env-default tag, like cleanenv does
+// for variables that are not set.
+func applyDefaults(v reflect.Value) error {
+	for i := range v.NumField() {
+		field, structField := v.Field(i), v.Type().Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+
+		if value, ok := structField.Tag.Lookup("env-default"); ok {
+			if !field.IsZero() {
+				continue
+			}
+
+			if err := yaml.Unmarshal([]byte(value), field.Addr().Interface()); err != nil {
+				return fmt.Errorf("default of %s: %w", structField.Name, err)
+			}
+
+			continue
+		}
+
+		if field.Kind() == reflect.Struct {
+			if err := applyDefaults(field); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Redacted returns a copy of the configuration with secrets replaced, safe to print or log.
+func (cfg *Config) Redacted() *Config {
+	redacted := *cfg
+
+	if settings, ok := cfg.Storage.DriverSettings.(SettingsRedactor); ok {
+		redacted.Storage.DriverSettings = settings.Redacted()
+	}
+
+	if redacted.Admin.Token != "" {
+		redacted.Admin.Token = RedactedValue
+	}
+
+	return &redacted
 }
@@ -0,0 +1,174 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/infrastructure/repository"
+	"github.com/AFK068/compressor/pkg/shortener"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	TestConfigPath = "../../config/test.yaml"
+)
+
+func Test_NewConfig_Precedence_Success(t *testing.T) {
+	cfg, err := config.NewConfig(TestConfigPath)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.PostgresRepository, cfg.Storage.Type)
+	assert.Equal(t, "8080", cfg.Shortener.Port)
+
+	t.Setenv("STORAGE_TYPE", "inmemory")
+	t.Setenv("SHORTENER_PORT", "8081")
+
+	cfg, err = config.NewConfig(TestConfigPath)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.InMemoryRepository, cfg.Storage.Type)
+	assert.Equal(t, "8081", cfg.Shortener.Port)
+
+	cfg, err = config.NewConfig(TestConfigPath, config.WithStorageType(domain.PostgresRepository), config.WithPort("8082"))
+	assert.NoError(t, err)
+	assert.Equal(t, domain.PostgresRepository, cfg.Storage.Type)
+	assert.Equal(t, "8082", cfg.Shortener.Port)
+}
+
+func Test_NewFileConfig_IgnoresEnvironment_Success(t *testing.T) {
+	t.Setenv("STORAGE_TYPE", "inmemory")
+	t.Setenv("POSTGRES_HOST", "elsewhere")
+	t.Setenv("SHORTENER_GRPC_PORT", "9091")
+
+	cfg, err := config.NewFileConfig(TestConfigPath)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.PostgresRepository, cfg.Storage.Type)
+	assert.Equal(t, "9090", cfg.Shortener.GRPCPort)
+	assert.Equal(t, 24*time.Hour, cfg.Idempotency.TTL)
+
+	settings := cfg.Storage.DriverSettings.(*repository.PostgresSettings)
+	assert.Equal(t, "localhost", settings.Host)
+	assert.Equal(t, "disable", settings.TLS.Mode)
+	assert.Equal(t, 5*time.Second, settings.ConnectTimeout)
+
+	fromEnv, err := config.NewConfig(TestConfigPath, config.WithStorageType(domain.PostgresRepository))
+	assert.NoError(t, err)
+	assert.Equal(t, "elsewhere", fromEnv.Storage.DriverSettings.(*repository.PostgresSettings).Host)
+}
+
+func Test_SameStorage_Success(t *testing.T) {
+	source, err := config.NewConfig(TestConfigPath)
+	assert.NoError(t, err)
+
+	t.Setenv("POSTGRES_USER", "migrator")
+	t.Setenv("POSTGRES_MAX_CONNS", "4")
+
+	destination, err := config.NewConfig(TestConfigPath)
+	assert.NoError(t, err)
+	assert.True(t, config.SameStorage(source, destination))
+
+	t.Setenv("POSTGRES_DATABASE_NAME", "compressor_new")
+
+	destination, err = config.NewConfig(TestConfigPath)
+	assert.NoError(t, err)
+	assert.False(t, config.SameStorage(source, destination))
+}
+
+func Test_NewConfig_MovedStorageSettings_Failure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+shortener:
+    alphabet: "abs"
+    length: 10
+storage:
+    type: "postgres"
+    max_size: 100
+    host: "localhost"
+    tls:
+        mode: "require"
+    memory_budget: 1024
+`), 0o600))
+
+	_, err := config.NewConfig(path)
+
+	var validationErr *config.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Fields, 3)
+	assert.Contains(t, err.Error(), "storage.host: moved to storage.postgres.host")
+	assert.Contains(t, err.Error(), "storage.memory_budget: moved to storage.inmemory.memory_budget")
+	assert.Contains(t, err.Error(), "storage.tls: moved to storage.postgres.tls")
+}
+
+func Test_Redacted_Success(t *testing.T) {
+	cfg, err := config.NewConfig(TestConfigPath)
+	assert.NoError(t, err)
+
+	cfg.Admin.Token = "token"
+
+	redacted := cfg.Redacted()
+	assert.Equal(t, config.RedactedValue, redacted.Storage.DriverSettings.(*repository.PostgresSettings).Password)
+	assert.Equal(t, config.RedactedValue, redacted.Admin.Token)
+	assert.Equal(t, "password", cfg.Storage.DriverSettings.(*repository.PostgresSettings).Password)
+	assert.Equal(t, "token", cfg.Admin.Token)
+}
+
+func Test_Storage_MarshalYAML_Success(t *testing.T) {
+	cfg, err := config.NewConfig(TestConfigPath)
+	assert.NoError(t, err)
+
+	out, err := yaml.Marshal(cfg.Redacted().Storage)
+	assert.NoError(t, err)
+
+	var storage struct {
+		Type     string                      `yaml:"type"`
+		Postgres repository.PostgresSettings `yaml:"postgres"`
+	}
+
+	assert.NoError(t, yaml.Unmarshal(out, &storage))
+	assert.Equal(t, "postgres", storage.Type)
+	assert.Equal(t, "localhost", storage.Postgres.Host)
+	assert.Equal(t, config.RedactedValue, storage.Postgres.Password)
+}
+
+func Test_Validate_ReportsAllProblems_Failure(t *testing.T) {
+	t.Setenv("STORAGE_TYPE", "mongo")
+	t.Setenv("ALPHABET", "abca")
+	t.Setenv("LENGTH", "2")
+	t.Setenv("SHORTENER_GRPC_PORT", "8080")
+
+	_, err := config.NewConfig(TestConfigPath)
+
+	var validationErr *config.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	paths := make([]string, 0, len(validationErr.Fields))
+	for _, field := range validationErr.Fields {
+		paths = append(paths, field.Path)
+	}
+
+	assert.Equal(t, []string{"storage.type", "shortener.alphabet", "storage.max_size", "shortener.grpc_port"}, paths)
+	assert.ErrorIs(t, err, shortener.ErrDuplicateCharacter)
+}
+
+func Test_Validate_CapacityOverflow_Failure(t *testing.T) {
+	t.Setenv("LENGTH", "100")
+
+	_, err := config.NewConfig(TestConfigPath)
+	assert.ErrorIs(t, err, shortener.ErrCapacityOverflow)
+	assert.Contains(t, err.Error(), "shortener.length")
+}
+
+func Test_Validate_Snapshots_Failure(t *testing.T) {
+	t.Setenv("SNAPSHOT_BUCKET", "compressor-snapshots")
+	t.Setenv("SNAPSHOT_INTERVAL", "10s")
+	t.Setenv("SNAPSHOT_KEEP", "-1")
+	t.Setenv("SNAPSHOT_ENDPOINT", "minio:9000")
+
+	_, err := config.NewConfig(TestConfigPath)
+	assert.Contains(t, err.Error(), "snapshots.interval")
+	assert.Contains(t, err.Error(), "snapshots.keep")
+	assert.Contains(t, err.Error(), "snapshots.endpoint")
+}
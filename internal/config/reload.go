@@ -0,0 +1,223 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	// ReloadDebounce groups the bursts of events editors and config map updates produce into one reload.
+	ReloadDebounce = 100 * time.Millisecond
+)
+
+// ErrImmutableSetting is returned by Reloader.Reload when the new configuration changes a setting
+// that is only read on startup.
+var ErrImmutableSetting = errors.New("setting cannot be changed without a restart")
+
+// immutableSettings are read once on startup, e.g. changing the alphabet or the length would change
+// the meaning of every code already handed out. Every other setting is applied live by the subscribers.
+var immutableSettings = []struct {
+	path  string
+	value func(cfg *Config) any
+}{
+	{"storage", func(cfg *Config) any { return cfg.Storage }},
+	{"migrations", func(cfg *Config) any { return cfg.Migration }},
+	{"shortener.port", func(cfg *Config) any { return cfg.Shortener.Port }},
+	{"shortener.grpc_port", func(cfg *Config) any { return cfg.Shortener.GRPCPort }},
+	{"shortener.alphabet", func(cfg *Config) any { return cfg.Shortener.Alphabet }},
+	{"shortener.length", func(cfg *Config) any { return cfg.Shortener.Length }},
+	{"admin.token", func(cfg *Config) any { return cfg.Admin.Token }},
+	{"snapshots", func(cfg *Config) any { return cfg.Snapshots }},
+}
+
+// ImmutableChanges returns the paths of the settings that differ between current and next and
+// cannot be changed without a restart.
+func ImmutableChanges(current, next *Config) []string {
+	var paths []string
+
+	for _, setting := range immutableSettings {
+		if !reflect.DeepEqual(setting.value(current), setting.value(next)) {
+			paths = append(paths, setting.path)
+		}
+	}
+
+	return paths
+}
+
+// Reloader re-reads the configuration file when it changes or the process receives SIGHUP, and
+// publishes the new configuration to its subscribers. A configuration that is invalid or changes
+// an immutable setting is refused as a whole and the current one is kept.
+type Reloader struct {
+	path string
+	opts []Option
+	log  *zap.Logger
+
+	// reloadMu serializes reloads, mu guards the fields below it.
+	reloadMu    sync.Mutex
+	mu          sync.Mutex
+	current     *Config
+	subscribers []func(cfg *Config)
+}
+
+// NewReloader watches the file cfg was read from, opts are applied on every reload like on startup.
+func NewReloader(path string, cfg *Config, log *zap.Logger, opts ...Option) *Reloader {
+	return &Reloader{
+		path:    path,
+		opts:    opts,
+		log:     log,
+		current: cfg,
+	}
+}
+
+// Current returns the last configuration that was applied.
+func (r *Reloader) Current() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.current
+}
+
+// Subscribe calls fn with every configuration applied from now on. Subscribers are called one at a
+// time, in the order they subscribed.
+func (r *Reloader) Subscribe(fn func(cfg *Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// Reload reads and validates the configuration file and publishes it when it changed.
+func (r *Reloader) Reload() error {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	next, err := NewConfig(r.path, r.opts...)
+	if err != nil {
+		r.log.Error("Refusing configuration reload", zap.String("path", r.path), zap.Error(err))
+		return err
+	}
+
+	current := r.Current()
+	if reflect.DeepEqual(current, next) {
+		return nil
+	}
+
+	if changes := ImmutableChanges(current, next); len(changes) > 0 {
+		r.log.Error("Refusing configuration reload",
+			zap.String("path", r.path),
+			zap.Strings("settings", changes),
+			zap.String("reason", ErrImmutableSetting.Error()),
+		)
+
+		return fmt.Errorf("%v: %w", changes, ErrImmutableSetting)
+	}
+
+	r.mu.Lock()
+	r.current = next
+	subscribers := r.subscribers
+	r.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(next)
+	}
+
+	r.log.Info("Configuration reloaded", zap.String("path", r.path))
+
+	return nil
+}
+
+// Watch reloads the configuration on SIGHUP and on changes to the file until ctx is done. The
+// directory is watched rather than the file, so files replaced by a rename are picked up too.
+func (r *Reloader) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("creating file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(r.path)); err != nil {
+		return fmt.Errorf("watching %s: %w", r.path, err)
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	defer signal.Stop(hangup)
+
+	debounce := time.NewTimer(ReloadDebounce)
+	debounce.Stop()
+
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hangup:
+			r.log.Info("Received SIGHUP, reloading configuration")
+
+			_ = r.Reload()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			if event.Has(fsnotify.Chmod) {
+				continue
+			}
+
+			debounce.Reset(ReloadDebounce)
+		case <-debounce.C:
+			_ = r.Reload()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			r.log.Error("Failed to watch configuration", zap.String("path", r.path), zap.Error(err))
+		}
+	}
+}
+
+func (r *Reloader) RegisterHooks(lc fx.Lifecycle, log *zap.Logger) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			log.Info("Watching configuration", zap.String("path", r.path))
+
+			go func() {
+				defer close(done)
+
+				if err := r.Watch(ctx); err != nil {
+					log.Error("Failed to watch configuration", zap.Error(err))
+				}
+			}()
+
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+
+			select {
+			case <-done:
+			case <-stopCtx.Done():
+			}
+
+			return nil
+		},
+	})
+}
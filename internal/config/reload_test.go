@@ -0,0 +1,113 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// setupReloader copies the test config into a temporary directory, so tests can rewrite it.
+func setupReloader(t *testing.T) (*config.Reloader, string) {
+	t.Helper()
+
+	data, err := os.ReadFile(TestConfigPath)
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+
+	cfg, err := config.NewConfig(path)
+	assert.NoError(t, err)
+
+	return config.NewReloader(path, cfg, zap.NewNop()), path
+}
+
+func rewriteConfig(t *testing.T, path, old, replacement string) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, []byte(strings.Replace(string(data), old, replacement, 1)), 0o600))
+}
+
+func Test_Reload_LiveSetting_Success(t *testing.T) {
+	reloader, path := setupReloader(t)
+
+	var published *config.Config
+
+	reloader.Subscribe(func(cfg *config.Config) {
+		published = cfg
+	})
+
+	rewriteConfig(t, path, `length: 10`, "length: 10\n    trust_proxy: true")
+
+	assert.NoError(t, reloader.Reload())
+	assert.NotNil(t, published)
+	assert.True(t, published.Shortener.TrustProxy)
+	assert.Equal(t, published, reloader.Current())
+}
+
+func Test_Reload_ImmutableSetting_Failure(t *testing.T) {
+	reloader, path := setupReloader(t)
+	current := reloader.Current()
+
+	reloader.Subscribe(func(*config.Config) {
+		t.Error("subscriber called with a refused configuration")
+	})
+
+	rewriteConfig(t, path, `alphabet: "abs"`, `alphabet: "abc"`)
+
+	err := reloader.Reload()
+	assert.ErrorIs(t, err, config.ErrImmutableSetting)
+	assert.Contains(t, err.Error(), "shortener.alphabet")
+	assert.Same(t, current, reloader.Current())
+}
+
+func Test_Reload_InvalidConfig_Failure(t *testing.T) {
+	reloader, path := setupReloader(t)
+	current := reloader.Current()
+
+	rewriteConfig(t, path, `length: 10`, "length: 10\n    public_base_url: \"not a url\"")
+
+	var validationErr *config.ValidationError
+	assert.ErrorAs(t, reloader.Reload(), &validationErr)
+	assert.Same(t, current, reloader.Current())
+}
+
+func Test_Watch_FileChange_Success(t *testing.T) {
+	reloader, path := setupReloader(t)
+
+	var reloads atomic.Int32
+
+	reloader.Subscribe(func(*config.Config) {
+		reloads.Add(1)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- reloader.Watch(ctx)
+	}()
+
+	// Give the watcher time to subscribe to the directory.
+	time.Sleep(50 * time.Millisecond)
+
+	rewriteConfig(t, path, `length: 10`, "length: 10\n    trust_proxy: true")
+
+	assert.Eventually(t, func() bool {
+		return reloads.Load() == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.True(t, reloader.Current().Shortener.TrustProxy)
+}
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/ilyakaznacheev/cleanenv"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	storageTypesMu sync.RWMutex
+	// storageTypes maps the valid values of storage.type to the constructor of the settings of their
+	// driver, nil for drivers without settings.
+	storageTypes = map[domain.RepositoryType]func() any{}
+)
+
+// RegisterStorageType makes storageType a valid storage.type. newSettings, when not nil, returns a
+// pointer to the settings of its driver, read from storage.<type> of the file and from the
+// environment like the rest of the configuration. It is called by storage.Register.
+func RegisterStorageType(storageType domain.RepositoryType, newSettings func() any) {
+	storageTypesMu.Lock()
+	defer storageTypesMu.Unlock()
+
+	storageTypes[storageType] = newSettings
+}
+
+// StorageTypes returns the valid values of storage.type, sorted.
+func StorageTypes() []domain.RepositoryType {
+	storageTypesMu.RLock()
+	defer storageTypesMu.RUnlock()
+
+	types := make([]domain.RepositoryType, 0, len(storageTypes))
+	for storageType := range storageTypes {
+		types = append(types, storageType)
+	}
+
+	slices.Sort(types)
+
+	return types
+}
+
+func lookupStorageType(storageType domain.RepositoryType) (func() any, bool) {
+	storageTypesMu.RLock()
+	defer storageTypesMu.RUnlock()
+
+	newSettings, ok := storageTypes[storageType]
+
+	return newSettings, ok
+}
+
+// SettingsValidator is implemented by driver settings that can be checked, their problems are
+// reported under storage.<type>. A *ValidationError reports each field under its path below
+// storage.<type>, e.g. "table" as storage.dynamodb.table.
+type SettingsValidator interface {
+	Validate() error
+}
+
+// SettingsResolver is implemented by driver settings that are completed once read, e.g. with a
+// secret read from a file. Resolve is called after the environment is read and before Validate.
+type SettingsResolver interface {
+	Resolve() error
+}
+
+// SettingsRedactor is implemented by driver settings holding secrets. Redacted returns a copy with
+// the secrets replaced by RedactedValue, which Config.Redacted puts in place of the settings.
+type SettingsRedactor interface {
+	Redacted() any
+}
+
+// MigratableSettings is implemented by the driver settings of storages whose schema is managed by
+// changesets, see the migration package. MigrationDSN is the connection string they are applied with.
+type MigratableSettings interface {
+	MigrationDSN() string
+}
+
+// SettingsLocator is implemented by the driver settings of storages kept outside the process.
+// Location identifies where the links are kept, e.g. the host, port and database for postgres, and
+// is the same for settings that only differ in how they connect.
+type SettingsLocator interface {
+	Location() string
+}
+
+// SameStorage tells whether a and b keep their links in the same storage. Driver settings that do
+// not implement SettingsLocator are compared as a whole.
+func SameStorage(a, b *Config) bool {
+	if a.Storage.Type != b.Storage.Type {
+		return false
+	}
+
+	locatorA, okA := a.Storage.DriverSettings.(SettingsLocator)
+	locatorB, okB := b.Storage.DriverSettings.(SettingsLocator)
+
+	if okA && okB {
+		return locatorA.Location() == locatorB.Location()
+	}
+
+	return reflect.DeepEqual(a.Storage.DriverSettings, b.Storage.DriverSettings)
+}
+
+// settingsError reports err of the driver settings of storageType under storage.<type>.
+func settingsError(storageType domain.RepositoryType, err error) *ValidationError {
+	prefix := "storage." + string(storageType)
+
+	var v *ValidationError
+	if !errors.As(err, &v) {
+		return &ValidationError{Fields: []*FieldError{{Path: prefix, Err: err}}}
+	}
+
+	fields := make([]*FieldError, 0, len(v.Fields))
+	for _, field := range v.Fields {
+		fields = append(fields, &FieldError{Path: prefix + "." + field.Path, Err: field.Err})
+	}
+
+	return &ValidationError{Fields: fields}
+}
+
+// readDriverSettings reads the settings of the driver of the storage type from the file at
+// filePath and, with readEnv, the environment.
+func (cfg *Config) readDriverSettings(filePath string, readEnv bool) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	var file struct {
+		Storage map[string]yaml.Node `yaml:"storage"`
+	}
+
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return err
+	}
+
+	if err := movedSettings(file.Storage); err != nil {
+		return err
+	}
+
+	newSettings, ok := lookupStorageType(cfg.Storage.Type)
+	if !ok || newSettings == nil {
+		return nil
+	}
+
+	settings := newSettings()
+
+	if node, ok := file.Storage[string(cfg.Storage.Type)]; ok {
+		if err := node.Decode(settings); err != nil {
+			return fmt.Errorf("storage.%s: %w", cfg.Storage.Type, err)
+		}
+	}
+
+	if readEnv {
+		err = cleanenv.ReadEnv(settings)
+	} else {
+		err = applyDefaults(reflect.ValueOf(settings).Elem())
+	}
+
+	if err != nil {
+		return fmt.Errorf("storage.%s: %w", cfg.Storage.Type, err)
+	}
+
+	if resolver, ok := settings.(SettingsResolver); ok {
+		if err := resolver.Resolve(); err != nil {
+			return settingsError(cfg.Storage.Type, err)
+		}
+	}
+
+	cfg.Storage.DriverSettings = settings
+
+	return nil
+}
+
+// movedSettings reports the driver settings set directly under storage, where the built-in drivers
+// read them before they had settings of their own, with the storage.<type> they have moved to.
+// They would be ignored otherwise.
+func movedSettings(storage map[string]yaml.Node) error {
+	v := &ValidationError{}
+
+	for _, key := range slices.Sorted(maps.Keys(storage)) {
+		if slices.Contains(settingsKeys(Storage{}), key) {
+			continue
+		}
+
+		var moved []string
+
+		for _, storageType := range StorageTypes() {
+			newSettings, _ := lookupStorageType(storageType)
+			if newSettings != nil && slices.Contains(settingsKeys(newSettings()), key) {
+				moved = append(moved, "storage."+string(storageType)+"."+key)
+			}
+		}
+
+		if len(moved) > 0 {
+			v.Fields = append(v.Fields, &FieldError{
+				Path: "storage." + key,
+				Err:  fmt.Errorf("moved to %s", strings.Join(moved, " or ")),
+			})
+		}
+	}
+
+	if len(v.Fields) == 0 {
+		return nil
+	}
+
+	return v
+}
+
+// settingsKeys returns the yaml keys of the fields of the driver settings.
+func settingsKeys(settings any) []string {
+	t := reflect.TypeOf(settings)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	keys := make([]string, 0, t.NumField())
+
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			keys = append(keys, name)
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AFK068/compressor/pkg/shortener"
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldError is a problem with the value at Path, the dotted yaml path such as "shortener.alphabet".
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+
+	b.WriteString("invalid configuration:")
+
+	for _, field := range e.Fields {
+		b.WriteString("\n  ")
+		b.WriteString(field.Error())
+	}
+
+	return b.String()
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		errs = append(errs, field)
+	}
+
+	return errs
+}
+
+// Validate checks the configuration as a whole and returns a *ValidationError listing all problems.
+func (cfg *Config) Validate() error {
+	v := &ValidationError{}
+
+	add := func(path string, err error) {
+		v.Fields = append(v.Fields, &FieldError{Path: path, Err: err})
+	}
+
+	if _, ok := lookupStorageType(cfg.Storage.Type); !ok {
+		add("storage.type", fmt.Errorf("unknown storage type %q, expected one of %v", cfg.Storage.Type, StorageTypes()))
+	}
+
+	if settings, ok := cfg.Storage.DriverSettings.(SettingsValidator); ok {
+		if err := settings.Validate(); err != nil {
+			v.Fields = append(v.Fields, settingsError(cfg.Storage.Type, err).Fields...)
+		}
+	}
+
+	if cfg.Storage.MaxSize == 0 {
+		add("storage.max_size", errors.New("max_size must be greater than 0"))
+	}
+
+	if cfg.Storage.IDs.BlockSize == 0 {
+		add("storage.ids.block_size", errors.New("block_size must be greater than 0"))
+	}
+
+	cfg.validateShortener(add)
+
+	ports := []struct{ path, port string }{
+		{"shortener.port", cfg.Shortener.Port},
+		{"shortener.grpc_port", cfg.Shortener.GRPCPort},
+	}
+
+	for _, p := range ports {
+		if n, err := strconv.Atoi(p.port); err != nil || n < 1 || n > 65535 {
+			add(p.path, fmt.Errorf("port %q must be a number between 1 and 65535", p.port))
+		}
+	}
+
+	if cfg.Shortener.Port == cfg.Shortener.GRPCPort {
+		add("shortener.grpc_port", fmt.Errorf("port %q is already used by shortener.port", cfg.Shortener.GRPCPort))
+	}
+
+	if cfg.Shortener.PublicBaseURL != "" {
+		u, err := url.Parse(cfg.Shortener.PublicBaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			add("shortener.public_base_url", fmt.Errorf("%q must be an absolute URL", cfg.Shortener.PublicBaseURL))
+		}
+	}
+
+	if cfg.Idempotency.TTL <= 0 {
+		add("idempotency.ttl", errors.New("ttl must be positive"))
+	}
+
+	if _, err := zapcore.ParseLevel(cfg.Log.Level); err != nil {
+		add("log.level", err)
+	}
+
+	if cfg.Snapshots.Bucket != "" {
+		cfg.validateSnapshots(add)
+	}
+
+	if len(v.Fields) > 0 {
+		return v
+	}
+
+	return nil
+}
+
+func (cfg *Config) validateSnapshots(add func(path string, err error)) {
+	if cfg.Snapshots.Interval != 0 && cfg.Snapshots.Interval < time.Minute {
+		add("snapshots.interval", errors.New("interval must be 0 or at least 1m"))
+	}
+
+	if cfg.Snapshots.Keep < 0 {
+		add("snapshots.keep", errors.New("keep must not be negative"))
+	}
+
+	if cfg.Snapshots.Region == "" {
+		add("snapshots.region", errors.New("region is required"))
+	}
+
+	if cfg.Snapshots.Endpoint != "" {
+		u, err := url.Parse(cfg.Snapshots.Endpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			add("snapshots.endpoint", fmt.Errorf("%q must be an absolute URL", cfg.Snapshots.Endpoint))
+		}
+	}
+}
+
+func (cfg *Config) validateShortener(add func(path string, err error)) {
+	if err := shortener.ValidateAlphabet(cfg.Shortener.Alphabet); err != nil {
+		add("shortener.alphabet", err)
+	}
+
+	if cfg.Shortener.Length == 0 {
+		add("shortener.length", shortener.ErrInvalidLength)
+		return
+	}
+
+	if cfg.Shortener.Alphabet == "" {
+		return
+	}
+
+	capacity, err := shortener.Capacity(uint64(len(cfg.Shortener.Alphabet)), cfg.Shortener.Length)
+	if err != nil {
+		add("shortener.length", fmt.Errorf("%w, use a shorter length or a smaller alphabet", err))
+		return
+	}
+
+	if cfg.Storage.MaxSize > capacity {
+		add("storage.max_size", fmt.Errorf(
+			"max_size %d exceeds the %d codes of length %d over a %d character alphabet",
+			cfg.Storage.MaxSize, capacity, cfg.Shortener.Length, len(cfg.Shortener.Alphabet),
+		))
+	}
+}
@@ -11,3 +11,22 @@ type ErrURLNotFound struct {
 }
 
 func (e *ErrURLNotFound) Error() string { return e.Message }
+
+type ErrIdempotencyRecordNotFound struct {
+	Message string
+}
+
+func (e *ErrIdempotencyRecordNotFound) Error() string { return e.Message }
+
+// ErrNotSupported is returned by a backend for an operation it cannot perform.
+type ErrNotSupported struct {
+	Message string
+}
+
+func (e *ErrNotSupported) Error() string { return e.Message }
+
+type ErrLinkConflict struct {
+	Message string
+}
+
+func (e *ErrLinkConflict) Error() string { return e.Message }
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyRecord is the first response given to a request carrying an Idempotency-Key. Until the
+// response is known the record is in flight: it reserves the key for the request being handled.
+type IdempotencyRecord struct {
+	Key         string
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	// Headers are the other headers of the response, e.g. Location.
+	Headers map[string][]string
+	Body    []byte
+	// CreatedAt identifies the reservation, so only the request that made it completes or releases it.
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// InFlight reports whether the request that reserved the key is still being handled.
+func (r *IdempotencyRecord) InFlight() bool {
+	return r.StatusCode == 0
+}
+
+type IdempotencyStore interface {
+	// GetIdempotencyRecord returns the unexpired record stored under key.
+	GetIdempotencyRecord(ctx context.Context, key string) (*IdempotencyRecord, error)
+	// ReserveIdempotencyKey atomically stores record, which is in flight, unless an unexpired record
+	// already exists under its key. It returns the existing record, or nil when the key was reserved.
+	ReserveIdempotencyKey(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error)
+	// CompleteIdempotencyRecord stores the response of the record reserved by ReserveIdempotencyKey.
+	// It fails with apperrors.ErrIdempotencyRecordNotFound when the reservation expired meanwhile.
+	CompleteIdempotencyRecord(ctx context.Context, record *IdempotencyRecord) error
+	// ReleaseIdempotencyKey deletes the record reserved by ReserveIdempotencyKey unless it was completed,
+	// so the request can be retried.
+	ReleaseIdempotencyKey(ctx context.Context, record *IdempotencyRecord) error
+	DeleteExpiredIdempotencyRecords(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+type Link struct {
+	ID          uint64
+	Code        string
+	Destination string
+	CreatedAt   time.Time
+	ExpiresAt   *time.Time
+	Owner       string
+	Disabled    bool
+}
+
+func (l *Link) IsExpired(now time.Time) bool {
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+// IsResolvable reports whether the link may be followed: it is neither disabled nor expired.
+func (l *Link) IsResolvable(now time.Time) bool {
+	return !l.Disabled && !l.IsExpired(now)
+}
+
+type ListLinksParams struct {
+	// FromID is the smallest id to return, pass the last returned id + 1 to get the next page.
+	FromID uint64
+	Limit  int
+}
+
+type Stats struct {
+	Total    uint64
+	Active   uint64
+	Disabled uint64
+	Expired  uint64
+	Capacity uint64
+}
@@ -0,0 +1,308 @@
+// Code generated by mockery v2.52.4. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	domain "github.com/AFK068/compressor/internal/domain"
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// IdempotencyStore is an autogenerated mock type for the IdempotencyStore type
+type IdempotencyStore struct {
+	mock.Mock
+}
+
+type IdempotencyStore_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *IdempotencyStore) EXPECT() *IdempotencyStore_Expecter {
+	return &IdempotencyStore_Expecter{mock: &_m.Mock}
+}
+
+// CompleteIdempotencyRecord provides a mock function with given fields: ctx, record
+func (_m *IdempotencyStore) CompleteIdempotencyRecord(ctx context.Context, record *domain.IdempotencyRecord) error {
+	ret := _m.Called(ctx, record)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CompleteIdempotencyRecord")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.IdempotencyRecord) error); ok {
+		r0 = rf(ctx, record)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// IdempotencyStore_CompleteIdempotencyRecord_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CompleteIdempotencyRecord'
+type IdempotencyStore_CompleteIdempotencyRecord_Call struct {
+	*mock.Call
+}
+
+// CompleteIdempotencyRecord is a helper method to define mock.On call
+//   - ctx context.Context
+//   - record *domain.IdempotencyRecord
+func (_e *IdempotencyStore_Expecter) CompleteIdempotencyRecord(ctx interface{}, record interface{}) *IdempotencyStore_CompleteIdempotencyRecord_Call {
+	return &IdempotencyStore_CompleteIdempotencyRecord_Call{Call: _e.mock.On("CompleteIdempotencyRecord", ctx, record)}
+}
+
+func (_c *IdempotencyStore_CompleteIdempotencyRecord_Call) Run(run func(ctx context.Context, record *domain.IdempotencyRecord)) *IdempotencyStore_CompleteIdempotencyRecord_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*domain.IdempotencyRecord))
+	})
+	return _c
+}
+
+func (_c *IdempotencyStore_CompleteIdempotencyRecord_Call) Return(_a0 error) *IdempotencyStore_CompleteIdempotencyRecord_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *IdempotencyStore_CompleteIdempotencyRecord_Call) RunAndReturn(run func(context.Context, *domain.IdempotencyRecord) error) *IdempotencyStore_CompleteIdempotencyRecord_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteExpiredIdempotencyRecords provides a mock function with given fields: ctx, now
+func (_m *IdempotencyStore) DeleteExpiredIdempotencyRecords(ctx context.Context, now time.Time) (int64, error) {
+	ret := _m.Called(ctx, now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteExpiredIdempotencyRecords")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) (int64, error)); ok {
+		return rf(ctx, now)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int64); ok {
+		r0 = rf(ctx, now)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IdempotencyStore_DeleteExpiredIdempotencyRecords_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteExpiredIdempotencyRecords'
+type IdempotencyStore_DeleteExpiredIdempotencyRecords_Call struct {
+	*mock.Call
+}
+
+// DeleteExpiredIdempotencyRecords is a helper method to define mock.On call
+//   - ctx context.Context
+//   - now time.Time
+func (_e *IdempotencyStore_Expecter) DeleteExpiredIdempotencyRecords(ctx interface{}, now interface{}) *IdempotencyStore_DeleteExpiredIdempotencyRecords_Call {
+	return &IdempotencyStore_DeleteExpiredIdempotencyRecords_Call{Call: _e.mock.On("DeleteExpiredIdempotencyRecords", ctx, now)}
+}
+
+func (_c *IdempotencyStore_DeleteExpiredIdempotencyRecords_Call) Run(run func(ctx context.Context, now time.Time)) *IdempotencyStore_DeleteExpiredIdempotencyRecords_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(time.Time))
+	})
+	return _c
+}
+
+func (_c *IdempotencyStore_DeleteExpiredIdempotencyRecords_Call) Return(_a0 int64, _a1 error) *IdempotencyStore_DeleteExpiredIdempotencyRecords_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *IdempotencyStore_DeleteExpiredIdempotencyRecords_Call) RunAndReturn(run func(context.Context, time.Time) (int64, error)) *IdempotencyStore_DeleteExpiredIdempotencyRecords_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetIdempotencyRecord provides a mock function with given fields: ctx, key
+func (_m *IdempotencyStore) GetIdempotencyRecord(ctx context.Context, key string) (*domain.IdempotencyRecord, error) {
+	ret := _m.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetIdempotencyRecord")
+	}
+
+	var r0 *domain.IdempotencyRecord
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*domain.IdempotencyRecord, error)); ok {
+		return rf(ctx, key)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *domain.IdempotencyRecord); ok {
+		r0 = rf(ctx, key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.IdempotencyRecord)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IdempotencyStore_GetIdempotencyRecord_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetIdempotencyRecord'
+type IdempotencyStore_GetIdempotencyRecord_Call struct {
+	*mock.Call
+}
+
+// GetIdempotencyRecord is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+func (_e *IdempotencyStore_Expecter) GetIdempotencyRecord(ctx interface{}, key interface{}) *IdempotencyStore_GetIdempotencyRecord_Call {
+	return &IdempotencyStore_GetIdempotencyRecord_Call{Call: _e.mock.On("GetIdempotencyRecord", ctx, key)}
+}
+
+func (_c *IdempotencyStore_GetIdempotencyRecord_Call) Run(run func(ctx context.Context, key string)) *IdempotencyStore_GetIdempotencyRecord_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *IdempotencyStore_GetIdempotencyRecord_Call) Return(_a0 *domain.IdempotencyRecord, _a1 error) *IdempotencyStore_GetIdempotencyRecord_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *IdempotencyStore_GetIdempotencyRecord_Call) RunAndReturn(run func(context.Context, string) (*domain.IdempotencyRecord, error)) *IdempotencyStore_GetIdempotencyRecord_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ReleaseIdempotencyKey provides a mock function with given fields: ctx, record
+func (_m *IdempotencyStore) ReleaseIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord) error {
+	ret := _m.Called(ctx, record)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReleaseIdempotencyKey")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.IdempotencyRecord) error); ok {
+		r0 = rf(ctx, record)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// IdempotencyStore_ReleaseIdempotencyKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReleaseIdempotencyKey'
+type IdempotencyStore_ReleaseIdempotencyKey_Call struct {
+	*mock.Call
+}
+
+// ReleaseIdempotencyKey is a helper method to define mock.On call
+//   - ctx context.Context
+//   - record *domain.IdempotencyRecord
+func (_e *IdempotencyStore_Expecter) ReleaseIdempotencyKey(ctx interface{}, record interface{}) *IdempotencyStore_ReleaseIdempotencyKey_Call {
+	return &IdempotencyStore_ReleaseIdempotencyKey_Call{Call: _e.mock.On("ReleaseIdempotencyKey", ctx, record)}
+}
+
+func (_c *IdempotencyStore_ReleaseIdempotencyKey_Call) Run(run func(ctx context.Context, record *domain.IdempotencyRecord)) *IdempotencyStore_ReleaseIdempotencyKey_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*domain.IdempotencyRecord))
+	})
+	return _c
+}
+
+func (_c *IdempotencyStore_ReleaseIdempotencyKey_Call) Return(_a0 error) *IdempotencyStore_ReleaseIdempotencyKey_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *IdempotencyStore_ReleaseIdempotencyKey_Call) RunAndReturn(run func(context.Context, *domain.IdempotencyRecord) error) *IdempotencyStore_ReleaseIdempotencyKey_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ReserveIdempotencyKey provides a mock function with given fields: ctx, record
+func (_m *IdempotencyStore) ReserveIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+	ret := _m.Called(ctx, record)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReserveIdempotencyKey")
+	}
+
+	var r0 *domain.IdempotencyRecord
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error)); ok {
+		return rf(ctx, record)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.IdempotencyRecord) *domain.IdempotencyRecord); ok {
+		r0 = rf(ctx, record)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.IdempotencyRecord)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *domain.IdempotencyRecord) error); ok {
+		r1 = rf(ctx, record)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IdempotencyStore_ReserveIdempotencyKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReserveIdempotencyKey'
+type IdempotencyStore_ReserveIdempotencyKey_Call struct {
+	*mock.Call
+}
+
+// ReserveIdempotencyKey is a helper method to define mock.On call
+//   - ctx context.Context
+//   - record *domain.IdempotencyRecord
+func (_e *IdempotencyStore_Expecter) ReserveIdempotencyKey(ctx interface{}, record interface{}) *IdempotencyStore_ReserveIdempotencyKey_Call {
+	return &IdempotencyStore_ReserveIdempotencyKey_Call{Call: _e.mock.On("ReserveIdempotencyKey", ctx, record)}
+}
+
+func (_c *IdempotencyStore_ReserveIdempotencyKey_Call) Run(run func(ctx context.Context, record *domain.IdempotencyRecord)) *IdempotencyStore_ReserveIdempotencyKey_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*domain.IdempotencyRecord))
+	})
+	return _c
+}
+
+func (_c *IdempotencyStore_ReserveIdempotencyKey_Call) Return(_a0 *domain.IdempotencyRecord, _a1 error) *IdempotencyStore_ReserveIdempotencyKey_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *IdempotencyStore_ReserveIdempotencyKey_Call) RunAndReturn(run func(context.Context, *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error)) *IdempotencyStore_ReserveIdempotencyKey_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewIdempotencyStore creates a new instance of IdempotencyStore. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIdempotencyStore(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IdempotencyStore {
+	mock := &IdempotencyStore{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -5,6 +5,7 @@ package mocks
 import (
 	context "context"
 
+	domain "github.com/AFK068/compressor/internal/domain"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -21,6 +22,218 @@ func (_m *Repository) EXPECT() *Repository_Expecter {
 	return &Repository_Expecter{mock: &_m.Mock}
 }
 
+// CreateLink provides a mock function with given fields: ctx, link
+func (_m *Repository) CreateLink(ctx context.Context, link *domain.Link) (*domain.Link, error) {
+	ret := _m.Called(ctx, link)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateLink")
+	}
+
+	var r0 *domain.Link
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Link) (*domain.Link, error)); ok {
+		return rf(ctx, link)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Link) *domain.Link); ok {
+		r0 = rf(ctx, link)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Link)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *domain.Link) error); ok {
+		r1 = rf(ctx, link)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_CreateLink_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateLink'
+type Repository_CreateLink_Call struct {
+	*mock.Call
+}
+
+// CreateLink is a helper method to define mock.On call
+//   - ctx context.Context
+//   - link *domain.Link
+func (_e *Repository_Expecter) CreateLink(ctx interface{}, link interface{}) *Repository_CreateLink_Call {
+	return &Repository_CreateLink_Call{Call: _e.mock.On("CreateLink", ctx, link)}
+}
+
+func (_c *Repository_CreateLink_Call) Run(run func(ctx context.Context, link *domain.Link)) *Repository_CreateLink_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*domain.Link))
+	})
+	return _c
+}
+
+func (_c *Repository_CreateLink_Call) Return(_a0 *domain.Link, _a1 error) *Repository_CreateLink_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_CreateLink_Call) RunAndReturn(run func(context.Context, *domain.Link) (*domain.Link, error)) *Repository_CreateLink_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteLink provides a mock function with given fields: ctx, code
+func (_m *Repository) DeleteLink(ctx context.Context, code string) error {
+	ret := _m.Called(ctx, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteLink")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, code)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Repository_DeleteLink_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteLink'
+type Repository_DeleteLink_Call struct {
+	*mock.Call
+}
+
+// DeleteLink is a helper method to define mock.On call
+//   - ctx context.Context
+//   - code string
+func (_e *Repository_Expecter) DeleteLink(ctx interface{}, code interface{}) *Repository_DeleteLink_Call {
+	return &Repository_DeleteLink_Call{Call: _e.mock.On("DeleteLink", ctx, code)}
+}
+
+func (_c *Repository_DeleteLink_Call) Run(run func(ctx context.Context, code string)) *Repository_DeleteLink_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *Repository_DeleteLink_Call) Return(_a0 error) *Repository_DeleteLink_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Repository_DeleteLink_Call) RunAndReturn(run func(context.Context, string) error) *Repository_DeleteLink_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DisableLink provides a mock function with given fields: ctx, code
+func (_m *Repository) DisableLink(ctx context.Context, code string) error {
+	ret := _m.Called(ctx, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DisableLink")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, code)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Repository_DisableLink_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DisableLink'
+type Repository_DisableLink_Call struct {
+	*mock.Call
+}
+
+// DisableLink is a helper method to define mock.On call
+//   - ctx context.Context
+//   - code string
+func (_e *Repository_Expecter) DisableLink(ctx interface{}, code interface{}) *Repository_DisableLink_Call {
+	return &Repository_DisableLink_Call{Call: _e.mock.On("DisableLink", ctx, code)}
+}
+
+func (_c *Repository_DisableLink_Call) Run(run func(ctx context.Context, code string)) *Repository_DisableLink_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *Repository_DisableLink_Call) Return(_a0 error) *Repository_DisableLink_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Repository_DisableLink_Call) RunAndReturn(run func(context.Context, string) error) *Repository_DisableLink_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetLink provides a mock function with given fields: ctx, code
+func (_m *Repository) GetLink(ctx context.Context, code string) (*domain.Link, error) {
+	ret := _m.Called(ctx, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetLink")
+	}
+
+	var r0 *domain.Link
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*domain.Link, error)); ok {
+		return rf(ctx, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *domain.Link); ok {
+		r0 = rf(ctx, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Link)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_GetLink_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetLink'
+type Repository_GetLink_Call struct {
+	*mock.Call
+}
+
+// GetLink is a helper method to define mock.On call
+//   - ctx context.Context
+//   - code string
+func (_e *Repository_Expecter) GetLink(ctx interface{}, code interface{}) *Repository_GetLink_Call {
+	return &Repository_GetLink_Call{Call: _e.mock.On("GetLink", ctx, code)}
+}
+
+func (_c *Repository_GetLink_Call) Run(run func(ctx context.Context, code string)) *Repository_GetLink_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *Repository_GetLink_Call) Return(_a0 *domain.Link, _a1 error) *Repository_GetLink_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_GetLink_Call) RunAndReturn(run func(context.Context, string) (*domain.Link, error)) *Repository_GetLink_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GetURL provides a mock function with given fields: ctx, shortenedURL
 func (_m *Repository) GetURL(ctx context.Context, shortenedURL string) (string, error) {
 	ret := _m.Called(ctx, shortenedURL)
@@ -78,6 +291,183 @@ func (_c *Repository_GetURL_Call) RunAndReturn(run func(context.Context, string)
 	return _c
 }
 
+// ImportLink provides a mock function with given fields: ctx, link
+func (_m *Repository) ImportLink(ctx context.Context, link *domain.Link) (*domain.Link, error) {
+	ret := _m.Called(ctx, link)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ImportLink")
+	}
+
+	var r0 *domain.Link
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Link) (*domain.Link, error)); ok {
+		return rf(ctx, link)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Link) *domain.Link); ok {
+		r0 = rf(ctx, link)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Link)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *domain.Link) error); ok {
+		r1 = rf(ctx, link)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_ImportLink_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ImportLink'
+type Repository_ImportLink_Call struct {
+	*mock.Call
+}
+
+// ImportLink is a helper method to define mock.On call
+//   - ctx context.Context
+//   - link *domain.Link
+func (_e *Repository_Expecter) ImportLink(ctx interface{}, link interface{}) *Repository_ImportLink_Call {
+	return &Repository_ImportLink_Call{Call: _e.mock.On("ImportLink", ctx, link)}
+}
+
+func (_c *Repository_ImportLink_Call) Run(run func(ctx context.Context, link *domain.Link)) *Repository_ImportLink_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*domain.Link))
+	})
+	return _c
+}
+
+func (_c *Repository_ImportLink_Call) Return(_a0 *domain.Link, _a1 error) *Repository_ImportLink_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_ImportLink_Call) RunAndReturn(run func(context.Context, *domain.Link) (*domain.Link, error)) *Repository_ImportLink_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListLinks provides a mock function with given fields: ctx, params
+func (_m *Repository) ListLinks(ctx context.Context, params domain.ListLinksParams) ([]*domain.Link, error) {
+	ret := _m.Called(ctx, params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListLinks")
+	}
+
+	var r0 []*domain.Link
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.ListLinksParams) ([]*domain.Link, error)); ok {
+		return rf(ctx, params)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.ListLinksParams) []*domain.Link); ok {
+		r0 = rf(ctx, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*domain.Link)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.ListLinksParams) error); ok {
+		r1 = rf(ctx, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_ListLinks_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListLinks'
+type Repository_ListLinks_Call struct {
+	*mock.Call
+}
+
+// ListLinks is a helper method to define mock.On call
+//   - ctx context.Context
+//   - params domain.ListLinksParams
+func (_e *Repository_Expecter) ListLinks(ctx interface{}, params interface{}) *Repository_ListLinks_Call {
+	return &Repository_ListLinks_Call{Call: _e.mock.On("ListLinks", ctx, params)}
+}
+
+func (_c *Repository_ListLinks_Call) Run(run func(ctx context.Context, params domain.ListLinksParams)) *Repository_ListLinks_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.ListLinksParams))
+	})
+	return _c
+}
+
+func (_c *Repository_ListLinks_Call) Return(_a0 []*domain.Link, _a1 error) *Repository_ListLinks_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_ListLinks_Call) RunAndReturn(run func(context.Context, domain.ListLinksParams) ([]*domain.Link, error)) *Repository_ListLinks_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ResolveLink provides a mock function with given fields: ctx, code
+func (_m *Repository) ResolveLink(ctx context.Context, code string) (*domain.Link, error) {
+	ret := _m.Called(ctx, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResolveLink")
+	}
+
+	var r0 *domain.Link
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*domain.Link, error)); ok {
+		return rf(ctx, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *domain.Link); ok {
+		r0 = rf(ctx, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Link)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_ResolveLink_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ResolveLink'
+type Repository_ResolveLink_Call struct {
+	*mock.Call
+}
+
+// ResolveLink is a helper method to define mock.On call
+//   - ctx context.Context
+//   - code string
+func (_e *Repository_Expecter) ResolveLink(ctx interface{}, code interface{}) *Repository_ResolveLink_Call {
+	return &Repository_ResolveLink_Call{Call: _e.mock.On("ResolveLink", ctx, code)}
+}
+
+func (_c *Repository_ResolveLink_Call) Run(run func(ctx context.Context, code string)) *Repository_ResolveLink_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *Repository_ResolveLink_Call) Return(_a0 *domain.Link, _a1 error) *Repository_ResolveLink_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_ResolveLink_Call) RunAndReturn(run func(context.Context, string) (*domain.Link, error)) *Repository_ResolveLink_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // SaveURL provides a mock function with given fields: ctx, originalURL
 func (_m *Repository) SaveURL(ctx context.Context, originalURL string) (string, error) {
 	ret := _m.Called(ctx, originalURL)
@@ -135,6 +525,64 @@ func (_c *Repository_SaveURL_Call) RunAndReturn(run func(context.Context, string
 	return _c
 }
 
+// Stats provides a mock function with given fields: ctx
+func (_m *Repository) Stats(ctx context.Context) (*domain.Stats, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Stats")
+	}
+
+	var r0 *domain.Stats
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (*domain.Stats, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) *domain.Stats); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.Stats)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_Stats_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Stats'
+type Repository_Stats_Call struct {
+	*mock.Call
+}
+
+// Stats is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *Repository_Expecter) Stats(ctx interface{}) *Repository_Stats_Call {
+	return &Repository_Stats_Call{Call: _e.mock.On("Stats", ctx)}
+}
+
+func (_c *Repository_Stats_Call) Run(run func(ctx context.Context)) *Repository_Stats_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *Repository_Stats_Call) Return(_a0 *domain.Stats, _a1 error) *Repository_Stats_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_Stats_Call) RunAndReturn(run func(context.Context) (*domain.Stats, error)) *Repository_Stats_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewRepository creates a new instance of Repository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewRepository(t interface {
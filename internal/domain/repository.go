@@ -7,9 +7,30 @@ type RepositoryType string
 const (
 	PostgresRepository RepositoryType = "postgres"
 	InMemoryRepository RepositoryType = "inmemory"
+	DynamoDBRepository RepositoryType = "dynamodb"
 )
 
 type Repository interface {
 	SaveURL(ctx context.Context, originalURL string) (string, error)
 	GetURL(ctx context.Context, shortenedURL string) (string, error)
+
+	// CreateLink stores link.Destination together with its expiry and owner and returns
+	// the stored link. An already shortened destination returns the existing link.
+	CreateLink(ctx context.Context, link *Link) (*Link, error)
+	GetLink(ctx context.Context, code string) (*Link, error)
+	// ResolveLink returns the link like GetLink, but may read it from a replica that lags behind
+	// the latest writes. It serves the lookups of clients, GetLink the reads that follow a write.
+	ResolveLink(ctx context.Context, code string) (*Link, error)
+	DeleteLink(ctx context.Context, code string) error
+	// ImportLink stores link under its own code, keeping its creation time and metadata, and makes
+	// sure later links get higher ids. A taken code returns ErrLinkConflict. A destination that is
+	// already shortened keeps being shortened as its existing link, and link only resolves by its
+	// code, unless link is resolvable and the existing one is disabled or expired.
+	ImportLink(ctx context.Context, link *Link) (*Link, error)
+
+	// ListLinks returns up to params.Limit links ordered by id, starting at params.FromID.
+	ListLinks(ctx context.Context, params ListLinksParams) ([]*Link, error)
+	// DisableLink keeps the link but stops it from resolving.
+	DisableLink(ctx context.Context, code string) error
+	Stats(ctx context.Context) (*Stats, error)
 }
@@ -0,0 +1,12 @@
+package domain
+
+import "context"
+
+// Transactor runs several repository operations as one unit of work, e.g. creating a link and
+// writing an audit record for it.
+type Transactor interface {
+	// WithTransaction runs fn in a transaction. The repository calls made with the context passed
+	// to fn are committed together when fn returns nil and rolled back otherwise. Backends that
+	// cannot roll back their operations fail with apperrors.ErrNotSupported without running fn.
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
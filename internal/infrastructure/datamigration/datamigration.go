@@ -0,0 +1,345 @@
+// Package datamigration copies the links of one repository into another, e.g. from the postgres
+// storage to dynamodb. Links keep their ids and codes, since ids are decoded from the codes.
+//
+// Links are copied in id order, one page at a time, and the progress is saved in a checkpoint after
+// every page, so an interrupted migration resumes where it stopped. Links created in the source
+// while migrating get higher ids and are copied by the next run; links deleted or disabled in the
+// source after they were copied are not.
+package datamigration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/domain/apperrors"
+	"github.com/AFK068/compressor/pkg/shortener"
+)
+
+const DefaultPageSize = 1000
+
+// LinkError describes a link that was skipped or that differs between the repositories.
+type LinkError struct {
+	ID   uint64
+	Code string
+	Err  error
+}
+
+func (e *LinkError) Error() string {
+	return fmt.Sprintf("link %d (%s): %v", e.ID, e.Code, e.Err)
+}
+
+func (e *LinkError) Unwrap() error {
+	return e.Err
+}
+
+// Checkpoint is the progress of a migration.
+type Checkpoint struct {
+	// NextID is the smallest id of the source that was not copied yet.
+	NextID uint64 `json:"next_id"`
+	// Migrated counts the links copied, Present the ones the destination already held unchanged,
+	// e.g. because the previous run stopped before saving its checkpoint, and Failed the skipped ones.
+	Migrated  int       `json:"migrated"`
+	Present   int       `json:"present"`
+	Failed    int       `json:"failed"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// VerifyReport compares the repositories after a migration.
+type VerifyReport struct {
+	Source      *domain.Stats
+	Destination *domain.Stats
+	// Sampled links of the source were looked up in the destination, Mismatches lists the ones
+	// that are missing or differ.
+	Sampled    int
+	Mismatches []*LinkError
+}
+
+// OK reports whether the destination holds as many links as the source and every sampled link.
+func (r *VerifyReport) OK() bool {
+	return r.Source.Total == r.Destination.Total &&
+		r.Source.Disabled == r.Destination.Disabled &&
+		len(r.Mismatches) == 0
+}
+
+type Migrator struct {
+	source      domain.Repository
+	destination domain.Repository
+	pageSize    int
+	// checkpointFile keeps the checkpoint across runs, it is only kept in memory when empty.
+	checkpointFile string
+}
+
+type Option func(*Migrator)
+
+func WithPageSize(size int) Option {
+	return func(m *Migrator) {
+		m.pageSize = size
+	}
+}
+
+func WithCheckpointFile(path string) Option {
+	return func(m *Migrator) {
+		m.checkpointFile = path
+	}
+}
+
+func New(source, destination domain.Repository, opts ...Option) *Migrator {
+	m := &Migrator{source: source, destination: destination, pageSize: DefaultPageSize}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Run copies the links of the source not copied yet. Links that cannot be copied, e.g. because
+// the destination holds another link under their code or destination, are passed to onError and
+// skipped; any other error, e.g. a full destination, stops the migration and returns the last
+// checkpoint saved.
+func (m *Migrator) Run(ctx context.Context, onError func(*LinkError)) (*Checkpoint, error) {
+	checkpoint, err := m.loadCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	params := domain.ListLinksParams{FromID: checkpoint.NextID, Limit: m.pageSize}
+
+	for {
+		page, err := m.source.ListLinks(ctx, params)
+		if err != nil {
+			return checkpoint, fmt.Errorf("listing links from %d: %w", params.FromID, err)
+		}
+
+		next := *checkpoint
+
+		for _, link := range page {
+			present, err := m.copy(ctx, link)
+
+			var linkErr *LinkError
+
+			switch {
+			case err == nil && present:
+				next.Present++
+			case err == nil:
+				next.Migrated++
+			case errors.As(err, &linkErr):
+				next.Failed++
+
+				onError(linkErr)
+			default:
+				return checkpoint, fmt.Errorf("copying link %d: %w", link.ID, err)
+			}
+
+			next.NextID = link.ID + 1
+		}
+
+		if len(page) > 0 {
+			if err := m.saveCheckpoint(&next); err != nil {
+				return checkpoint, err
+			}
+
+			checkpoint = &next
+		}
+
+		if len(page) < params.Limit {
+			return checkpoint, nil
+		}
+
+		params.FromID = checkpoint.NextID
+	}
+}
+
+// copy imports link into the destination and reports whether the destination already held it.
+func (m *Migrator) copy(ctx context.Context, link *domain.Link) (bool, error) {
+	imported, err := m.destination.ImportLink(ctx, link)
+
+	var errLinkConflict *apperrors.ErrLinkConflict
+
+	switch {
+	case err == nil && imported.ID != link.ID:
+		return false, &LinkError{
+			ID:   link.ID,
+			Code: link.Code,
+			Err:  fmt.Errorf("stored under id %d, the repositories use different shorteners", imported.ID),
+		}
+	case err == nil:
+		return false, nil
+	case errors.As(err, &errLinkConflict):
+		existing, getErr := m.destination.GetLink(ctx, link.Code)
+		if getErr == nil && sameLink(link, existing) {
+			return true, nil
+		}
+
+		return false, &LinkError{ID: link.ID, Code: link.Code, Err: err}
+	case isLinkError(err):
+		return false, &LinkError{ID: link.ID, Code: link.Code, Err: err}
+	default:
+		return false, err
+	}
+}
+
+// Verify compares the statistics of both repositories and looks up sample random links of the
+// source in the destination. The destination is expected to hold nothing but the migrated links.
+func (m *Migrator) Verify(ctx context.Context, sample int) (*VerifyReport, error) {
+	report := &VerifyReport{}
+
+	var err error
+
+	if report.Source, err = m.source.Stats(ctx); err != nil {
+		return nil, fmt.Errorf("reading source stats: %w", err)
+	}
+
+	if report.Destination, err = m.destination.Stats(ctx); err != nil {
+		return nil, fmt.Errorf("reading destination stats: %w", err)
+	}
+
+	links, err := m.sample(ctx, sample)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, link := range links {
+		existing, err := m.destination.GetLink(ctx, link.Code)
+
+		var errURLNotFound *apperrors.ErrURLNotFound
+
+		switch {
+		case errors.As(err, &errURLNotFound):
+			report.Mismatches = append(report.Mismatches, &LinkError{ID: link.ID, Code: link.Code, Err: err})
+		case err != nil:
+			return nil, fmt.Errorf("looking up %s: %w", link.Code, err)
+		case !sameLink(link, existing):
+			report.Mismatches = append(report.Mismatches, &LinkError{
+				ID:   link.ID,
+				Code: link.Code,
+				Err:  errors.New("differs in the destination"),
+			})
+		}
+	}
+
+	report.Sampled = len(links)
+
+	return report, nil
+}
+
+// sample picks up to n links of the source uniformly at random, reading the source once.
+func (m *Migrator) sample(ctx context.Context, n int) ([]*domain.Link, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	sampled := make([]*domain.Link, 0, n)
+	seen := 0
+	params := domain.ListLinksParams{Limit: m.pageSize}
+
+	for {
+		page, err := m.source.ListLinks(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("listing links from %d: %w", params.FromID, err)
+		}
+
+		for _, link := range page {
+			seen++
+
+			if len(sampled) < n {
+				sampled = append(sampled, link)
+			} else if i := rand.IntN(seen); i < n {
+				sampled[i] = link
+			}
+		}
+
+		if len(page) < params.Limit {
+			return sampled, nil
+		}
+
+		params.FromID = page[len(page)-1].ID + 1
+	}
+}
+
+func (m *Migrator) loadCheckpoint() (*Checkpoint, error) {
+	checkpoint := &Checkpoint{}
+	if m.checkpointFile == "" {
+		return checkpoint, nil
+	}
+
+	data, err := os.ReadFile(m.checkpointFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading checkpoint: %w", err)
+	}
+
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, fmt.Errorf("parsing checkpoint %s: %w", m.checkpointFile, err)
+	}
+
+	return checkpoint, nil
+}
+
+// saveCheckpoint replaces the checkpoint file at once, so it is never left half written.
+func (m *Migrator) saveCheckpoint(checkpoint *Checkpoint) error {
+	checkpoint.UpdatedAt = time.Now().UTC()
+
+	if m.checkpointFile == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(m.checkpointFile), filepath.Base(m.checkpointFile)+".*")
+	if err != nil {
+		return fmt.Errorf("saving checkpoint: %w", err)
+	}
+
+	_, err = file.Write(data)
+	if err = errors.Join(err, file.Close()); err == nil {
+		err = os.Rename(file.Name(), m.checkpointFile)
+	}
+
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("saving checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// sameLink compares the stored fields of two links. Times are compared to the microsecond, the
+// precision of the postgres storage.
+func sameLink(a, b *domain.Link) bool {
+	return a.ID == b.ID &&
+		a.Code == b.Code &&
+		a.Destination == b.Destination &&
+		a.Owner == b.Owner &&
+		a.Disabled == b.Disabled &&
+		sameTime(&a.CreatedAt, &b.CreatedAt) &&
+		sameTime(a.ExpiresAt, b.ExpiresAt)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Truncate(time.Microsecond).Equal(b.Truncate(time.Microsecond))
+}
+
+// isLinkError reports whether err concerns the link alone, so the migration goes on without it. A
+// full destination is not one of them, since it refuses the links that follow as well.
+func isLinkError(err error) bool {
+	return errors.Is(err, shortener.ErrInvalidDecoderLength) ||
+		errors.Is(err, shortener.ErrInvalidCharacter)
+}
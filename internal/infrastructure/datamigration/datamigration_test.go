@@ -0,0 +1,236 @@
+package datamigration_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/domain/apperrors"
+	"github.com/AFK068/compressor/internal/infrastructure/datamigration"
+	"github.com/AFK068/compressor/internal/infrastructure/repository/inmemoryrepo"
+	"github.com/AFK068/compressor/pkg/shortener"
+	"github.com/stretchr/testify/assert"
+)
+
+var errImportFailed = errors.New("import failed")
+
+func newRepository(t *testing.T) *inmemoryrepo.InMemoryRepository {
+	s, err := shortener.NewShortener("abcdefgh", 4)
+	assert.NoError(t, err)
+
+	return inmemoryrepo.New(s, 100)
+}
+
+func createLinks(ctx context.Context, t *testing.T, repo domain.Repository, from, to int) {
+	for i := from; i < to; i++ {
+		_, err := repo.CreateLink(ctx, &domain.Link{Destination: fmt.Sprintf("http://example.com/%d", i)})
+		assert.NoError(t, err)
+	}
+}
+
+func failOnLinkError(t *testing.T) func(*datamigration.LinkError) {
+	return func(linkErr *datamigration.LinkError) {
+		t.Errorf("unexpected link error: %v", linkErr)
+	}
+}
+
+// failingRepository fails every import after the first imports ones.
+type failingRepository struct {
+	domain.Repository
+	imports int
+}
+
+func (r *failingRepository) ImportLink(ctx context.Context, link *domain.Link) (*domain.Link, error) {
+	if r.imports == 0 {
+		return nil, errImportFailed
+	}
+
+	r.imports--
+
+	return r.Repository.ImportLink(ctx, link)
+}
+
+func Test_Run_CopiesLinks_Success(t *testing.T) {
+	ctx := context.Background()
+	source := newRepository(t)
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	createLinks(ctx, t, source, 0, 5)
+
+	expiring, err := source.CreateLink(ctx, &domain.Link{Destination: "http://example.com/expiring", ExpiresAt: &expiresAt, Owner: "team-a"})
+	assert.NoError(t, err)
+	assert.NoError(t, source.DisableLink(ctx, expiring.Code))
+
+	deleted, err := source.SaveURL(ctx, "http://example.com/deleted")
+	assert.NoError(t, err)
+	assert.NoError(t, source.DeleteLink(ctx, deleted))
+
+	destination := newRepository(t)
+
+	checkpoint, err := datamigration.New(source, destination, datamigration.WithPageSize(2)).Run(ctx, failOnLinkError(t))
+	assert.NoError(t, err)
+	assert.Equal(t, 6, checkpoint.Migrated)
+	assert.Equal(t, expiring.ID+1, checkpoint.NextID)
+
+	want, err := source.ListLinks(ctx, domain.ListLinksParams{Limit: 100})
+	assert.NoError(t, err)
+
+	got, err := destination.ListLinks(ctx, domain.ListLinksParams{Limit: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// New links do not take the ids of the migrated ones.
+	created, err := destination.CreateLink(ctx, &domain.Link{Destination: "http://example.com/new"})
+	assert.NoError(t, err)
+	assert.Greater(t, created.ID, expiring.ID)
+}
+
+func Test_Run_DisabledAndReplacement_Success(t *testing.T) {
+	ctx := context.Background()
+	source := newRepository(t)
+
+	disabled, err := source.CreateLink(ctx, &domain.Link{Destination: "http://example.com/1"})
+	assert.NoError(t, err)
+	assert.NoError(t, source.DisableLink(ctx, disabled.Code))
+
+	replacement, err := source.CreateLink(ctx, &domain.Link{Destination: "http://example.com/1"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, disabled.ID, replacement.ID)
+
+	destination := newRepository(t)
+
+	checkpoint, err := datamigration.New(source, destination).Run(ctx, failOnLinkError(t))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, checkpoint.Migrated)
+
+	want, err := source.ListLinks(ctx, domain.ListLinksParams{Limit: 100})
+	assert.NoError(t, err)
+
+	got, err := destination.ListLinks(ctx, domain.ListLinksParams{Limit: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// The destination is still shortened as the replacement.
+	link, err := destination.CreateLink(ctx, &domain.Link{Destination: "http://example.com/1"})
+	assert.NoError(t, err)
+	assert.Equal(t, replacement.Code, link.Code)
+}
+
+func Test_Run_ResumesFromCheckpoint_Success(t *testing.T) {
+	ctx := context.Background()
+	source := newRepository(t)
+	destination := newRepository(t)
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	createLinks(ctx, t, source, 0, 10)
+
+	// The third page stops after one of its links was copied.
+	interrupted := datamigration.New(source, &failingRepository{Repository: destination, imports: 7},
+		datamigration.WithPageSize(3), datamigration.WithCheckpointFile(checkpointFile))
+
+	checkpoint, err := interrupted.Run(ctx, failOnLinkError(t))
+	assert.ErrorIs(t, err, errImportFailed)
+	assert.Equal(t, &datamigration.Checkpoint{NextID: 6, Migrated: 6, UpdatedAt: checkpoint.UpdatedAt}, checkpoint)
+
+	createLinks(ctx, t, source, 10, 12)
+
+	migrator := datamigration.New(source, destination,
+		datamigration.WithPageSize(3), datamigration.WithCheckpointFile(checkpointFile))
+
+	checkpoint, err = migrator.Run(ctx, failOnLinkError(t))
+	assert.NoError(t, err)
+	assert.Equal(t, &datamigration.Checkpoint{NextID: 12, Migrated: 11, Present: 1, UpdatedAt: checkpoint.UpdatedAt}, checkpoint)
+
+	report, err := migrator.Verify(ctx, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, report.Sampled)
+	assert.True(t, report.OK())
+
+	// Nothing is left to copy.
+	again, err := migrator.Run(ctx, failOnLinkError(t))
+	assert.NoError(t, err)
+	assert.Equal(t, checkpoint, again)
+}
+
+func Test_Run_Conflict_Failure(t *testing.T) {
+	ctx := context.Background()
+	source := newRepository(t)
+	destination := newRepository(t)
+
+	createLinks(ctx, t, source, 0, 3)
+
+	// The destination holds another destination under the code of the second link.
+	_, err := destination.ImportLink(ctx, &domain.Link{Code: "aaab", Destination: "http://example.com/other"})
+	assert.NoError(t, err)
+
+	var linkErrors []*datamigration.LinkError
+
+	checkpoint, err := datamigration.New(source, destination).Run(ctx, func(linkErr *datamigration.LinkError) {
+		linkErrors = append(linkErrors, linkErr)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, checkpoint.Migrated)
+	assert.Equal(t, 1, checkpoint.Failed)
+
+	var errLinkConflict *apperrors.ErrLinkConflict
+
+	assert.Len(t, linkErrors, 1)
+	assert.Equal(t, "aaab", linkErrors[0].Code)
+	assert.ErrorAs(t, linkErrors[0], &errLinkConflict)
+}
+
+func Test_Run_DestinationFull_Failure(t *testing.T) {
+	ctx := context.Background()
+	source := newRepository(t)
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	s, err := shortener.NewShortener("abcdefgh", 4)
+	assert.NoError(t, err)
+
+	createLinks(ctx, t, source, 0, 5)
+
+	// The destination only has room for the first three links.
+	destination := inmemoryrepo.New(s, 3)
+
+	checkpoint, err := datamigration.New(source, destination,
+		datamigration.WithPageSize(2), datamigration.WithCheckpointFile(checkpointFile)).Run(ctx, failOnLinkError(t))
+
+	var errRepositoryIsFull *apperrors.ErrRepositoryIsFull
+
+	assert.ErrorAs(t, err, &errRepositoryIsFull)
+	assert.Equal(t, &datamigration.Checkpoint{NextID: 2, Migrated: 2, UpdatedAt: checkpoint.UpdatedAt}, checkpoint)
+}
+
+func Test_Verify_Mismatch_Failure(t *testing.T) {
+	ctx := context.Background()
+	source := newRepository(t)
+	destination := newRepository(t)
+
+	createLinks(ctx, t, source, 0, 4)
+
+	migrator := datamigration.New(source, destination)
+
+	_, err := migrator.Run(ctx, failOnLinkError(t))
+	assert.NoError(t, err)
+
+	assert.NoError(t, destination.DisableLink(ctx, "aaac"))
+	assert.NoError(t, destination.DeleteLink(ctx, "aaad"))
+
+	report, err := migrator.Verify(ctx, 4)
+	assert.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Equal(t, uint64(4), report.Source.Total)
+	assert.Equal(t, uint64(3), report.Destination.Total)
+	assert.Equal(t, 4, report.Sampled)
+
+	codes := make([]string, 0, len(report.Mismatches))
+	for _, mismatch := range report.Mismatches {
+		codes = append(codes, mismatch.Code)
+	}
+
+	assert.ElementsMatch(t, []string{"aaac", "aaad"}, codes)
+}
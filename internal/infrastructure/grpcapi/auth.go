@@ -0,0 +1,48 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	compressorv1 "github.com/AFK068/compressor/internal/api/grpc/compressor/v1"
+)
+
+// adminMethods can delete links or create many at once, so like the /admin HTTP API they require
+// the admin token.
+var adminMethods = map[string]bool{
+	compressorv1.CompressorService_BatchShorten_FullMethodName: true,
+	compressorv1.CompressorService_Delete_FullMethodName:       true,
+}
+
+// AdminAuth rejects calls of the admin methods that do not carry token as a bearer token in the
+// authorization metadata. When token is empty the admin methods are refused, like the /admin HTTP
+// API is not served.
+func AdminAuth(token string) grpc.UnaryServerInterceptor {
+	expected := []byte("Bearer " + token)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !adminMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		if token == "" {
+			return nil, status.Error(codes.PermissionDenied, "admin methods are disabled, set admin.token to enable them")
+		}
+
+		var provided []byte
+		if values := metadata.ValueFromIncomingContext(ctx, "authorization"); len(values) == 1 {
+			provided = []byte(values[0])
+		}
+
+		if subtle.ConstantTimeCompare(provided, expected) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "missing or invalid admin token")
+		}
+
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,199 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/domain/apperrors"
+	"github.com/AFK068/compressor/pkg/shortener"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	compressorv1 "github.com/AFK068/compressor/internal/api/grpc/compressor/v1"
+)
+
+const (
+	MaxBatchSize = 1000
+)
+
+type Service struct {
+	compressorv1.UnimplementedCompressorServiceServer
+
+	repository domain.Repository
+	baseURL    atomic.Pointer[string]
+	logger     *zap.Logger
+}
+
+func NewService(repository domain.Repository, cfg *config.Config, logger *zap.Logger) *Service {
+	s := &Service{
+		repository: repository,
+		logger:     logger,
+	}
+
+	s.Reconfigure(cfg)
+
+	return s
+}
+
+// Reconfigure applies the public base URL of cfg to the links returned from now on.
+func (s *Service) Reconfigure(cfg *config.Config) {
+	baseURL := strings.TrimRight(cfg.Shortener.PublicBaseURL, "/")
+	s.baseURL.Store(&baseURL)
+}
+
+func (s *Service) Shorten(ctx context.Context, req *compressorv1.ShortenRequest) (*compressorv1.ShortenResponse, error) {
+	s.logger.Info("Shorten request received")
+
+	link, err := s.shorten(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &compressorv1.ShortenResponse{Link: link}, nil
+}
+
+func (s *Service) Resolve(ctx context.Context, req *compressorv1.ResolveRequest) (*compressorv1.ResolveResponse, error) {
+	s.logger.Info("Resolve request received", zap.String("code", req.GetCode()))
+
+	link, err := s.repository.ResolveLink(ctx, req.GetCode())
+	if err != nil {
+		s.logger.Error("Failed to resolve link", zap.Error(err))
+		return nil, toStatusError(err)
+	}
+
+	if !link.IsResolvable(time.Now()) {
+		return nil, status.Errorf(codes.NotFound, "link %q is disabled or has expired", req.GetCode())
+	}
+
+	return &compressorv1.ResolveResponse{Link: s.toProtoLink(link)}, nil
+}
+
+func (s *Service) BatchShorten(
+	ctx context.Context,
+	req *compressorv1.BatchShortenRequest,
+) (*compressorv1.BatchShortenResponse, error) {
+	s.logger.Info("Batch shorten request received", zap.Int("size", len(req.GetRequests())))
+
+	if len(req.GetRequests()) > MaxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "batch size %d exceeds the limit of %d", len(req.GetRequests()), MaxBatchSize)
+	}
+
+	results := make([]*compressorv1.BatchShortenResult, 0, len(req.GetRequests()))
+
+	for _, item := range req.GetRequests() {
+		link, err := s.shorten(ctx, item)
+		if err != nil {
+			st := status.Convert(err)
+
+			results = append(results, &compressorv1.BatchShortenResult{
+				Result: &compressorv1.BatchShortenResult_Error{Error: &compressorv1.BatchShortenError{
+					Code:    uint32(st.Code()),
+					Message: st.Message(),
+				}},
+			})
+
+			continue
+		}
+
+		results = append(results, &compressorv1.BatchShortenResult{
+			Result: &compressorv1.BatchShortenResult_Link{Link: link},
+		})
+	}
+
+	return &compressorv1.BatchShortenResponse{Results: results}, nil
+}
+
+func (s *Service) Delete(ctx context.Context, req *compressorv1.DeleteRequest) (*compressorv1.DeleteResponse, error) {
+	s.logger.Info("Delete request received", zap.String("code", req.GetCode()))
+
+	if err := s.repository.DeleteLink(ctx, req.GetCode()); err != nil {
+		s.logger.Error("Failed to delete link", zap.Error(err))
+		return nil, toStatusError(err)
+	}
+
+	return &compressorv1.DeleteResponse{}, nil
+}
+
+func (s *Service) shorten(ctx context.Context, req *compressorv1.ShortenRequest) (*compressorv1.Link, error) {
+	if !isAbsoluteURL(req.GetDestination()) {
+		return nil, status.Errorf(codes.InvalidArgument, "destination %q is not an absolute URL", req.GetDestination())
+	}
+
+	link := &domain.Link{
+		Destination: req.GetDestination(),
+		Owner:       req.GetOwner(),
+	}
+
+	if req.GetExpiresAt() != nil {
+		expiresAt := req.GetExpiresAt().AsTime()
+		if !expiresAt.After(time.Now()) {
+			return nil, status.Error(codes.InvalidArgument, "expiry must be in the future")
+		}
+
+		link.ExpiresAt = &expiresAt
+	}
+
+	created, err := s.repository.CreateLink(ctx, link)
+	if err != nil {
+		s.logger.Error("Failed to create link", zap.Error(err))
+		return nil, toStatusError(err)
+	}
+
+	return s.toProtoLink(created), nil
+}
+
+func (s *Service) toProtoLink(link *domain.Link) *compressorv1.Link {
+	result := &compressorv1.Link{
+		Code:        link.Code,
+		Destination: link.Destination,
+		CreatedAt:   timestamppb.New(link.CreatedAt),
+		Owner:       link.Owner,
+		Disabled:    link.Disabled,
+	}
+
+	if baseURL := *s.baseURL.Load(); baseURL != "" {
+		result.ShortUrl = baseURL + "/" + link.Code
+	}
+
+	if link.ExpiresAt != nil {
+		result.ExpiresAt = timestamppb.New(*link.ExpiresAt)
+	}
+
+	return result
+}
+
+func toStatusError(err error) error {
+	var (
+		errURLNotFound      *apperrors.ErrURLNotFound
+		errRepositoryIsFull *apperrors.ErrRepositoryIsFull
+	)
+
+	switch {
+	case errors.As(err, &errURLNotFound):
+		return status.Error(codes.NotFound, "link not found")
+	case errors.As(err, &errRepositoryIsFull):
+		return status.Error(codes.ResourceExhausted, "repository is full")
+	case errors.Is(err, shortener.ErrInvalidDecoderLength), errors.Is(err, shortener.ErrInvalidCharacter):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func isAbsoluteURL(raw string) bool {
+	u, err := url.ParseRequestURI(raw)
+
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
@@ -0,0 +1,254 @@
+package grpcapi_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/domain/apperrors"
+	"github.com/AFK068/compressor/internal/infrastructure/grpcapi"
+	"github.com/AFK068/compressor/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	compressorv1 "github.com/AFK068/compressor/internal/api/grpc/compressor/v1"
+	repomock "github.com/AFK068/compressor/internal/domain/mocks"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	bufSize    = 1024 * 1024
+	adminToken = "secret"
+)
+
+func setupClient(t *testing.T, repo domain.Repository) *grpc.ClientConn {
+	return setupClientWithConfig(t, repo, &config.Config{
+		Shortener: config.Shortener{PublicBaseURL: "https://sho.rt/"},
+		Admin:     config.Admin{Token: adminToken},
+	})
+}
+
+func setupClientWithConfig(t *testing.T, repo domain.Repository, cfg *config.Config) *grpc.ClientConn {
+	srv := server.NewGRPC(cfg, grpcapi.NewService(repo, cfg, zap.NewNop()), zap.NewNop())
+	listener := bufconn.Listen(bufSize)
+
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, conn.Close())
+		srv.Stop()
+	})
+
+	return conn
+}
+
+// adminContext carries the admin token, which the admin methods require.
+func adminContext() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+adminToken)
+}
+
+func Test_Shorten_Success(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	repoMock.On("CreateLink", mock.Anything, &domain.Link{Destination: "http://example.com", Owner: "team-a"}).
+		Return(&domain.Link{ID: 4, Code: "aaabb", Destination: "http://example.com", CreatedAt: createdAt, Owner: "team-a"}, nil)
+
+	client := compressorv1.NewCompressorServiceClient(setupClient(t, repoMock))
+
+	resp, err := client.Shorten(context.Background(), &compressorv1.ShortenRequest{
+		Destination: "http://example.com",
+		Owner:       "team-a",
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "aaabb", resp.GetLink().GetCode())
+	assert.Equal(t, "https://sho.rt/aaabb", resp.GetLink().GetShortUrl())
+	assert.Equal(t, createdAt, resp.GetLink().GetCreatedAt().AsTime())
+	assert.Nil(t, resp.GetLink().GetExpiresAt())
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Shorten_InvalidDestination_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	client := compressorv1.NewCompressorServiceClient(setupClient(t, repoMock))
+
+	_, err := client.Shorten(context.Background(), &compressorv1.ShortenRequest{Destination: "example.com"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Resolve_Success(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	repoMock.On("ResolveLink", mock.Anything, "aaabb").
+		Return(&domain.Link{ID: 4, Code: "aaabb", Destination: "http://example.com"}, nil)
+
+	client := compressorv1.NewCompressorServiceClient(setupClient(t, repoMock))
+
+	resp, err := client.Resolve(context.Background(), &compressorv1.ResolveRequest{Code: "aaabb"})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com", resp.GetLink().GetDestination())
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Resolve_Expired_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	expiresAt := time.Now().Add(-time.Minute)
+
+	repoMock.On("ResolveLink", mock.Anything, "aaabb").
+		Return(&domain.Link{ID: 4, Code: "aaabb", Destination: "http://example.com", ExpiresAt: &expiresAt}, nil)
+
+	client := compressorv1.NewCompressorServiceClient(setupClient(t, repoMock))
+
+	_, err := client.Resolve(context.Background(), &compressorv1.ResolveRequest{Code: "aaabb"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Resolve_NotFound_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	repoMock.On("ResolveLink", mock.Anything, "aaabb").Return(nil, &apperrors.ErrURLNotFound{Message: "url not found"})
+
+	client := compressorv1.NewCompressorServiceClient(setupClient(t, repoMock))
+
+	_, err := client.Resolve(context.Background(), &compressorv1.ResolveRequest{Code: "aaabb"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_BatchShorten_PartialFailure_Success(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	expiresAt := time.Now().Add(time.Hour)
+
+	repoMock.On("CreateLink", mock.Anything, mock.MatchedBy(func(link *domain.Link) bool {
+		return link.Destination == "http://example.com/1" && link.ExpiresAt.Equal(expiresAt)
+	})).Return(&domain.Link{ID: 0, Code: "aaaaa", Destination: "http://example.com/1"}, nil)
+	repoMock.On("CreateLink", mock.Anything, &domain.Link{Destination: "http://example.com/3"}).
+		Return(nil, &apperrors.ErrRepositoryIsFull{Message: "repository is full"})
+
+	client := compressorv1.NewCompressorServiceClient(setupClient(t, repoMock))
+
+	resp, err := client.BatchShorten(adminContext(), &compressorv1.BatchShortenRequest{
+		Requests: []*compressorv1.ShortenRequest{
+			{Destination: "http://example.com/1", ExpiresAt: timestamppb.New(expiresAt)},
+			{Destination: "not a url"},
+			{Destination: "http://example.com/3"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetResults(), 3)
+
+	assert.Equal(t, "aaaaa", resp.GetResults()[0].GetLink().GetCode())
+	assert.Equal(t, uint32(codes.InvalidArgument), resp.GetResults()[1].GetError().GetCode())
+	assert.Equal(t, uint32(codes.ResourceExhausted), resp.GetResults()[2].GetError().GetCode())
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_BatchShorten_TooLarge_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	client := compressorv1.NewCompressorServiceClient(setupClient(t, repoMock))
+
+	requests := make([]*compressorv1.ShortenRequest, grpcapi.MaxBatchSize+1)
+	for i := range requests {
+		requests[i] = &compressorv1.ShortenRequest{}
+	}
+
+	_, err := client.BatchShorten(adminContext(), &compressorv1.BatchShortenRequest{Requests: requests})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Delete_Success(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	repoMock.On("DeleteLink", mock.Anything, "aaabb").Return(nil)
+
+	client := compressorv1.NewCompressorServiceClient(setupClient(t, repoMock))
+
+	_, err := client.Delete(adminContext(), &compressorv1.DeleteRequest{Code: "aaabb"})
+	assert.NoError(t, err)
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Delete_NotFound_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	repoMock.On("DeleteLink", mock.Anything, "aaabb").Return(&apperrors.ErrURLNotFound{Message: "url not found"})
+
+	client := compressorv1.NewCompressorServiceClient(setupClient(t, repoMock))
+
+	_, err := client.Delete(adminContext(), &compressorv1.DeleteRequest{Code: "aaabb"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Delete_InvalidToken_Failure(t *testing.T) {
+	client := compressorv1.NewCompressorServiceClient(setupClient(t, repomock.NewRepository(t)))
+
+	_, err := client.Delete(context.Background(), &compressorv1.DeleteRequest{Code: "aaabb"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer wrong")
+
+	_, err = client.Delete(ctx, &compressorv1.DeleteRequest{Code: "aaabb"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.BatchShorten(ctx, &compressorv1.BatchShortenRequest{
+		Requests: []*compressorv1.ShortenRequest{{Destination: "http://example.com"}},
+	})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func Test_Delete_AdminDisabled_Failure(t *testing.T) {
+	client := compressorv1.NewCompressorServiceClient(setupClientWithConfig(t, repomock.NewRepository(t), &config.Config{}))
+
+	_, err := client.Delete(adminContext(), &compressorv1.DeleteRequest{Code: "aaabb"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func Test_Health_Serving_Success(t *testing.T) {
+	client := healthpb.NewHealthClient(setupClient(t, repomock.NewRepository(t)))
+
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{
+		Service: compressorv1.CompressorService_ServiceDesc.ServiceName,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+}
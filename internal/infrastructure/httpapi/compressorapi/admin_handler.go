@@ -0,0 +1,149 @@
+package compressorapi
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/infrastructure/linkio"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	admintypes "github.com/AFK068/compressor/internal/api/openapi/compressor/admin"
+)
+
+const (
+	// MaxReportedImportErrors caps the skipped rows listed in an import report, the failed count is always exact.
+	MaxReportedImportErrors = 1000
+
+	ErrUnauthorized        = "unauthorized"
+	ErrInvalidFormat       = "invalid_format"
+	ErrFailedToImportLinks = "Failed to import links"
+
+	ErrDescriptionUnauthorized        = "Missing or invalid admin token"
+	ErrDescriptionInvalidFormat       = "Format must be csv or jsonl"
+	ErrDescriptionFailedToImportLinks = "Failed to import links"
+)
+
+// AdminHandler serves the admin API, which imports and exports links in bulk.
+type AdminHandler struct {
+	repository domain.Repository
+	logger     *zap.Logger
+}
+
+func NewAdminHandler(repository domain.Repository, logger *zap.Logger) *AdminHandler {
+	return &AdminHandler{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+// AdminAuth rejects requests that do not carry token as a bearer token.
+func AdminAuth(token string) echo.MiddlewareFunc {
+	expected := []byte("Bearer " + token)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			provided := []byte(ctx.Request().Header.Get(echo.HeaderAuthorization))
+			if subtle.ConstantTimeCompare(provided, expected) != 1 {
+				return SendErrorResponse(ctx, http.StatusUnauthorized, ErrUnauthorized, ErrDescriptionUnauthorized)
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+func (h *AdminHandler) ExportLinks(ctx echo.Context, params admintypes.ExportLinksParams) error {
+	h.logger.Info("Export links request received")
+
+	format := linkio.FormatCSV
+
+	if params.Format != nil {
+		var err error
+		if format, err = linkio.ParseFormat(string(*params.Format)); err != nil {
+			return SendBadRequestResponse(ctx, ErrInvalidFormat, ErrDescriptionInvalidFormat)
+		}
+	}
+
+	ctx.Response().Header().Set(echo.HeaderContentType, format.ContentType())
+	ctx.Response().WriteHeader(http.StatusOK)
+
+	exported, err := linkio.Export(ctx.Request().Context(), h.repository, linkio.NewWriter(ctx.Response(), format))
+	if err != nil {
+		// The status is already sent, the client sees a truncated body.
+		h.logger.Error("Failed to export links", zap.Int("exported", exported), zap.Error(err))
+		return nil
+	}
+
+	h.logger.Info("Successfully exported links", zap.Int("exported", exported))
+
+	return nil
+}
+
+func (h *AdminHandler) ImportLinks(ctx echo.Context, params admintypes.ImportLinksParams) error {
+	h.logger.Info("Import links request received")
+
+	format, ok := importFormat(ctx.Request(), params)
+	if !ok {
+		return SendBadRequestResponse(ctx, ErrInvalidFormat, ErrDescriptionInvalidFormat)
+	}
+
+	rowErrors := make([]admintypes.ImportRowError, 0)
+
+	report, err := linkio.Import(
+		ctx.Request().Context(),
+		h.repository,
+		linkio.NewReader(ctx.Request().Body, format),
+		func(rowErr *linkio.RowError) {
+			if len(rowErrors) >= MaxReportedImportErrors {
+				return
+			}
+
+			rowError := admintypes.ImportRowError{Line: rowErr.Line, Message: rowErr.Err.Error()}
+			if rowErr.Code != "" {
+				rowError.Code = &rowErr.Code
+			}
+
+			rowErrors = append(rowErrors, rowError)
+		},
+	)
+	if err != nil {
+		h.logger.Error("Failed to import links", zap.Int("imported", report.Imported), zap.Error(err))
+		return SendBadRequestResponse(ctx, ErrFailedToImportLinks, ErrDescriptionFailedToImportLinks,
+			WithDetail(fmt.Sprintf("import stopped after %d links: %v", report.Imported, err)))
+	}
+
+	h.logger.Info("Successfully imported links", zap.Int("imported", report.Imported), zap.Int("failed", report.Failed))
+
+	return SendSuccessResponse(ctx, admintypes.ImportReport{
+		Imported: report.Imported,
+		Failed:   report.Failed,
+		Errors:   rowErrors,
+	})
+}
+
+// importFormat prefers the format parameter and falls back to the Content-Type of the body.
+func importFormat(req *http.Request, params admintypes.ImportLinksParams) (linkio.Format, bool) {
+	if params.Format != nil {
+		format, err := linkio.ParseFormat(string(*params.Format))
+
+		return format, err == nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+	if err != nil {
+		return "", false
+	}
+
+	switch mediaType {
+	case linkio.MIMETextCSV:
+		return linkio.FormatCSV, true
+	case linkio.MIMEApplicationJSONL, linkio.MIMEApplicationJSONLines:
+		return linkio.FormatJSONL, true
+	default:
+		return "", false
+	}
+}
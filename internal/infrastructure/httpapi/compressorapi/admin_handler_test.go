@@ -0,0 +1,121 @@
+package compressorapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/infrastructure/httpapi/compressorapi"
+	"github.com/AFK068/compressor/internal/infrastructure/repository/inmemoryrepo"
+	"github.com/AFK068/compressor/pkg/shortener"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	admintypes "github.com/AFK068/compressor/internal/api/openapi/compressor/admin"
+)
+
+const (
+	testAdminToken = "secret"
+)
+
+func setupAdminServer(t *testing.T) (*echo.Echo, *inmemoryrepo.InMemoryRepository) {
+	s, err := shortener.NewShortener("abcdefgh", 4)
+	assert.NoError(t, err)
+
+	repo := inmemoryrepo.New(s, 100)
+
+	e := echo.New()
+	admin := e.Group("/admin", compressorapi.AdminAuth(testAdminToken))
+	admintypes.RegisterHandlers(admin, compressorapi.NewAdminHandler(repo, zap.NewNop()))
+
+	return e, repo
+}
+
+func adminRequest(e *echo.Echo, method, target, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+testAdminToken)
+
+	if contentType != "" {
+		req.Header.Set(echo.HeaderContentType, contentType)
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func Test_ImportLinks_Success(t *testing.T) {
+	e, repo := setupAdminServer(t)
+
+	_, err := repo.CreateLink(context.Background(), &domain.Link{Destination: "http://example.com/taken"})
+	assert.NoError(t, err)
+
+	body := strings.Join([]string{
+		`{"code":"aabb","destination":"http://example.com/1","owner":"team-a"}`,
+		`{"code":"aaaa","destination":"http://example.com/2"}`,
+		`{"code":"aabc","destination":"http://example.com/taken"}`,
+	}, "\n")
+
+	rec := adminRequest(e, http.MethodPost, "/admin/links/import", "application/x-ndjson", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var report admintypes.ImportReport
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+
+	assert.Equal(t, 2, report.Imported)
+	assert.Equal(t, 1, report.Failed)
+	assert.Len(t, report.Errors, 1)
+	assert.Equal(t, 2, report.Errors[0].Line)
+	assert.Equal(t, "aaaa", *report.Errors[0].Code)
+
+	link, err := repo.GetLink(context.Background(), "aabb")
+	assert.NoError(t, err)
+	assert.Equal(t, "team-a", link.Owner)
+
+	link, err = repo.GetLink(context.Background(), "aabc")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com/taken", link.Destination)
+}
+
+func Test_ImportLinks_UnknownFormat_Failure(t *testing.T) {
+	e, _ := setupAdminServer(t)
+
+	rec := adminRequest(e, http.MethodPost, "/admin/links/import", "application/json", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), compressorapi.ErrInvalidFormat)
+}
+
+func Test_ExportLinks_Success(t *testing.T) {
+	e, repo := setupAdminServer(t)
+
+	_, err := repo.CreateLink(context.Background(), &domain.Link{Destination: "http://example.com", Owner: "team-a"})
+	assert.NoError(t, err)
+
+	rec := adminRequest(e, http.MethodGet, "/admin/links/export?format=csv", "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv", rec.Header().Get(echo.HeaderContentType))
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "code,destination,created_at,expires_at,owner,disabled", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "aaaa,http://example.com,"))
+	assert.True(t, strings.HasSuffix(lines[1], ",,team-a,false"))
+}
+
+func Test_AdminAuth_InvalidToken_Failure(t *testing.T) {
+	e, _ := setupAdminServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/links/export", http.NoBody)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer wrong")
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
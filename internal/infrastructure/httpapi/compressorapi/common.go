@@ -1,7 +1,12 @@
 package compressorapi
 
 import (
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/labstack/echo/v4"
@@ -21,22 +26,144 @@ const (
 	ErrDescriptionLinkNotFound       = "Link not found"
 )
 
+const (
+	MIMEApplicationProblemJSON = "application/problem+json"
+
+	// ProblemTypeBaseURI is the prefix of the RFC 7807 "type" member. It is a relative
+	// reference, resolved by clients against the request URI.
+	ProblemTypeBaseURI = "/problems/"
+
+	// ProblemExtensionCode carries the machine-readable error code as an extension member.
+	ProblemExtensionCode = "code"
+)
+
+type ProblemOption func(problem *compressortypes.ProblemDetails)
+
+// WithTitle replaces the summary of the problem type, which otherwise is the description.
+func WithTitle(title string) ProblemOption {
+	return func(problem *compressortypes.ProblemDetails) {
+		problem.Title = aws.String(title)
+	}
+}
+
+// WithDetail sets the occurrence-specific explanation of the problem.
+func WithDetail(detail string) ProblemOption {
+	return func(problem *compressortypes.ProblemDetails) {
+		problem.Detail = aws.String(detail)
+	}
+}
+
+// WithExtension adds an extension member to the problem details.
+func WithExtension(name string, value any) ProblemOption {
+	return func(problem *compressortypes.ProblemDetails) {
+		problem.Set(name, value)
+	}
+}
+
 func SendSuccessResponse(ctx echo.Context, data any) error {
 	return ctx.JSON(http.StatusOK, data)
 }
 
-func SendBadRequestResponse(ctx echo.Context, err, description string) error {
-	return ctx.JSON(http.StatusBadRequest, compressortypes.ApiErrorResponse{
-		Description:      aws.String(description),
-		Code:             aws.String("400"),
-		ExceptionMessage: aws.String(err),
-	})
+func SendBadRequestResponse(ctx echo.Context, err, description string, opts ...ProblemOption) error {
+	return SendErrorResponse(ctx, http.StatusBadRequest, err, description, opts...)
+}
+
+func SendNotFoundResponse(ctx echo.Context, err, description string, opts ...ProblemOption) error {
+	return SendErrorResponse(ctx, http.StatusNotFound, err, description, opts...)
+}
+
+// SendErrorResponse writes an RFC 7807 problem when the client prefers application/problem+json
+// and falls back to ApiErrorResponse otherwise, so existing clients keep their response shape.
+func SendErrorResponse(ctx echo.Context, status int, err, description string, opts ...ProblemOption) error {
+	if !AcceptsProblemJSON(ctx.Request()) {
+		return ctx.JSON(status, compressortypes.ApiErrorResponse{
+			Description:      aws.String(description),
+			Code:             aws.String(strconv.Itoa(status)),
+			ExceptionMessage: aws.String(err),
+		})
+	}
+
+	problem := compressortypes.ProblemDetails{
+		Type:     aws.String(ProblemTypeBaseURI + problemSlug(err)),
+		Title:    aws.String(description),
+		Status:   aws.Int(status),
+		Instance: aws.String(ctx.Request().URL.RequestURI()),
+	}
+
+	problem.Set(ProblemExtensionCode, err)
+
+	for _, opt := range opts {
+		opt(&problem)
+	}
+
+	ctx.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+
+	return ctx.JSON(status, problem)
+}
+
+// HTTPErrorHandler renders errors returned by echo itself (routing, parameter binding)
+// in the same negotiated shape as the handler errors.
+func HTTPErrorHandler(err error, ctx echo.Context) {
+	if ctx.Response().Committed {
+		return
+	}
+
+	status := http.StatusInternalServerError
+	description := http.StatusText(status)
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		status = httpErr.Code
+		description = fmt.Sprint(httpErr.Message)
+	}
+
+	// The problem title must stay the same for every occurrence of the status,
+	// so the echo message goes to the detail member.
+	opts := []ProblemOption{WithTitle(http.StatusText(status))}
+	if description != http.StatusText(status) {
+		opts = append(opts, WithDetail(description))
+	}
+
+	if ctx.Request().Method == http.MethodHead {
+		_ = ctx.NoContent(status)
+		return
+	}
+
+	code := strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+
+	_ = SendErrorResponse(ctx, status, code, description, opts...)
+}
+
+// AcceptsProblemJSON reports whether the Accept header explicitly asks for application/problem+json
+// with a quality not lower than the one given to application/json. Wildcards keep the legacy shape.
+func AcceptsProblemJSON(req *http.Request) bool {
+	var problemQ, jsonQ float64
+
+	for _, part := range strings.Split(req.Header.Get(echo.HeaderAccept), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+
+		if raw, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(raw, 64); err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case MIMEApplicationProblemJSON:
+			problemQ = max(problemQ, q)
+		case echo.MIMEApplicationJSON:
+			jsonQ = max(jsonQ, q)
+		}
+	}
+
+	return problemQ > 0 && problemQ >= jsonQ
 }
 
-func SendNotFoundResponse(ctx echo.Context, err, description string) error {
-	return ctx.JSON(http.StatusNotFound, compressortypes.ApiErrorResponse{
-		Description:      aws.String(description),
-		Code:             aws.String("404"),
-		ExceptionMessage: aws.String(err),
-	})
+func problemSlug(code string) string {
+	return strings.NewReplacer(" ", "-", "_", "-").Replace(strings.ToLower(code))
 }
@@ -2,9 +2,12 @@ package compressorapi
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
 
 	"github.com/AFK068/compressor/internal/domain"
 	"github.com/AFK068/compressor/internal/domain/apperrors"
+	"github.com/AFK068/compressor/pkg/shortener"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
@@ -14,12 +17,14 @@ import (
 
 type Handler struct {
 	repository domain.Repository
+	shortURLs  *ShortURLBuilder
 	logger     *zap.Logger
 }
 
-func NewHandler(repository domain.Repository, logger *zap.Logger) *Handler {
+func NewHandler(repository domain.Repository, shortURLs *ShortURLBuilder, logger *zap.Logger) *Handler {
 	return &Handler{
 		repository: repository,
+		shortURLs:  shortURLs,
 		logger:     logger,
 	}
 }
@@ -32,7 +37,8 @@ func (h *Handler) GetUrl(ctx echo.Context, params compressortypes.GetUrlParams)
 	var errURLNotFound *apperrors.ErrURLNotFound
 	if errors.As(err, &errURLNotFound) {
 		h.logger.Error("URL not found", zap.String("shortUrl", params.ShortUrl))
-		return SendNotFoundResponse(ctx, ErrLinkNotFound, ErrDescriptionLinkNotFound)
+		return SendNotFoundResponse(ctx, ErrLinkNotFound, ErrDescriptionLinkNotFound,
+			WithDetail(fmt.Sprintf("no link found for short URL %q", params.ShortUrl)))
 	}
 
 	if err != nil {
@@ -61,6 +67,12 @@ func (h *Handler) PostUrl(ctx echo.Context) error { //nolint
 		return SendBadRequestResponse(ctx, ErrInvalidRequestBody, ErrDescriptionInvalidRequestBody)
 	}
 
+	if !isAbsoluteURL(*request.Url) {
+		h.logger.Error("Invalid URL", zap.String("url", *request.Url))
+		return SendBadRequestResponse(ctx, ErrInvalidDestination, ErrDescriptionInvalidDestination,
+			WithDetail(fmt.Sprintf("%q is not an absolute URL", *request.Url)))
+	}
+
 	short, err := h.repository.SaveURL(ctx.Request().Context(), *request.Url)
 	if err != nil {
 		h.logger.Error("Failed to save URL", zap.Error(err))
@@ -70,6 +82,32 @@ func (h *Handler) PostUrl(ctx echo.Context) error { //nolint
 	h.logger.Info("Successfully saved URL", zap.String("shortUrl", short))
 
 	return SendSuccessResponse(ctx, compressortypes.UrlResponse{
-		Url: aws.String(short),
+		Url:      aws.String(short),
+		Code:     aws.String(short),
+		ShortUrl: aws.String(h.shortURLs.Build(ctx, short)),
 	})
 }
+
+// Redirect serves the short URLs built by ShortURLBuilder. Disabled and expired links, and codes that
+// are not valid for the alphabet and length, are not found.
+func (h *Handler) Redirect(ctx echo.Context, code string) error {
+	originalURL, err := h.repository.GetURL(ctx.Request().Context(), code)
+
+	var errURLNotFound *apperrors.ErrURLNotFound
+	if errors.As(err, &errURLNotFound) || isInvalidCode(err) {
+		return SendNotFoundResponse(ctx, ErrLinkNotFound, ErrDescriptionLinkNotFound,
+			WithDetail(fmt.Sprintf("no link found for code %q", code)))
+	}
+
+	if err != nil {
+		h.logger.Error("Failed to resolve link", zap.String("code", code), zap.Error(err))
+		return SendBadRequestResponse(ctx, ErrFailedToGetURL, ErrDescriptionFailedToGetURL)
+	}
+
+	return ctx.Redirect(http.StatusFound, originalURL)
+}
+
+// isInvalidCode tells whether err is returned for a code that cannot be decoded, so no link can have it.
+func isInvalidCode(err error) bool {
+	return errors.Is(err, shortener.ErrInvalidDecoderLength) || errors.Is(err, shortener.ErrInvalidCharacter)
+}
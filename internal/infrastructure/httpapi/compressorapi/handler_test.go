@@ -1,6 +1,7 @@
 package compressorapi_test
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/AFK068/compressor/internal/domain/apperrors"
 	"github.com/AFK068/compressor/internal/infrastructure/httpapi/compressorapi"
+	"github.com/AFK068/compressor/pkg/shortener"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -21,7 +23,7 @@ func Test_GetUrl_Success(t *testing.T) {
 	repoMock := repomock.NewRepository(t)
 
 	repoMock.On("GetURL", mock.Anything, "shortUrl").Return("http://example.com", nil)
-	handler := compressorapi.NewHandler(repoMock, zap.NewNop())
+	handler := compressorapi.NewHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
 
 	req := httptest.NewRequest("GET", "/url", http.NoBody)
 	rec := httptest.NewRecorder()
@@ -39,7 +41,7 @@ func Test_GetUrl_NotFound_Failure(t *testing.T) {
 	repoMock := repomock.NewRepository(t)
 
 	repoMock.On("GetURL", mock.Anything, "shortUrl").Return("", &apperrors.ErrURLNotFound{Message: "url not found"})
-	handler := compressorapi.NewHandler(repoMock, zap.NewNop())
+	handler := compressorapi.NewHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
 
 	req := httptest.NewRequest("GET", "/url", http.NoBody)
 	rec := httptest.NewRecorder()
@@ -57,7 +59,7 @@ func Test_GetUrl_BadRequest_Filure(t *testing.T) {
 	repoMock := repomock.NewRepository(t)
 
 	repoMock.On("GetURL", mock.Anything, "shortUrl").Return("", &apperrors.ErrRepositoryIsFull{Message: "repository is full"})
-	handler := compressorapi.NewHandler(repoMock, zap.NewNop())
+	handler := compressorapi.NewHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
 
 	req := httptest.NewRequest("GET", "/url", http.NoBody)
 	rec := httptest.NewRecorder()
@@ -71,15 +73,71 @@ func Test_GetUrl_BadRequest_Filure(t *testing.T) {
 	repoMock.AssertExpectations(t)
 }
 
+func Test_Redirect_Success(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	repoMock.On("GetURL", mock.Anything, "code").Return("http://example.com", nil)
+	handler := compressorapi.NewHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
+
+	req := httptest.NewRequest("GET", "/code", http.NoBody)
+	rec := httptest.NewRecorder()
+
+	c := echo.New().NewContext(req, rec)
+
+	err := handler.Redirect(c, "code")
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "http://example.com", rec.Header().Get("Location"))
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Redirect_NotFound_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	repoMock.On("GetURL", mock.Anything, "code").Return("", &apperrors.ErrURLNotFound{Message: "url not found"})
+	handler := compressorapi.NewHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
+
+	req := httptest.NewRequest("GET", "/code", http.NoBody)
+	rec := httptest.NewRecorder()
+
+	c := echo.New().NewContext(req, rec)
+
+	err := handler.Redirect(c, "code")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 404, rec.Code)
+	assert.Empty(t, rec.Header().Get("Location"))
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Redirect_InvalidCode_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	repoMock.On("GetURL", mock.Anything, "favicon.ico").Return("", shortener.ErrInvalidCharacter)
+	handler := compressorapi.NewHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
+
+	req := httptest.NewRequest("GET", "/favicon.ico", http.NoBody)
+	rec := httptest.NewRecorder()
+
+	c := echo.New().NewContext(req, rec)
+
+	err := handler.Redirect(c, "favicon.ico")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 404, rec.Code)
+	repoMock.AssertExpectations(t)
+}
+
 func Test_PostUrl_Success(t *testing.T) {
 	repoMock := repomock.NewRepository(t)
 
 	repoMock.On("SaveURL", mock.Anything, "http://example.com").Return("shortUrl", nil)
 
-	handler := compressorapi.NewHandler(repoMock, zap.NewNop())
+	handler := compressorapi.NewHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
 
 	body := `{"url": "http://example.com"}`
-	req := httptest.NewRequest("POST", "/url", strings.NewReader(body))
+	req := httptest.NewRequest("POST", "http://sho.rt/url", strings.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 
 	rec := httptest.NewRecorder()
@@ -90,6 +148,11 @@ func Test_PostUrl_Success(t *testing.T) {
 
 	assert.Equal(t, 200, rec.Code)
 
+	var response compressortypes.UrlResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "shortUrl", *response.Code)
+	assert.Equal(t, "http://sho.rt/shortUrl", *response.ShortUrl)
+
 	repoMock.AssertExpectations(t)
 }
 
@@ -98,7 +161,7 @@ func Test_PostUrl_BadRequest_Failure(t *testing.T) {
 
 	repoMock.On("SaveURL", mock.Anything, "http://example.com").Return("", &apperrors.ErrRepositoryIsFull{Message: "repository is full"})
 
-	handler := compressorapi.NewHandler(repoMock, zap.NewNop())
+	handler := compressorapi.NewHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
 
 	body := `{"url": "http://example.com"}`
 	req := httptest.NewRequest("POST", "/url", strings.NewReader(body))
@@ -118,7 +181,7 @@ func Test_PostUrl_BadRequest_Failure(t *testing.T) {
 func Test_PostUrl_InvalidRequestBody_Failure(t *testing.T) {
 	repoMock := repomock.NewRepository(t)
 
-	handler := compressorapi.NewHandler(repoMock, zap.NewNop())
+	handler := compressorapi.NewHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
 
 	body := `{"asd": "http://example.com"}`
 	req := httptest.NewRequest("POST", "/url", strings.NewReader(body))
@@ -134,3 +197,140 @@ func Test_PostUrl_InvalidRequestBody_Failure(t *testing.T) {
 
 	repoMock.AssertExpectations(t)
 }
+
+func Test_PostUrl_RelativeURL_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	handler := compressorapi.NewHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
+
+	body := `{"url": "javascript:alert(1)"}`
+	req := httptest.NewRequest("POST", "/url", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	err := handler.PostUrl(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 400, rec.Code)
+	assert.Contains(t, rec.Body.String(), compressorapi.ErrDescriptionInvalidDestination)
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_GetUrl_NotFound_ProblemJSON_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	repoMock.On("GetURL", mock.Anything, "shortUrl").Return("", &apperrors.ErrURLNotFound{Message: "url not found"})
+	handler := compressorapi.NewHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
+
+	req := httptest.NewRequest("GET", "/url?short-url=shortUrl", http.NoBody)
+	req.Header.Set("Accept", "application/problem+json, application/json;q=0.5")
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	err := handler.GetUrl(c, compressortypes.GetUrlParams{ShortUrl: "shortUrl"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 404, rec.Code)
+	assert.Equal(t, compressorapi.MIMEApplicationProblemJSON, rec.Header().Get("Content-Type"))
+
+	var problem compressortypes.ProblemDetails
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+
+	assert.Equal(t, "/problems/link-not-found", *problem.Type)
+	assert.Equal(t, compressorapi.ErrDescriptionLinkNotFound, *problem.Title)
+	assert.Equal(t, 404, *problem.Status)
+	assert.Equal(t, `no link found for short URL "shortUrl"`, *problem.Detail)
+	assert.Equal(t, "/url?short-url=shortUrl", *problem.Instance)
+
+	code, ok := problem.Get(compressorapi.ProblemExtensionCode)
+	assert.True(t, ok)
+	assert.Equal(t, compressorapi.ErrLinkNotFound, code)
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_PostUrl_InvalidRequestBody_LegacyJSON_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	handler := compressorapi.NewHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
+
+	req := httptest.NewRequest("POST", "/url", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, application/problem+json;q=0.9")
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	err := handler.PostUrl(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 400, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+
+	var response compressortypes.ApiErrorResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "400", *response.Code)
+	assert.Equal(t, compressorapi.ErrInvalidRequestBody, *response.ExceptionMessage)
+}
+
+func Test_AcceptsProblemJSON(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected bool
+	}{
+		{accept: "", expected: false},
+		{accept: "*/*", expected: false},
+		{accept: "application/json", expected: false},
+		{accept: "application/problem+json", expected: true},
+		{accept: "application/json, application/problem+json", expected: true},
+		{accept: "application/json, application/problem+json;q=0.8", expected: false},
+		{accept: "application/problem+json;q=0", expected: false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/url", http.NoBody)
+		req.Header.Set("Accept", tt.accept)
+
+		assert.Equal(t, tt.expected, compressorapi.AcceptsProblemJSON(req), tt.accept)
+	}
+}
+
+func Test_HTTPErrorHandler_ProblemJSON(t *testing.T) {
+	req := httptest.NewRequest("GET", "/url", http.NoBody)
+	req.Header.Set("Accept", "application/problem+json")
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	compressorapi.HTTPErrorHandler(echo.NewHTTPError(http.StatusBadRequest, "Invalid format for parameter short-url"), c)
+
+	assert.Equal(t, 400, rec.Code)
+	assert.Equal(t, compressorapi.MIMEApplicationProblemJSON, rec.Header().Get("Content-Type"))
+
+	var problem compressortypes.ProblemDetails
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+
+	assert.Equal(t, "/problems/bad-request", *problem.Type)
+	assert.Equal(t, "Bad Request", *problem.Title)
+	assert.Equal(t, "Invalid format for parameter short-url", *problem.Detail)
+}
+
+func Test_HTTPErrorHandler_LegacyDescription(t *testing.T) {
+	req := httptest.NewRequest("GET", "/url", http.NoBody)
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	compressorapi.HTTPErrorHandler(echo.NewHTTPError(http.StatusBadRequest, "Invalid format for parameter short-url"), c)
+
+	assert.Equal(t, 400, rec.Code)
+
+	var apiErr compressortypes.ApiErrorResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiErr))
+
+	assert.Equal(t, "Invalid format for parameter short-url", *apiErr.Description)
+}
@@ -0,0 +1,285 @@
+package compressorapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	MaxIdempotencyKeyLength = 255
+
+	// MaxIdempotentBodySize limits the bodies of requests with an Idempotency-Key, which are read into
+	// memory to be hashed.
+	MaxIdempotentBodySize = 1 << 20
+
+	// ReservationTTL limits how long a key stays reserved by a request that neither completes nor
+	// releases it, e.g. because the server stopped.
+	ReservationTTL = time.Minute
+
+	// InFlightRetryAfter is the Retry-After, in seconds, sent while the key is reserved by another request.
+	InFlightRetryAfter = "1"
+
+	ErrInvalidIdempotencyKey      = "invalid_idempotency_key"
+	ErrIdempotencyKeyReused       = "idempotency_key_reused"
+	ErrIdempotencyRequestInFlight = "idempotency_request_in_flight"
+	ErrRequestBodyTooLarge        = "request_body_too_large"
+
+	ErrDescriptionInvalidIdempotencyKey      = "Idempotency key must be between 1 and 255 characters"
+	ErrDescriptionIdempotencyKeyReused       = "Idempotency key was already used for a different request"
+	ErrDescriptionIdempotencyRequestInFlight = "A request with the same idempotency key is still being processed"
+	ErrDescriptionRequestBodyTooLarge        = "Request body must not exceed 1 MiB"
+)
+
+// Idempotency replays the first response given to a request carrying an Idempotency-Key header,
+// so clients can safely retry create requests after timeouts. Keys are scoped to the route and the
+// Authorization header, so different clients don't see each other's responses.
+type Idempotency struct {
+	store  domain.IdempotencyStore
+	ttl    atomic.Int64
+	logger *zap.Logger
+}
+
+func NewIdempotency(store domain.IdempotencyStore, cfg *config.Config, logger *zap.Logger) *Idempotency {
+	i := &Idempotency{
+		store:  store,
+		logger: logger,
+	}
+
+	i.Reconfigure(cfg)
+
+	return i
+}
+
+// Reconfigure applies the TTL of cfg to the records saved from now on.
+func (i *Idempotency) Reconfigure(cfg *config.Config) {
+	i.ttl.Store(int64(cfg.Idempotency.TTL))
+}
+
+func (i *Idempotency) TTL() time.Duration {
+	return time.Duration(i.ttl.Load())
+}
+
+// Middleware applies idempotency to the routes registered under paths, e.g. "/url".
+func (i *Idempotency) Middleware(paths ...string) echo.MiddlewareFunc {
+	routes := make(map[string]struct{}, len(paths))
+	for _, path := range paths {
+		routes[path] = struct{}{}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			key, ok := ctx.Request().Header[HeaderIdempotencyKey]
+			if _, route := routes[ctx.Path()]; !ok || !route || ctx.Request().Method != http.MethodPost {
+				return next(ctx)
+			}
+
+			if len(key) != 1 || key[0] == "" || len(key[0]) > MaxIdempotencyKeyLength {
+				return SendBadRequestResponse(ctx, ErrInvalidIdempotencyKey, ErrDescriptionInvalidIdempotencyKey)
+			}
+
+			return i.handle(ctx, next, key[0])
+		}
+	}
+}
+
+func (i *Idempotency) handle(ctx echo.Context, next echo.HandlerFunc, key string) error {
+	body, err := io.ReadAll(http.MaxBytesReader(ctx.Response(), ctx.Request().Body, MaxIdempotentBodySize))
+	if err != nil {
+		var errMaxBytes *http.MaxBytesError
+		if errors.As(err, &errMaxBytes) {
+			return SendErrorResponse(ctx, http.StatusRequestEntityTooLarge, ErrRequestBodyTooLarge, ErrDescriptionRequestBodyTooLarge)
+		}
+
+		return SendBadRequestResponse(ctx, ErrInvalidRequestBody, ErrDescriptionInvalidRequestBody)
+	}
+
+	ctx.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+	// The key is reserved before the request is handled, so concurrent retries don't all run it.
+	now := time.Now()
+	record := &domain.IdempotencyRecord{
+		Key:         scopeKey(ctx, key),
+		RequestHash: hashRequest(ctx.Request(), body),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(min(i.TTL(), ReservationTTL)),
+	}
+
+	existing, err := i.store.ReserveIdempotencyKey(ctx.Request().Context(), record)
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		return i.replay(ctx, existing, record.RequestHash, key)
+	}
+
+	original := ctx.Response().Writer
+	buffer := newBufferedResponseWriter()
+	ctx.Response().Writer = buffer
+
+	err = next(ctx)
+
+	ctx.Response().Writer = original
+
+	if err != nil || buffer.status >= http.StatusInternalServerError {
+		// Server errors are not stored, so the request can be retried.
+		if releaseErr := i.store.ReleaseIdempotencyKey(ctx.Request().Context(), record); releaseErr != nil {
+			i.logger.Error("Failed to release idempotency key", zap.String("key", key), zap.Error(releaseErr))
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return buffer.flushTo(original)
+	}
+
+	record.StatusCode = buffer.status
+	record.ContentType = buffer.Header().Get(echo.HeaderContentType)
+	record.Headers = buffer.Header().Clone()
+	delete(record.Headers, echo.HeaderContentType)
+	record.Body = buffer.body.Bytes()
+	record.ExpiresAt = time.Now().Add(i.TTL())
+
+	if err := i.store.CompleteIdempotencyRecord(ctx.Request().Context(), record); err != nil {
+		i.logger.Error("Failed to save idempotency record", zap.String("key", key), zap.Error(err))
+	}
+
+	return buffer.flushTo(original)
+}
+
+func (i *Idempotency) replay(ctx echo.Context, record *domain.IdempotencyRecord, requestHash, key string) error {
+	if record.RequestHash != requestHash {
+		i.logger.Error("Idempotency key reused with a different request", zap.String("key", key))
+		return SendErrorResponse(ctx, http.StatusConflict, ErrIdempotencyKeyReused, ErrDescriptionIdempotencyKeyReused)
+	}
+
+	if record.InFlight() {
+		i.logger.Info("Idempotent request is still in flight", zap.String("key", key))
+
+		ctx.Response().Header().Set(echo.HeaderRetryAfter, InFlightRetryAfter)
+
+		return SendErrorResponse(ctx, http.StatusConflict, ErrIdempotencyRequestInFlight, ErrDescriptionIdempotencyRequestInFlight)
+	}
+
+	i.logger.Info("Replaying idempotent response", zap.String("key", key))
+
+	for name, values := range record.Headers {
+		ctx.Response().Header()[name] = append([]string(nil), values...)
+	}
+
+	ctx.Response().Header().Set(HeaderIdempotentReplayed, "true")
+
+	return ctx.Blob(record.StatusCode, record.ContentType, record.Body)
+}
+
+func (i *Idempotency) RegisterHooks(lc fx.Lifecycle, log *zap.Logger) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go i.purgeExpired(ctx, log)
+
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+
+			return nil
+		},
+	})
+}
+
+func (i *Idempotency) purgeExpired(ctx context.Context, log *zap.Logger) {
+	ticker := time.NewTicker(min(i.TTL(), time.Hour))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			deleted, err := i.store.DeleteExpiredIdempotencyRecords(ctx, now)
+			if err != nil {
+				log.Error("Failed to delete expired idempotency records", zap.Error(err))
+				continue
+			}
+
+			log.Info("Deleted expired idempotency records", zap.Int64("count", deleted))
+		}
+	}
+}
+
+// scopeKey returns the key the record is stored under: the client's key scoped to the route and the
+// credential the request was made with.
+func scopeKey(ctx echo.Context, key string) string {
+	hash := sha256.New()
+
+	hash.Write([]byte(ctx.Request().Method + " " + ctx.Path() + "\n"))
+	hash.Write([]byte(ctx.Request().Header.Get(echo.HeaderAuthorization) + "\n"))
+	hash.Write([]byte(key))
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func hashRequest(req *http.Request, body []byte) string {
+	hash := sha256.New()
+
+	hash.Write([]byte(req.Method + " " + req.URL.Path + "\n"))
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{
+		header: make(http.Header),
+		status: http.StatusOK,
+	}
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponseWriter) flushTo(original http.ResponseWriter) error {
+	for name, values := range w.header {
+		original.Header()[name] = values
+	}
+
+	original.WriteHeader(w.status)
+
+	_, err := original.Write(w.body.Bytes())
+
+	return err
+}
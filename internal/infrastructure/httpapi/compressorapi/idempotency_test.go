@@ -0,0 +1,273 @@
+package compressorapi_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/infrastructure/httpapi/compressorapi"
+	"github.com/AFK068/compressor/internal/infrastructure/repository/inmemoryrepo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+
+	compressortypes "github.com/AFK068/compressor/internal/api/openapi/compressor/v1"
+	repomock "github.com/AFK068/compressor/internal/domain/mocks"
+)
+
+func setupIdempotentServer(t *testing.T, repoMock *repomock.Repository) *echo.Echo {
+	cfg := &config.Config{Idempotency: config.Idempotency{TTL: time.Hour}}
+	store := inmemoryrepo.New(repomock.NewShortener(t), 0)
+
+	e := echo.New()
+	e.Use(compressorapi.NewIdempotency(store, cfg, zap.NewNop()).Middleware("/url"))
+
+	compressortypes.RegisterHandlers(e, compressorapi.NewHandler(repoMock, newShortURLBuilder(t), zap.NewNop()))
+
+	return e
+}
+
+func postURL(e *echo.Echo, key, body string) *httptest.ResponseRecorder {
+	return postURLAs(e, key, "", body)
+}
+
+func postURLAs(e *echo.Echo, key, authorization, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/url", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	if key != "" {
+		req.Header.Set(compressorapi.HeaderIdempotencyKey, key)
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func Test_Idempotency_Replay_Success(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+	repoMock.On("SaveURL", mock.Anything, "http://example.com").Return("shortUrl", nil).Once()
+
+	e := setupIdempotentServer(t, repoMock)
+
+	first := postURL(e, "key-1", `{"url": "http://example.com"}`)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Empty(t, first.Header().Get(compressorapi.HeaderIdempotentReplayed))
+
+	second := postURL(e, "key-1", `{"url": "http://example.com"}`)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, "true", second.Header().Get(compressorapi.HeaderIdempotentReplayed))
+	assert.Equal(t, first.Header().Get("Content-Type"), second.Header().Get("Content-Type"))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Idempotency_ReplaysHeaders_Success(t *testing.T) {
+	store := inmemoryrepo.New(repomock.NewShortener(t), 0)
+	cfg := &config.Config{Idempotency: config.Idempotency{TTL: time.Hour}}
+
+	calls := 0
+
+	e := echo.New()
+	e.Use(compressorapi.NewIdempotency(store, cfg, zap.NewNop()).Middleware("/links"))
+	e.POST("/links", func(ctx echo.Context) error {
+		calls++
+
+		ctx.Response().Header().Set(echo.HeaderLocation, "/links/aaab")
+
+		return ctx.JSON(http.StatusCreated, map[string]string{"code": "aaab"})
+	})
+
+	for range 2 {
+		req := httptest.NewRequest("POST", "/links", strings.NewReader(`{}`))
+		req.Header.Set(compressorapi.HeaderIdempotencyKey, "key-1")
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "/links/aaab", rec.Header().Get(echo.HeaderLocation))
+		assert.Equal(t, echo.MIMEApplicationJSON, rec.Header().Get(echo.HeaderContentType))
+	}
+
+	assert.Equal(t, 1, calls)
+}
+
+func Test_Idempotency_BodyTooLarge_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	e := setupIdempotentServer(t, repoMock)
+
+	body := `{"url": "http://example.com/` + strings.Repeat("a", compressorapi.MaxIdempotentBodySize) + `"}`
+
+	rec := postURL(e, "key-1", body)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Idempotency_DifferentBody_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+	repoMock.On("SaveURL", mock.Anything, "http://example.com").Return("shortUrl", nil).Once()
+
+	e := setupIdempotentServer(t, repoMock)
+
+	first := postURL(e, "key-1", `{"url": "http://example.com"}`)
+	assert.Equal(t, http.StatusOK, first.Code)
+
+	second := postURL(e, "key-1", `{"url": "http://example.com/other"}`)
+	assert.Equal(t, http.StatusConflict, second.Code)
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Idempotency_ClientError_Replayed_Success(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	e := setupIdempotentServer(t, repoMock)
+
+	first := postURL(e, "key-1", `{}`)
+	assert.Equal(t, http.StatusBadRequest, first.Code)
+
+	second := postURL(e, "key-1", `{}`)
+	assert.Equal(t, http.StatusBadRequest, second.Code)
+	assert.Equal(t, "true", second.Header().Get(compressorapi.HeaderIdempotentReplayed))
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Idempotency_WithoutKey_Success(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+	repoMock.On("SaveURL", mock.Anything, "http://example.com").Return("shortUrl", nil).Twice()
+
+	e := setupIdempotentServer(t, repoMock)
+
+	assert.Equal(t, http.StatusOK, postURL(e, "", `{"url": "http://example.com"}`).Code)
+	assert.Equal(t, http.StatusOK, postURL(e, "", `{"url": "http://example.com"}`).Code)
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Idempotency_KeyTooLong_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	e := setupIdempotentServer(t, repoMock)
+
+	rec := postURL(e, strings.Repeat("k", compressorapi.MaxIdempotencyKeyLength+1), `{"url": "http://example.com"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Idempotency_ConcurrentSameKey_Success(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	repoMock := repomock.NewRepository(t)
+	repoMock.On("SaveURL", mock.Anything, "http://example.com").
+		Run(func(mock.Arguments) {
+			close(started)
+			<-release
+		}).
+		Return("shortUrl", nil).Once()
+
+	e := setupIdempotentServer(t, repoMock)
+
+	var first *httptest.ResponseRecorder
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		first = postURL(e, "key-1", `{"url": "http://example.com"}`)
+	}()
+
+	<-started
+
+	const retries = 5
+
+	inFlight := make([]*httptest.ResponseRecorder, retries)
+
+	var retried sync.WaitGroup
+
+	for n := range retries {
+		retried.Add(1)
+
+		go func() {
+			defer retried.Done()
+
+			inFlight[n] = postURL(e, "key-1", `{"url": "http://example.com"}`)
+		}()
+	}
+
+	// The retries are answered while the first request is still blocked in SaveURL.
+	retried.Wait()
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, http.StatusOK, first.Code)
+
+	for _, rec := range inFlight {
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, compressorapi.InFlightRetryAfter, rec.Header().Get("Retry-After"))
+	}
+
+	replayed := postURL(e, "key-1", `{"url": "http://example.com"}`)
+	assert.Equal(t, http.StatusOK, replayed.Code)
+	assert.Equal(t, "true", replayed.Header().Get(compressorapi.HeaderIdempotentReplayed))
+	assert.Equal(t, first.Body.String(), replayed.Body.String())
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Idempotency_ScopedToCredential_Success(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+	repoMock.On("SaveURL", mock.Anything, "http://example.com").Return("shortUrl", nil).Twice()
+
+	e := setupIdempotentServer(t, repoMock)
+
+	first := postURLAs(e, "key-1", "Bearer a", `{"url": "http://example.com"}`)
+	assert.Equal(t, http.StatusOK, first.Code)
+
+	second := postURLAs(e, "key-1", "Bearer b", `{"url": "http://example.com"}`)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Empty(t, second.Header().Get(compressorapi.HeaderIdempotentReplayed))
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_Idempotency_ServerError_Released_Success(t *testing.T) {
+	cfg := &config.Config{Idempotency: config.Idempotency{TTL: time.Hour}}
+	store := inmemoryrepo.New(repomock.NewShortener(t), 0)
+
+	e := echo.New()
+	e.Use(compressorapi.NewIdempotency(store, cfg, zap.NewNop()).Middleware("/fail"))
+	e.POST("/fail", func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusServiceUnavailable)
+	})
+
+	for range 2 {
+		req := httptest.NewRequest("POST", "/fail", http.NoBody)
+		req.Header.Set(compressorapi.HeaderIdempotencyKey, "key-1")
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Empty(t, rec.Header().Get(compressorapi.HeaderIdempotentReplayed))
+	}
+}
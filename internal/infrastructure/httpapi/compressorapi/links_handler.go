@@ -0,0 +1,129 @@
+package compressorapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/domain/apperrors"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	linktypes "github.com/AFK068/compressor/internal/api/openapi/compressor/v2"
+)
+
+const (
+	ErrFailedToGetLink    = "Failed to get link"
+	ErrFailedToCreateLink = "Failed to create link"
+	ErrInvalidDestination = "invalid_destination"
+	ErrInvalidExpiry      = "invalid_expiry"
+
+	ErrDescriptionFailedToGetLink    = "Failed to get link"
+	ErrDescriptionFailedToCreateLink = "Failed to create link"
+	ErrDescriptionInvalidDestination = "Destination must be an absolute URL"
+	ErrDescriptionInvalidExpiry      = "Expiry must be in the future"
+)
+
+// LinksHandler serves the v2 API, which exposes links as resources.
+type LinksHandler struct {
+	repository domain.Repository
+	shortURLs  *ShortURLBuilder
+	logger     *zap.Logger
+}
+
+func NewLinksHandler(repository domain.Repository, shortURLs *ShortURLBuilder, logger *zap.Logger) *LinksHandler {
+	return &LinksHandler{
+		repository: repository,
+		shortURLs:  shortURLs,
+		logger:     logger,
+	}
+}
+
+func (h *LinksHandler) CreateLink(ctx echo.Context) error {
+	h.logger.Info("Create link request received")
+
+	var request linktypes.CreateLinkRequest
+	if err := ctx.Bind(&request); err != nil {
+		h.logger.Error("Failed to bind request", zap.Error(err))
+		return SendBadRequestResponse(ctx, ErrInvalidRequestBody, ErrDescriptionInvalidRequestBody)
+	}
+
+	if !isAbsoluteURL(request.Destination) {
+		h.logger.Error("Invalid destination", zap.String("destination", request.Destination))
+		return SendBadRequestResponse(ctx, ErrInvalidDestination, ErrDescriptionInvalidDestination,
+			WithDetail(fmt.Sprintf("%q is not an absolute URL", request.Destination)))
+	}
+
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		h.logger.Error("Expiry is in the past", zap.Time("expiresAt", *request.ExpiresAt))
+		return SendBadRequestResponse(ctx, ErrInvalidExpiry, ErrDescriptionInvalidExpiry)
+	}
+
+	link := &domain.Link{
+		Destination: request.Destination,
+		ExpiresAt:   request.ExpiresAt,
+	}
+
+	if request.Owner != nil {
+		link.Owner = *request.Owner
+	}
+
+	created, err := h.repository.CreateLink(ctx.Request().Context(), link)
+	if err != nil {
+		h.logger.Error("Failed to create link", zap.Error(err))
+		return SendBadRequestResponse(ctx, ErrFailedToCreateLink, ErrDescriptionFailedToCreateLink)
+	}
+
+	h.logger.Info("Successfully created link", zap.String("code", created.Code))
+
+	return ctx.JSON(http.StatusCreated, h.toLinkResponse(ctx, created))
+}
+
+func (h *LinksHandler) GetLink(ctx echo.Context, code string) error {
+	h.logger.Info("Get link request received", zap.String("code", code))
+
+	link, err := h.repository.ResolveLink(ctx.Request().Context(), code)
+
+	var errURLNotFound *apperrors.ErrURLNotFound
+	if errors.As(err, &errURLNotFound) {
+		h.logger.Error("Link not found", zap.String("code", code))
+		return SendNotFoundResponse(ctx, ErrLinkNotFound, ErrDescriptionLinkNotFound,
+			WithDetail(fmt.Sprintf("no link found for code %q", code)))
+	}
+
+	if err != nil {
+		h.logger.Error("Failed to get link", zap.Error(err))
+		return SendBadRequestResponse(ctx, ErrFailedToGetLink, ErrDescriptionFailedToGetLink)
+	}
+
+	return SendSuccessResponse(ctx, h.toLinkResponse(ctx, link))
+}
+
+func (h *LinksHandler) toLinkResponse(ctx echo.Context, link *domain.Link) linktypes.Link {
+	response := linktypes.Link{
+		Code:        link.Code,
+		ShortUrl:    h.shortURLs.Build(ctx, link.Code),
+		Destination: link.Destination,
+		CreatedAt:   link.CreatedAt,
+		ExpiresAt:   link.ExpiresAt,
+	}
+
+	if link.Disabled {
+		response.Disabled = &link.Disabled
+	}
+
+	if link.Owner != "" {
+		response.Owner = &link.Owner
+	}
+
+	return response
+}
+
+func isAbsoluteURL(raw string) bool {
+	u, err := url.ParseRequestURI(raw)
+
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
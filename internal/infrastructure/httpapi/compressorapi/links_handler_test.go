@@ -0,0 +1,179 @@
+package compressorapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/domain/apperrors"
+	"github.com/AFK068/compressor/internal/infrastructure/httpapi/compressorapi"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+
+	linktypes "github.com/AFK068/compressor/internal/api/openapi/compressor/v2"
+	repomock "github.com/AFK068/compressor/internal/domain/mocks"
+)
+
+func Test_CreateLink_Success(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	repoMock.On("CreateLink", mock.Anything, &domain.Link{
+		Destination: "http://example.com",
+		ExpiresAt:   &expiresAt,
+		Owner:       "team-a",
+	}).Return(&domain.Link{
+		ID:          4,
+		Code:        "aaabb",
+		Destination: "http://example.com",
+		CreatedAt:   createdAt,
+		ExpiresAt:   &expiresAt,
+		Owner:       "team-a",
+	}, nil)
+
+	handler := compressorapi.NewLinksHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
+
+	body := `{"destination": "http://example.com", "expires_at": "` + expiresAt.Format(time.RFC3339) + `", "owner": "team-a"}`
+	req := httptest.NewRequest("POST", "http://sho.rt/v2/links", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	err := handler.CreateLink(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var link linktypes.Link
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &link))
+
+	assert.Equal(t, "aaabb", link.Code)
+	assert.Equal(t, "http://sho.rt/aaabb", link.ShortUrl)
+	assert.Equal(t, "http://example.com", link.Destination)
+	assert.Equal(t, createdAt, link.CreatedAt)
+	assert.True(t, expiresAt.Equal(*link.ExpiresAt))
+	assert.Equal(t, "team-a", *link.Owner)
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_CreateLink_InvalidDestination_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	handler := compressorapi.NewLinksHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
+
+	req := httptest.NewRequest("POST", "/v2/links", strings.NewReader(`{"destination": "example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	err := handler.CreateLink(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_CreateLink_ExpiredExpiry_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	handler := compressorapi.NewLinksHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
+
+	body := `{"destination": "http://example.com", "expires_at": "2000-01-01T00:00:00Z"}`
+	req := httptest.NewRequest("POST", "/v2/links", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	err := handler.CreateLink(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_CreateLink_RepositoryError_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	repoMock.On("CreateLink", mock.Anything, mock.Anything).Return(nil, &apperrors.ErrRepositoryIsFull{Message: "repository is full"})
+
+	handler := compressorapi.NewLinksHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
+
+	req := httptest.NewRequest("POST", "/v2/links", strings.NewReader(`{"destination": "http://example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	err := handler.CreateLink(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_GetLink_Success(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	repoMock.On("ResolveLink", mock.Anything, "aaabb").Return(&domain.Link{
+		ID:          4,
+		Code:        "aaabb",
+		Destination: "http://example.com",
+		CreatedAt:   time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}, nil)
+
+	handler := compressorapi.NewLinksHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
+
+	req := httptest.NewRequest("GET", "http://sho.rt/v2/links/aaabb", http.NoBody)
+	rec := httptest.NewRecorder()
+
+	c := echo.New().NewContext(req, rec)
+
+	err := handler.GetLink(c, "aaabb")
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var link linktypes.Link
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &link))
+
+	assert.Equal(t, "http://sho.rt/aaabb", link.ShortUrl)
+	assert.Nil(t, link.ExpiresAt)
+	assert.Nil(t, link.Owner)
+
+	repoMock.AssertExpectations(t)
+}
+
+func Test_GetLink_NotFound_Failure(t *testing.T) {
+	repoMock := repomock.NewRepository(t)
+
+	repoMock.On("ResolveLink", mock.Anything, "aaabb").Return(nil, &apperrors.ErrURLNotFound{Message: "url not found"})
+
+	handler := compressorapi.NewLinksHandler(repoMock, newShortURLBuilder(t), zap.NewNop())
+
+	req := httptest.NewRequest("GET", "/v2/links/aaabb", http.NoBody)
+	rec := httptest.NewRecorder()
+
+	c := echo.New().NewContext(req, rec)
+
+	err := handler.GetLink(c, "aaabb")
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	repoMock.AssertExpectations(t)
+}
@@ -0,0 +1,91 @@
+package compressorapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/labstack/echo/v4"
+)
+
+// ShortURLBuilder turns codes into absolute short URLs, either under the configured public base URL
+// or under the scheme and host the request was made to.
+type ShortURLBuilder struct {
+	settings atomic.Pointer[shortURLSettings]
+}
+
+type shortURLSettings struct {
+	baseURL    string
+	trustProxy bool
+}
+
+func NewShortURLBuilder(cfg *config.Config) (*ShortURLBuilder, error) {
+	b := &ShortURLBuilder{}
+
+	if err := b.Reconfigure(cfg); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Reconfigure applies the public base URL and trust_proxy of cfg to the URLs built from now on.
+func (b *ShortURLBuilder) Reconfigure(cfg *config.Config) error {
+	baseURL := strings.TrimRight(cfg.Shortener.PublicBaseURL, "/")
+
+	if baseURL != "" {
+		u, err := url.Parse(baseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("public base url %q must be an absolute URL", cfg.Shortener.PublicBaseURL)
+		}
+	}
+
+	b.settings.Store(&shortURLSettings{
+		baseURL:    baseURL,
+		trustProxy: cfg.Shortener.TrustProxy,
+	})
+
+	return nil
+}
+
+func (b *ShortURLBuilder) Build(ctx echo.Context, code string) string {
+	settings := b.settings.Load()
+
+	if settings.baseURL != "" {
+		return settings.baseURL + "/" + code
+	}
+
+	return settings.requestBaseURL(ctx.Request()) + "/" + code
+}
+
+func (b *shortURLSettings) requestBaseURL(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+
+	host := req.Host
+
+	if b.trustProxy {
+		// Other schemes would turn the short URLs into links a client should not follow.
+		if proto := strings.ToLower(firstHeaderValue(req, echo.HeaderXForwardedProto)); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+
+		if forwardedHost := firstHeaderValue(req, "X-Forwarded-Host"); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+
+	return scheme + "://" + host
+}
+
+// firstHeaderValue returns the value added by the proxy closest to the client.
+func firstHeaderValue(req *http.Request, header string) string {
+	value, _, _ := strings.Cut(req.Header.Get(header), ",")
+
+	return strings.TrimSpace(value)
+}
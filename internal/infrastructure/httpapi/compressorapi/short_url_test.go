@@ -0,0 +1,70 @@
+package compressorapi_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/infrastructure/httpapi/compressorapi"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newShortURLBuilder(t *testing.T) *compressorapi.ShortURLBuilder {
+	builder, err := compressorapi.NewShortURLBuilder(&config.Config{})
+	assert.NoError(t, err)
+
+	return builder
+}
+
+func newForwardedContext() echo.Context {
+	req := httptest.NewRequest("POST", "http://internal:8080/url", http.NoBody)
+	req.Header.Set("X-Forwarded-Host", "sho.rt, proxy.internal")
+	req.Header.Set("X-Forwarded-Proto", "https")
+
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
+
+func Test_ShortURLBuilder_PublicBaseURL_Success(t *testing.T) {
+	builder, err := compressorapi.NewShortURLBuilder(&config.Config{
+		Shortener: config.Shortener{PublicBaseURL: "https://sho.rt/s/", TrustProxy: true},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "https://sho.rt/s/aaabb", builder.Build(newForwardedContext(), "aaabb"))
+}
+
+func Test_ShortURLBuilder_TrustProxy_Success(t *testing.T) {
+	builder, err := compressorapi.NewShortURLBuilder(&config.Config{
+		Shortener: config.Shortener{TrustProxy: true},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "https://sho.rt/aaabb", builder.Build(newForwardedContext(), "aaabb"))
+}
+
+func Test_ShortURLBuilder_TrustProxy_UnknownProto_Success(t *testing.T) {
+	builder, err := compressorapi.NewShortURLBuilder(&config.Config{
+		Shortener: config.Shortener{TrustProxy: true},
+	})
+	assert.NoError(t, err)
+
+	c := newForwardedContext()
+	c.Request().Header.Set("X-Forwarded-Proto", "javascript")
+
+	assert.Equal(t, "http://sho.rt/aaabb", builder.Build(c, "aaabb"))
+}
+
+func Test_ShortURLBuilder_UntrustedProxy_Success(t *testing.T) {
+	builder := newShortURLBuilder(t)
+
+	assert.Equal(t, "http://internal:8080/aaabb", builder.Build(newForwardedContext(), "aaabb"))
+}
+
+func Test_NewShortURLBuilder_RelativeBaseURL_Failure(t *testing.T) {
+	_, err := compressorapi.NewShortURLBuilder(&config.Config{
+		Shortener: config.Shortener{PublicBaseURL: "sho.rt"},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+// Package linkio streams links in and out of a repository as CSV or JSON Lines.
+package linkio
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/AFK068/compressor/internal/domain"
+)
+
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+
+	MIMETextCSV          = "text/csv"
+	MIMEApplicationJSONL = "application/x-ndjson"
+	// MIMEApplicationJSONLines is accepted on import as an alias of MIMEApplicationJSONL.
+	MIMEApplicationJSONLines = "application/jsonl"
+)
+
+// Columns is the CSV header. Only code and destination are required when importing.
+var Columns = []string{"code", "destination", "created_at", "expires_at", "owner", "disabled"}
+
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(s); format {
+	case FormatCSV, FormatJSONL:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown format %q, expected csv or jsonl", s)
+	}
+}
+
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return MIMETextCSV
+	}
+
+	return MIMEApplicationJSONL
+}
+
+// Record is the JSON Lines representation of a link.
+type Record struct {
+	Code        string     `json:"code"`
+	Destination string     `json:"destination"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Owner       string     `json:"owner,omitempty"`
+	Disabled    bool       `json:"disabled"`
+}
+
+func newRecord(link *domain.Link) *Record {
+	return &Record{
+		Code:        link.Code,
+		Destination: link.Destination,
+		CreatedAt:   link.CreatedAt,
+		ExpiresAt:   link.ExpiresAt,
+		Owner:       link.Owner,
+		Disabled:    link.Disabled,
+	}
+}
+
+func (r *Record) link() *domain.Link {
+	return &domain.Link{
+		Code:        r.Code,
+		Destination: r.Destination,
+		CreatedAt:   r.CreatedAt,
+		ExpiresAt:   r.ExpiresAt,
+		Owner:       r.Owner,
+		Disabled:    r.Disabled,
+	}
+}
+
+func (r *Record) validate() error {
+	switch {
+	case r.Code == "":
+		return fmt.Errorf("code is required")
+	case r.Destination == "":
+		return fmt.Errorf("destination is required")
+	case !isAbsoluteURL(r.Destination):
+		return fmt.Errorf("destination %q is not an absolute URL", r.Destination)
+	default:
+		return nil
+	}
+}
+
+func isAbsoluteURL(raw string) bool {
+	u, err := url.ParseRequestURI(raw)
+
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
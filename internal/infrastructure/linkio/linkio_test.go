@@ -0,0 +1,147 @@
+package linkio_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/domain/apperrors"
+	"github.com/AFK068/compressor/internal/infrastructure/linkio"
+	"github.com/AFK068/compressor/internal/infrastructure/repository/inmemoryrepo"
+	"github.com/AFK068/compressor/pkg/shortener"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRepository(t *testing.T) *inmemoryrepo.InMemoryRepository {
+	s, err := shortener.NewShortener("abcdefgh", 4)
+	assert.NoError(t, err)
+
+	return inmemoryrepo.New(s, 100)
+}
+
+func Test_ExportImport_RoundTrip_Success(t *testing.T) {
+	for _, format := range []linkio.Format{linkio.FormatCSV, linkio.FormatJSONL} {
+		t.Run(string(format), func(t *testing.T) {
+			ctx := context.Background()
+			source := newRepository(t)
+			expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+			_, err := source.CreateLink(ctx, &domain.Link{Destination: "http://example.com/a,b", Owner: "team-a"})
+			assert.NoError(t, err)
+
+			second, err := source.CreateLink(ctx, &domain.Link{Destination: "http://example.com/2", ExpiresAt: &expiresAt})
+			assert.NoError(t, err)
+			assert.NoError(t, source.DisableLink(ctx, second.Code))
+
+			var buffer bytes.Buffer
+
+			exported, err := linkio.Export(ctx, source, linkio.NewWriter(&buffer, format))
+			assert.NoError(t, err)
+			assert.Equal(t, 2, exported)
+
+			target := newRepository(t)
+
+			report, err := linkio.Import(ctx, target, linkio.NewReader(&buffer, format), func(rowErr *linkio.RowError) {
+				t.Errorf("unexpected row error: %v", rowErr)
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, linkio.ImportReport{Imported: 2}, report)
+
+			want, err := source.ListLinks(ctx, domain.ListLinksParams{Limit: 10})
+			assert.NoError(t, err)
+
+			got, err := target.ListLinks(ctx, domain.ListLinksParams{Limit: 10})
+			assert.NoError(t, err)
+
+			assert.Len(t, got, len(want))
+
+			for i := range want {
+				assert.Equal(t, want[i].Code, got[i].Code)
+				assert.Equal(t, want[i].Destination, got[i].Destination)
+				assert.True(t, want[i].CreatedAt.Equal(got[i].CreatedAt))
+				assert.Equal(t, want[i].Owner, got[i].Owner)
+				assert.Equal(t, want[i].Disabled, got[i].Disabled)
+			}
+
+			assert.True(t, expiresAt.Equal(*got[1].ExpiresAt))
+		})
+	}
+}
+
+func Test_Import_ReportsRowErrors_Success(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+
+	input := strings.Join([]string{
+		"destination,code,owner",
+		"http://example.com/1,aaad,team-a",
+		"http://example.com/2,aaad,team-a",
+		"http://example.com/1,aaae,team-a",
+		"http://example.com/3,zz,team-a",
+		",aaaf,team-a",
+		"example.com/5,aaah,team-a",
+		"http://example.com/4,aaag,team-b",
+	}, "\n")
+
+	var rowErrors []*linkio.RowError
+
+	report, err := linkio.Import(ctx, repo, linkio.NewReader(strings.NewReader(input), linkio.FormatCSV), func(rowErr *linkio.RowError) {
+		rowErrors = append(rowErrors, rowErr)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, linkio.ImportReport{Imported: 3, Failed: 4}, report)
+
+	assert.Len(t, rowErrors, 4)
+	assert.Equal(t, 3, rowErrors[0].Line)
+	assert.IsType(t, &apperrors.ErrLinkConflict{}, rowErrors[0].Err)
+	assert.ErrorIs(t, rowErrors[1], shortener.ErrInvalidDecoderLength)
+	assert.Equal(t, "aaaf", rowErrors[2].Code)
+	assert.Equal(t, 7, rowErrors[3].Line)
+	assert.Equal(t, "aaah", rowErrors[3].Code)
+
+	link, err := repo.GetLink(ctx, "aaag")
+	assert.NoError(t, err)
+	assert.Equal(t, "team-b", link.Owner)
+
+	// The second link to a destination is imported, but the destination stays shortened as the first.
+	link, err = repo.GetLink(ctx, "aaae")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com/1", link.Destination)
+
+	link, err = repo.CreateLink(ctx, &domain.Link{Destination: "http://example.com/1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "aaad", link.Code)
+}
+
+func Test_Import_AdvancesIDs_Success(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+
+	input := `{"code":"aabb","destination":"http://example.com/old"}` + "\n\n" + `not json` + "\n"
+
+	var rowErrors []*linkio.RowError
+
+	report, err := linkio.Import(ctx, repo, linkio.NewReader(strings.NewReader(input), linkio.FormatJSONL), func(rowErr *linkio.RowError) {
+		rowErrors = append(rowErrors, rowErr)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, linkio.ImportReport{Imported: 1, Failed: 1}, report)
+	assert.Equal(t, 3, rowErrors[0].Line)
+
+	created, err := repo.CreateLink(ctx, &domain.Link{Destination: "http://example.com/new"})
+	assert.NoError(t, err)
+	assert.Equal(t, "aabc", created.Code)
+}
+
+func Test_Import_MissingColumn_Failure(t *testing.T) {
+	_, err := linkio.Import(
+		context.Background(),
+		newRepository(t),
+		linkio.NewReader(strings.NewReader("url\nhttp://example.com\n"), linkio.FormatCSV),
+		func(*linkio.RowError) {},
+	)
+	assert.Error(t, err)
+}
@@ -0,0 +1,236 @@
+package linkio
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/domain/apperrors"
+	"github.com/AFK068/compressor/pkg/shortener"
+)
+
+const (
+	MaxJSONLLineSize = 1 << 20
+)
+
+// RowError describes a row that was skipped, Line is the line of the row in the input.
+type RowError struct {
+	Line int
+	Code string
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+type Reader interface {
+	// Read returns the next link, a *RowError for a malformed row, or io.EOF after the last row.
+	Read() (*domain.Link, int, error)
+}
+
+func NewReader(r io.Reader, format Format) Reader {
+	if format == FormatCSV {
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+
+		return &csvReader{r: reader}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), MaxJSONLLineSize)
+
+	return &jsonlReader{scanner: scanner}
+}
+
+type ImportReport struct {
+	Imported int
+	Failed   int
+}
+
+// Import stores every link read from r under its own code. Rows that cannot be imported, such as
+// malformed rows or taken codes, are passed to onError and skipped; any other error stops the import.
+func Import(ctx context.Context, repo domain.Repository, r Reader, onError func(*RowError)) (ImportReport, error) {
+	var report ImportReport
+
+	for {
+		link, line, err := r.Read()
+		if err == io.EOF {
+			return report, nil
+		}
+
+		if err == nil {
+			_, err = repo.ImportLink(ctx, link)
+			if err != nil && isRowError(err) {
+				err = &RowError{Line: line, Code: link.Code, Err: err}
+			}
+		}
+
+		var rowErr *RowError
+
+		switch {
+		case err == nil:
+			report.Imported++
+		case errors.As(err, &rowErr):
+			report.Failed++
+
+			onError(rowErr)
+		default:
+			return report, err
+		}
+	}
+}
+
+func isRowError(err error) bool {
+	var (
+		errLinkConflict     *apperrors.ErrLinkConflict
+		errRepositoryIsFull *apperrors.ErrRepositoryIsFull
+	)
+
+	return errors.As(err, &errLinkConflict) ||
+		errors.As(err, &errRepositoryIsFull) ||
+		errors.Is(err, shortener.ErrInvalidDecoderLength) ||
+		errors.Is(err, shortener.ErrInvalidCharacter)
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func (c *csvReader) Read() (*domain.Link, int, error) {
+	if c.columns == nil {
+		if err := c.readHeader(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	fields, err := c.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, parseErr.StartLine, &RowError{Line: parseErr.StartLine, Err: parseErr.Err}
+		}
+
+		return nil, 0, err
+	}
+
+	line, _ := c.r.FieldPos(0)
+
+	record, err := c.parse(fields)
+	if err != nil {
+		return nil, line, &RowError{Line: line, Code: record.Code, Err: err}
+	}
+
+	return record.link(), line, nil
+}
+
+func (c *csvReader) readHeader() error {
+	header, err := c.r.Read()
+	if err == io.EOF {
+		return io.EOF
+	}
+
+	if err != nil {
+		return fmt.Errorf("reading csv header: %w", err)
+	}
+
+	c.columns = make(map[string]int, len(header))
+	for i, name := range header {
+		c.columns[strings.TrimSpace(name)] = i
+	}
+
+	for _, required := range []string{"code", "destination"} {
+		if _, ok := c.columns[required]; !ok {
+			return fmt.Errorf("csv header has no %q column", required)
+		}
+	}
+
+	return nil
+}
+
+func (c *csvReader) parse(fields []string) (*Record, error) {
+	field := func(name string) string {
+		if i, ok := c.columns[name]; ok && i < len(fields) {
+			return strings.TrimSpace(fields[i])
+		}
+
+		return ""
+	}
+
+	record := &Record{
+		Code:        field("code"),
+		Destination: field("destination"),
+		Owner:       field("owner"),
+	}
+
+	var err error
+
+	if value := field("created_at"); value != "" {
+		if record.CreatedAt, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			return record, fmt.Errorf("invalid created_at: %w", err)
+		}
+	}
+
+	if value := field("expires_at"); value != "" {
+		expiresAt, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return record, fmt.Errorf("invalid expires_at: %w", err)
+		}
+
+		record.ExpiresAt = &expiresAt
+	}
+
+	if value := field("disabled"); value != "" {
+		if record.Disabled, err = strconv.ParseBool(value); err != nil {
+			return record, fmt.Errorf("invalid disabled: %w", err)
+		}
+	}
+
+	return record, record.validate()
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (j *jsonlReader) Read() (*domain.Link, int, error) {
+	for j.scanner.Scan() {
+		j.line++
+
+		data := j.scanner.Bytes()
+		if len(strings.TrimSpace(string(data))) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, j.line, &RowError{Line: j.line, Err: err}
+		}
+
+		if err := record.validate(); err != nil {
+			return nil, j.line, &RowError{Line: j.line, Code: record.Code, Err: err}
+		}
+
+		return record.link(), j.line, nil
+	}
+
+	if err := j.scanner.Err(); err != nil {
+		return nil, j.line, err
+	}
+
+	return nil, j.line, io.EOF
+}
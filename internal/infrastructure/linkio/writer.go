@@ -0,0 +1,121 @@
+package linkio
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/AFK068/compressor/internal/domain"
+)
+
+const (
+	ExportPageSize = 1000
+)
+
+type Writer interface {
+	Write(link *domain.Link) error
+	// Flush writes buffered links to the underlying writer.
+	Flush() error
+}
+
+func NewWriter(w io.Writer, format Format) Writer {
+	if format == FormatCSV {
+		return &csvWriter{w: csv.NewWriter(w)}
+	}
+
+	buffered := bufio.NewWriter(w)
+
+	return &jsonlWriter{buffered: buffered, encoder: json.NewEncoder(buffered)}
+}
+
+// Export writes every link of repo to w in id order and returns the number of exported links.
+// Each page is flushed, so the output is streamed rather than built in memory.
+func Export(ctx context.Context, repo domain.Repository, w Writer) (int, error) {
+	exported := 0
+	params := domain.ListLinksParams{Limit: ExportPageSize}
+
+	for {
+		page, err := repo.ListLinks(ctx, params)
+		if err != nil {
+			return exported, err
+		}
+
+		for _, link := range page {
+			if err := w.Write(link); err != nil {
+				return exported, err
+			}
+		}
+
+		exported += len(page)
+
+		if err := w.Flush(); err != nil {
+			return exported, err
+		}
+
+		if len(page) < params.Limit {
+			return exported, nil
+		}
+
+		params.FromID = page[len(page)-1].ID + 1
+	}
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvWriter) Write(link *domain.Link) error {
+	if !c.headerWritten {
+		if err := c.w.Write(Columns); err != nil {
+			return err
+		}
+
+		c.headerWritten = true
+	}
+
+	expiresAt := ""
+	if link.ExpiresAt != nil {
+		expiresAt = link.ExpiresAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	return c.w.Write([]string{
+		link.Code,
+		link.Destination,
+		link.CreatedAt.UTC().Format(time.RFC3339Nano),
+		expiresAt,
+		link.Owner,
+		strconv.FormatBool(link.Disabled),
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	if !c.headerWritten {
+		if err := c.w.Write(Columns); err != nil {
+			return err
+		}
+
+		c.headerWritten = true
+	}
+
+	c.w.Flush()
+
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+}
+
+func (j *jsonlWriter) Write(link *domain.Link) error {
+	return j.encoder.Encode(newRecord(link))
+}
+
+func (j *jsonlWriter) Flush() error {
+	return j.buffered.Flush()
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/AFK068/compressor/internal/infrastructure/repository/dynamorepo"
+	"github.com/AFK068/compressor/pkg/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// DynamoDBSettings configure the dynamodb storage, read from storage.dynamodb. Credentials are read
+// like by the AWS CLI, from the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY variables, the shared
+// credentials file or the role of the instance.
+type DynamoDBSettings struct {
+	// Table holds the links, the leased ids and the idempotency records. It is created on startup
+	// unless migrations.disable_auto_migrate is set.
+	Table  string `yaml:"table" env:"DYNAMODB_TABLE" env-default:"compressor"`
+	Region string `yaml:"region" env:"DYNAMODB_REGION" env-default:"us-east-1"`
+	// Endpoint replaces the endpoint of the region, e.g. with http://localhost:8000 for DynamoDB Local.
+	Endpoint string `yaml:"endpoint" env:"DYNAMODB_ENDPOINT"`
+}
+
+// dynamoDBDriver creates the table on startup, like pending changesets are applied for postgres,
+// unless migrations.disable_auto_migrate is set.
+type dynamoDBDriver struct{}
+
+func (dynamoDBDriver) NewSettings() any {
+	return &DynamoDBSettings{}
+}
+
+func (dynamoDBDriver) Open(ctx context.Context, opts storage.Options) (storage.Backend, func(), error) {
+	cfg, log := opts.Config, opts.Logger
+	settings := cfg.Storage.DriverSettings.(*DynamoDBSettings)
+
+	awsConfig := aws.NewConfig().WithRegion(settings.Region)
+	if settings.Endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(settings.Endpoint)
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *awsConfig,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating aws session: %w", err)
+	}
+
+	client := dynamodb.New(sess)
+
+	if !cfg.Migration.DisableAutoMigrate {
+		if err := dynamorepo.CreateTable(ctx, client, settings.Table); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	repo := dynamorepo.New(client, settings.Table, opts.Shortener, cfg.Storage.MaxSize,
+		dynamorepo.WithIDAllocator(newIDAllocator(cfg, dynamorepo.NewIDLeaser(client, settings.Table))),
+	)
+
+	return repo, func() { closeIDs(repo, log) }, nil
+}
+
+func (s *DynamoDBSettings) Validate() error {
+	errs := &fieldErrors{}
+
+	if s.Table == "" {
+		errs.add("table", errors.New("table is required"))
+	}
+
+	if s.Region == "" {
+		errs.add("region", errors.New("region is required"))
+	}
+
+	if s.Endpoint != "" {
+		u, err := url.Parse(s.Endpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			errs.add("endpoint", fmt.Errorf("%q must be an absolute URL", s.Endpoint))
+		}
+	}
+
+	return errs.err()
+}
+
+// Location returns the endpoint, or the region when it has none, and the table.
+func (s *DynamoDBSettings) Location() string {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = s.Region
+	}
+
+	return endpoint + "/" + s.Table
+}
@@ -0,0 +1,30 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/infrastructure/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DynamoDBSettings_Success(t *testing.T) {
+	t.Setenv("STORAGE_TYPE", "dynamodb")
+	t.Setenv("DYNAMODB_ENDPOINT", "http://localhost:8000")
+
+	cfg, err := config.NewConfig(TestConfigPath)
+	assert.NoError(t, err)
+
+	settings := cfg.Storage.DriverSettings.(*repository.DynamoDBSettings)
+	assert.Equal(t, "compressor", settings.Table)
+	assert.Equal(t, "http://localhost:8000", settings.Endpoint)
+}
+
+func Test_DynamoDBSettings_Validate_Failure(t *testing.T) {
+	t.Setenv("STORAGE_TYPE", "dynamodb")
+	t.Setenv("DYNAMODB_TABLE", "")
+	t.Setenv("DYNAMODB_ENDPOINT", "localhost")
+
+	_, err := config.NewConfig(TestConfigPath)
+	assert.Equal(t, []string{"storage.dynamodb.table", "storage.dynamodb.endpoint"}, validationPaths(t, err))
+}
@@ -0,0 +1,595 @@
+package dynamorepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/domain/apperrors"
+	"github.com/AFK068/compressor/pkg/idalloc"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+)
+
+// DynamoDBRepository stores the links in a single table, see CreateTable. Links are read with
+// strongly consistent reads, so a link is resolvable as soon as it was created.
+//
+// A destination is shortened once, also by concurrent requests, as every link is written together
+// with the item of its destination, see urlItem. Expired links are deleted by DynamoDB, usually
+// within a few days after they expired.
+type DynamoDBRepository struct {
+	client    dynamodbiface.DynamoDBAPI
+	table     *string
+	ids       *idalloc.Allocator
+	shortener domain.Shortener
+	maxSize   uint64
+}
+
+type Option func(r *DynamoDBRepository)
+
+// WithIDAllocator sets the allocator of the ids of new links, which leases blocks of
+// idalloc.DefaultBlockSize ids with an IDLeaser by default.
+func WithIDAllocator(ids *idalloc.Allocator) Option {
+	return func(r *DynamoDBRepository) {
+		r.ids = ids
+	}
+}
+
+func New(client dynamodbiface.DynamoDBAPI, table string, shortener domain.Shortener, maxSize uint64, opts ...Option) *DynamoDBRepository {
+	r := &DynamoDBRepository{
+		client:    client,
+		table:     aws.String(table),
+		ids:       idalloc.New(NewIDLeaser(client, table), maxSize),
+		shortener: shortener,
+		maxSize:   maxSize,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Close returns the ids leased by the repository but not used to the table, so other processes
+// use them.
+func (r *DynamoDBRepository) Close(ctx context.Context) error {
+	return r.ids.Close(ctx)
+}
+
+// WithTransaction implements domain.Transactor. Every operation is applied on its own and cannot be
+// rolled back, so it fails without running fn.
+func (r *DynamoDBRepository) WithTransaction(context.Context, func(ctx context.Context) error) error {
+	return &apperrors.ErrNotSupported{Message: "transactions are not supported by the dynamodb storage"}
+}
+
+func (r *DynamoDBRepository) SaveURL(ctx context.Context, originalURL string) (string, error) {
+	link, err := r.CreateLink(ctx, &domain.Link{Destination: originalURL})
+	if err != nil {
+		return "", err
+	}
+
+	return link.Code, nil
+}
+
+func (r *DynamoDBRepository) GetURL(ctx context.Context, shortenedURL string) (string, error) {
+	link, err := r.ResolveLink(ctx, shortenedURL)
+	if err != nil {
+		return "", err
+	}
+
+	if !link.IsResolvable(time.Now()) {
+		return "", &apperrors.ErrURLNotFound{Message: "url not found"}
+	}
+
+	return link.Destination, nil
+}
+
+func (r *DynamoDBRepository) CreateLink(ctx context.Context, link *domain.Link) (*domain.Link, error) {
+	for {
+		owner, existing, err := r.findDestination(ctx, link.Destination)
+		if err != nil {
+			return nil, err
+		}
+
+		// A disabled or expired link keeps its state, but the destination is shortened as a new link.
+		if existing != nil && existing.resolvable(time.Now()) {
+			return r.toLink(existing)
+		}
+
+		id, err := r.ids.Next(ctx)
+		if errors.Is(err, idalloc.ErrExhausted) {
+			return nil, &apperrors.ErrRepositoryIsFull{Message: "repository is full"}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		item := newLinkItem(id, link.Destination)
+		item.CreatedAt = time.Now().UTC()
+		item.Owner = link.Owner
+		item.setExpiresAt(link.ExpiresAt)
+
+		created, err := r.toLink(item)
+		if err != nil {
+			r.ids.Release(id)
+			return nil, err
+		}
+
+		err = r.putLink(ctx, item, owner)
+
+		switch {
+		case err == nil:
+			return created, nil
+		case errors.Is(err, errIDTaken):
+			// An imported link has the id, which is not handed out again.
+		case errors.Is(err, errDestinationTaken):
+			// A concurrent request stored a link to the destination, which is found next.
+			r.ids.Release(id)
+		default:
+			r.ids.Release(id)
+			return nil, err
+		}
+	}
+}
+
+// errIDTaken and errDestinationTaken are returned by putLink when a link with the id exists or the
+// item of the destination was changed since it was read.
+var (
+	errIDTaken          = errors.New("id is taken")
+	errDestinationTaken = errors.New("destination is taken")
+)
+
+// putLink stores the link together with the item of its destination, which replaces owner. When
+// owner is nil, the destination must not have an item.
+func (r *DynamoDBRepository) putLink(ctx context.Context, item *linkItem, owner *urlItem) error {
+	linkAV, err := dynamodbattribute.MarshalMap(item)
+	if err != nil {
+		return err
+	}
+
+	urlAV, err := dynamodbattribute.MarshalMap(newURLItem(item))
+	if err != nil {
+		return err
+	}
+
+	notExists := expression.AttributeNotExists(expression.Name(attrPK))
+
+	linkExpr, err := expression.NewBuilder().WithCondition(notExists).Build()
+	if err != nil {
+		return err
+	}
+
+	urlCondition := notExists
+	if owner != nil {
+		urlCondition = expression.Name(attrLinkID).Equal(expression.Value(owner.LinkID))
+	}
+
+	urlExpr, err := expression.NewBuilder().WithCondition(urlCondition).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{{
+			Put: &dynamodb.Put{
+				TableName:                r.table,
+				Item:                     linkAV,
+				ConditionExpression:      linkExpr.Condition(),
+				ExpressionAttributeNames: linkExpr.Names(),
+			},
+		}, {
+			Put: &dynamodb.Put{
+				TableName:                 r.table,
+				Item:                      urlAV,
+				ConditionExpression:       urlExpr.Condition(),
+				ExpressionAttributeNames:  urlExpr.Names(),
+				ExpressionAttributeValues: urlExpr.Values(),
+			},
+		}},
+	})
+
+	var canceled *dynamodb.TransactionCanceledException
+	if !errors.As(err, &canceled) {
+		return err
+	}
+
+	// The reasons are in the order of the items. Without them the id is given up, which costs an
+	// id at worst.
+	reasons := canceled.CancellationReasons
+	if len(reasons) == 2 && aws.StringValue(reasons[0].Code) == cancellationReasonNone {
+		return errDestinationTaken
+	}
+
+	return errIDTaken
+}
+
+// cancellationReasonNone is the reason given for the items of a canceled transaction that did not
+// cancel it.
+const cancellationReasonNone = "None"
+
+// findDestination returns the item of the destination and the link it points to, or nil when there
+// are none. The link is nil when it was deleted but the item of the destination was not.
+func (r *DynamoDBRepository) findDestination(ctx context.Context, destination string) (*urlItem, *linkItem, error) {
+	out, err := r.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      r.table,
+		Key:            itemKey(urlPartition(destination), 0),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil || out.Item == nil {
+		return nil, nil, err
+	}
+
+	var owner urlItem
+	if err := dynamodbattribute.UnmarshalMap(out.Item, &owner); err != nil {
+		return nil, nil, err
+	}
+
+	link, err := r.getItem(ctx, owner.LinkID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &owner, link, nil
+}
+
+func (r *DynamoDBRepository) GetLink(ctx context.Context, code string) (*domain.Link, error) {
+	id, err := r.decode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := r.getItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if item == nil {
+		return nil, &apperrors.ErrURLNotFound{Message: "url not found"}
+	}
+
+	return newLink(item, code), nil
+}
+
+// ResolveLink is GetLink, the repository has no replicas.
+func (r *DynamoDBRepository) ResolveLink(ctx context.Context, code string) (*domain.Link, error) {
+	return r.GetLink(ctx, code)
+}
+
+func (r *DynamoDBRepository) decode(code string) (uint64, error) {
+	id, err := r.shortener.Decode(code)
+	if err != nil {
+		return 0, err
+	}
+
+	if id >= r.maxSize {
+		return 0, &apperrors.ErrURLNotFound{Message: "url not found"}
+	}
+
+	return id, nil
+}
+
+// getItem returns the link with the id, or nil when there is none.
+func (r *DynamoDBRepository) getItem(ctx context.Context, id uint64) (*linkItem, error) {
+	out, err := r.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      r.table,
+		Key:            linkKey(id),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil || out.Item == nil {
+		return nil, err
+	}
+
+	var item linkItem
+	if err := dynamodbattribute.UnmarshalMap(out.Item, &item); err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+func (r *DynamoDBRepository) DeleteLink(ctx context.Context, code string) error {
+	id, err := r.shortener.Decode(code)
+	if err != nil {
+		return err
+	}
+
+	expr, err := expression.NewBuilder().WithCondition(expression.AttributeExists(expression.Name(attrPK))).Build()
+	if err != nil {
+		return err
+	}
+
+	out, err := r.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName:                r.table,
+		Key:                      linkKey(id),
+		ConditionExpression:      expr.Condition(),
+		ExpressionAttributeNames: expr.Names(),
+		ReturnValues:             aws.String(dynamodb.ReturnValueAllOld),
+	})
+	if isAWSError(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return &apperrors.ErrURLNotFound{Message: "url not found"}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var item linkItem
+	if err := dynamodbattribute.UnmarshalMap(out.Attributes, &item); err != nil {
+		return err
+	}
+
+	return r.deleteDestination(ctx, &item)
+}
+
+// deleteDestination deletes the item of the destination of the link unless it points to another
+// link. An item left behind, e.g. when this fails, points to no link and is replaced by CreateLink.
+func (r *DynamoDBRepository) deleteDestination(ctx context.Context, link *linkItem) error {
+	expr, err := expression.NewBuilder().
+		WithCondition(expression.Name(attrLinkID).Equal(expression.Value(link.ID))).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 r.table,
+		Key:                       itemKey(urlPartition(link.URL), 0),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if isAWSError(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return nil
+	}
+
+	return err
+}
+
+func (r *DynamoDBRepository) ImportLink(ctx context.Context, link *domain.Link) (*domain.Link, error) {
+	id, err := r.shortener.Decode(link.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	if id >= r.maxSize {
+		return nil, &apperrors.ErrRepositoryIsFull{Message: "code is beyond the repository capacity"}
+	}
+
+	if err := r.ids.Advance(ctx, id+1); err != nil {
+		return nil, err
+	}
+
+	item := newLinkItem(id, link.Destination)
+	item.CreatedAt = link.CreatedAt
+	item.Owner = link.Owner
+	item.Disabled = link.Disabled
+	item.setExpiresAt(link.ExpiresAt)
+
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = time.Now().UTC()
+	}
+
+	for {
+		err := r.importItem(ctx, item)
+
+		switch {
+		case errors.Is(err, errIDTaken):
+			return nil, &apperrors.ErrLinkConflict{Message: fmt.Sprintf("code %q already exists", link.Code)}
+		case errors.Is(err, errDestinationTaken):
+			// The destination was shortened meanwhile, which is read again.
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		return newLink(item, link.Code), nil
+	}
+}
+
+// importItem stores the imported link. Like a new link, it replaces a disabled or expired link of
+// the destination, or an item of the destination left behind by a deleted link. Otherwise it is stored without the item of the destination, so it resolves by
+// its code only.
+func (r *DynamoDBRepository) importItem(ctx context.Context, item *linkItem) error {
+	owner, existing, err := r.findDestination(ctx, item.URL)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if existing == nil || (item.resolvable(now) && !existing.resolvable(now)) {
+		return r.putLink(ctx, item, owner)
+	}
+
+	av, err := dynamodbattribute.MarshalMap(item)
+	if err != nil {
+		return err
+	}
+
+	expr, err := expression.NewBuilder().WithCondition(expression.AttributeNotExists(expression.Name(attrPK))).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:                r.table,
+		Item:                     av,
+		ConditionExpression:      expr.Condition(),
+		ExpressionAttributeNames: expr.Names(),
+	})
+	if isAWSError(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return errIDTaken
+	}
+
+	return err
+}
+
+// ListLinks queries the partitions of the ids in order, up to the highest id leased.
+func (r *DynamoDBRepository) ListLinks(ctx context.Context, params domain.ListLinksParams) ([]*domain.Link, error) {
+	next, err := nextID(ctx, r.client, r.table)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]*domain.Link, 0, params.Limit)
+
+	for bucket := params.FromID / linkBucketSize; bucket*linkBucketSize < next && len(links) < params.Limit; bucket++ {
+		keyCondition := expression.Key(attrPK).Equal(expression.Value(linkPrefix + strconv.FormatUint(bucket, 10))).
+			And(expression.Key(attrSK).GreaterThanEqual(expression.Value(params.FromID)))
+
+		expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).Build()
+		if err != nil {
+			return nil, err
+		}
+
+		input := &dynamodb.QueryInput{
+			TableName:                 r.table,
+			KeyConditionExpression:    expr.KeyCondition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			ConsistentRead:            aws.Bool(true),
+		}
+
+		var pageErr error
+
+		err = r.client.QueryPagesWithContext(ctx, input, func(out *dynamodb.QueryOutput, _ bool) bool {
+			for _, av := range out.Items {
+				var item linkItem
+				if pageErr = dynamodbattribute.UnmarshalMap(av, &item); pageErr != nil {
+					return false
+				}
+
+				var link *domain.Link
+				if link, pageErr = r.toLink(&item); pageErr != nil {
+					return false
+				}
+
+				links = append(links, link)
+				if len(links) == params.Limit {
+					return false
+				}
+			}
+
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if pageErr != nil {
+			return nil, pageErr
+		}
+	}
+
+	return links, nil
+}
+
+func (r *DynamoDBRepository) DisableLink(ctx context.Context, code string) error {
+	id, err := r.shortener.Decode(code)
+	if err != nil {
+		return err
+	}
+
+	expr, err := expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name("disabled"), expression.Value(true))).
+		WithCondition(expression.AttributeExists(expression.Name(attrPK))).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 r.table,
+		Key:                       linkKey(id),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if isAWSError(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return &apperrors.ErrURLNotFound{Message: "url not found"}
+	}
+
+	return err
+}
+
+// Stats scans the whole table, so it costs a read of every link.
+func (r *DynamoDBRepository) Stats(ctx context.Context) (*domain.Stats, error) {
+	expr, err := expression.NewBuilder().
+		WithFilter(expression.BeginsWith(expression.Name(attrPK), linkPrefix)).
+		WithProjection(expression.NamesList(expression.Name("disabled"), expression.Name("expires_at"))).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &domain.Stats{Capacity: r.maxSize}
+	now := time.Now()
+
+	var pageErr error
+
+	err = r.client.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+		TableName:                 r.table,
+		FilterExpression:          expr.Filter(),
+		ProjectionExpression:      expr.Projection(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}, func(out *dynamodb.ScanOutput, _ bool) bool {
+		for _, av := range out.Items {
+			var item linkItem
+			if pageErr = dynamodbattribute.UnmarshalMap(av, &item); pageErr != nil {
+				return false
+			}
+
+			stats.Total++
+
+			switch {
+			case item.Disabled:
+				stats.Disabled++
+			case item.ExpiresAt != nil && !now.Before(*item.ExpiresAt):
+				stats.Expired++
+			default:
+				stats.Active++
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if pageErr != nil {
+		return nil, pageErr
+	}
+
+	return stats, nil
+}
+
+func (r *DynamoDBRepository) toLink(item *linkItem) (*domain.Link, error) {
+	code, err := r.shortener.Encode(item.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newLink(item, code), nil
+}
+
+func newLink(item *linkItem, code string) *domain.Link {
+	return &domain.Link{
+		ID:          item.ID,
+		Code:        code,
+		Destination: item.URL,
+		CreatedAt:   item.CreatedAt,
+		ExpiresAt:   item.ExpiresAt,
+		Owner:       item.Owner,
+		Disabled:    item.Disabled,
+	}
+}
@@ -0,0 +1,555 @@
+package dynamorepo_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/domain/apperrors"
+	"github.com/AFK068/compressor/internal/infrastructure/repository/dynamorepo"
+	"github.com/AFK068/compressor/internal/testcontainer"
+	"github.com/AFK068/compressor/pkg/shortener"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTable = "compressor"
+
+func setupTable(t testing.TB) (dynamodbiface.DynamoDBAPI, context.Context) {
+	ctx := context.Background()
+
+	testContainer, err := testcontainer.NewDynamoDBTestcontainer(ctx)
+	assert.NoError(t, err)
+
+	client, cleanup, err := testContainer.SetupTestDynamoDBContainer(ctx)
+	assert.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, cleanup())
+	})
+
+	assert.NoError(t, dynamorepo.CreateTable(ctx, client, testTable))
+
+	return client, ctx
+}
+
+func newShortener(t testing.TB) domain.Shortener {
+	s, err := shortener.NewShortener("abcdefgh", 4)
+	assert.NoError(t, err)
+
+	return s
+}
+
+func Test_CreateTable_Exists_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	assert.NoError(t, dynamorepo.CreateTable(ctx, client, testTable))
+}
+
+func Test_SaveURL_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	repo := dynamorepo.New(client, testTable, newShortener(t), 100)
+
+	short, err := repo.SaveURL(ctx, "originURL")
+	assert.NoError(t, err)
+
+	original, err := repo.GetURL(ctx, short)
+	assert.NoError(t, err)
+	assert.Equal(t, "originURL", original)
+
+	again, err := repo.SaveURL(ctx, "originURL")
+	assert.NoError(t, err)
+	assert.Equal(t, short, again)
+}
+
+func Test_SaveURL_RepoIsFull_Failure(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	repo := dynamorepo.New(client, testTable, newShortener(t), 2)
+
+	_, err := repo.SaveURL(ctx, "originURL1")
+	assert.NoError(t, err)
+
+	_, err = repo.SaveURL(ctx, "originURL2")
+	assert.NoError(t, err)
+
+	_, err = repo.SaveURL(ctx, "originURL3")
+	assert.IsType(t, &apperrors.ErrRepositoryIsFull{}, err)
+
+	// Existing destinations are still found.
+	_, err = repo.SaveURL(ctx, "originURL1")
+	assert.NoError(t, err)
+}
+
+func Test_GetURL_LinkNotFound_Failure(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	repo := dynamorepo.New(client, testTable, newShortener(t), 100)
+
+	_, err := repo.GetURL(ctx, "aaab")
+	assert.IsType(t, &apperrors.ErrURLNotFound{}, err)
+}
+
+func Test_CreateLink_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	repo := dynamorepo.New(client, testTable, newShortener(t), 100)
+
+	expiresAt := time.Now().Add(-time.Minute).UTC().Truncate(time.Millisecond)
+
+	created, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL", ExpiresAt: &expiresAt, Owner: "alice"})
+	assert.NoError(t, err)
+
+	link, err := repo.GetLink(ctx, created.Code)
+	assert.NoError(t, err)
+	assert.Equal(t, "originURL", link.Destination)
+	assert.Equal(t, "alice", link.Owner)
+	assert.True(t, expiresAt.Equal(*link.ExpiresAt))
+
+	// Expired links are kept until DynamoDB deletes them, but do not resolve.
+	_, err = repo.GetURL(ctx, created.Code)
+	assert.IsType(t, &apperrors.ErrURLNotFound{}, err)
+}
+
+func Test_CreateLink_DisabledExisting_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	repo := dynamorepo.New(client, testTable, newShortener(t), 100)
+
+	code, err := repo.SaveURL(ctx, "originURL")
+	assert.NoError(t, err)
+	assert.NoError(t, repo.DisableLink(ctx, code))
+
+	created, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, code, created.Code)
+	assert.False(t, created.Disabled)
+
+	again, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL"})
+	assert.NoError(t, err)
+	assert.Equal(t, created.Code, again.Code)
+
+	link, err := repo.GetLink(ctx, code)
+	assert.NoError(t, err)
+	assert.True(t, link.Disabled)
+}
+
+func Test_CreateLink_ExpiredExisting_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	repo := dynamorepo.New(client, testTable, newShortener(t), 100)
+
+	expiresAt := time.Now().Add(-time.Minute)
+
+	expired, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL", ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+
+	created, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, expired.Code, created.Code)
+
+	// Deleting the replaced link keeps the destination shortened as the new one.
+	assert.NoError(t, repo.DeleteLink(ctx, expired.Code))
+
+	again, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL"})
+	assert.NoError(t, err)
+	assert.Equal(t, created.Code, again.Code)
+}
+
+func Test_CreateLink_ConcurrentSameDestination_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	s := newShortener(t)
+	repos := []*dynamorepo.DynamoDBRepository{
+		dynamorepo.New(client, testTable, s, 1000),
+		dynamorepo.New(client, testTable, s, 1000),
+	}
+
+	const requests = 20
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		codes = make(map[string]struct{})
+	)
+
+	for i := range requests {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			link, err := repos[i%len(repos)].CreateLink(ctx, &domain.Link{Destination: "originURL"})
+			assert.NoError(t, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			codes[link.Code] = struct{}{}
+		}()
+	}
+
+	wg.Wait()
+
+	// Every request got the one link of the destination.
+	assert.Len(t, codes, 1)
+
+	stats, err := repos[0].Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), stats.Total)
+}
+
+func Test_DeleteLink_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	repo := dynamorepo.New(client, testTable, newShortener(t), 100)
+
+	code, err := repo.SaveURL(ctx, "originURL")
+	assert.NoError(t, err)
+
+	assert.NoError(t, repo.DeleteLink(ctx, code))
+
+	_, err = repo.GetLink(ctx, code)
+	assert.IsType(t, &apperrors.ErrURLNotFound{}, err)
+
+	err = repo.DeleteLink(ctx, code)
+	assert.IsType(t, &apperrors.ErrURLNotFound{}, err)
+
+	// Deleting the link frees its destination.
+	created, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, code, created.Code)
+}
+
+func Test_DisableLink_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	repo := dynamorepo.New(client, testTable, newShortener(t), 100)
+
+	code, err := repo.SaveURL(ctx, "originURL")
+	assert.NoError(t, err)
+
+	assert.NoError(t, repo.DisableLink(ctx, code))
+
+	_, err = repo.GetURL(ctx, code)
+	assert.IsType(t, &apperrors.ErrURLNotFound{}, err)
+
+	link, err := repo.GetLink(ctx, code)
+	assert.NoError(t, err)
+	assert.True(t, link.Disabled)
+
+	err = repo.DisableLink(ctx, "aaab")
+	assert.IsType(t, &apperrors.ErrURLNotFound{}, err)
+}
+
+func Test_ImportLink_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	s := newShortener(t)
+	repo := dynamorepo.New(client, testTable, s, 100)
+
+	code, err := s.Encode(50)
+	assert.NoError(t, err)
+
+	createdAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	imported, err := repo.ImportLink(ctx, &domain.Link{Code: code, Destination: "importedURL", CreatedAt: createdAt})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(50), imported.ID)
+	assert.Equal(t, createdAt, imported.CreatedAt)
+
+	_, err = repo.ImportLink(ctx, &domain.Link{Code: code, Destination: "otherURL"})
+	assert.IsType(t, &apperrors.ErrLinkConflict{}, err)
+
+	other, err := s.Encode(60)
+	assert.NoError(t, err)
+
+	// A second link to the destination is imported, but the destination stays shortened as the first.
+	_, err = repo.ImportLink(ctx, &domain.Link{Code: other, Destination: "importedURL"})
+	assert.NoError(t, err)
+
+	existing, err := repo.CreateLink(ctx, &domain.Link{Destination: "importedURL"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(50), existing.ID)
+
+	// The ids of new links are leased after the imported ones.
+	link, err := dynamorepo.New(client, testTable, s, 100).CreateLink(ctx, &domain.Link{Destination: "originURL"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(61), link.ID)
+}
+
+func Test_ImportLink_ReplacesDisabled_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	s := newShortener(t)
+	repo := dynamorepo.New(client, testTable, s, 100)
+
+	disabled, err := s.Encode(10)
+	assert.NoError(t, err)
+
+	replacement, err := s.Encode(20)
+	assert.NoError(t, err)
+
+	_, err = repo.ImportLink(ctx, &domain.Link{Code: disabled, Destination: "importedURL", Disabled: true})
+	assert.NoError(t, err)
+
+	_, err = repo.ImportLink(ctx, &domain.Link{Code: replacement, Destination: "importedURL"})
+	assert.NoError(t, err)
+
+	link, err := repo.CreateLink(ctx, &domain.Link{Destination: "importedURL"})
+	assert.NoError(t, err)
+	assert.Equal(t, replacement, link.Code)
+
+	// The disabled link is kept, and deleting it leaves the destination to the replacement.
+	link, err = repo.GetLink(ctx, disabled)
+	assert.NoError(t, err)
+	assert.True(t, link.Disabled)
+
+	assert.NoError(t, repo.DeleteLink(ctx, disabled))
+
+	link, err = repo.CreateLink(ctx, &domain.Link{Destination: "importedURL"})
+	assert.NoError(t, err)
+	assert.Equal(t, replacement, link.Code)
+}
+
+func Test_CreateLink_SkipsImportedID_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	s := newShortener(t)
+	repo := dynamorepo.New(client, testTable, s, 100)
+
+	_, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL1"})
+	assert.NoError(t, err)
+
+	code, err := s.Encode(1)
+	assert.NoError(t, err)
+
+	// The id of the imported link belongs to the block the repository already leased.
+	_, err = repo.ImportLink(ctx, &domain.Link{Code: code, Destination: "importedURL"})
+	assert.NoError(t, err)
+
+	link, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL2"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), link.ID)
+}
+
+func Test_ListLinks_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	s := newShortener(t)
+	repo := dynamorepo.New(client, testTable, s, 1000)
+
+	// The links are spread over several partitions.
+	for _, id := range []uint64{350, 5, 120} {
+		code, err := s.Encode(id)
+		assert.NoError(t, err)
+
+		_, err = repo.ImportLink(ctx, &domain.Link{Code: code, Destination: fmt.Sprintf("importedURL%d", id)})
+		assert.NoError(t, err)
+	}
+
+	links, err := repo.ListLinks(ctx, domain.ListLinksParams{FromID: 0, Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, links, 2)
+	assert.Equal(t, uint64(5), links[0].ID)
+	assert.Equal(t, uint64(120), links[1].ID)
+
+	links, err = repo.ListLinks(ctx, domain.ListLinksParams{FromID: 121, Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, links, 1)
+	assert.Equal(t, uint64(350), links[0].ID)
+	assert.Equal(t, "importedURL350", links[0].Destination)
+}
+
+func Test_Stats_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	repo := dynamorepo.New(client, testTable, newShortener(t), 100)
+
+	_, err := repo.SaveURL(ctx, "activeURL")
+	assert.NoError(t, err)
+
+	disabled, err := repo.SaveURL(ctx, "disabledURL")
+	assert.NoError(t, err)
+	assert.NoError(t, repo.DisableLink(ctx, disabled))
+
+	expiresAt := time.Now().Add(-time.Minute)
+	_, err = repo.CreateLink(ctx, &domain.Link{Destination: "expiredURL", ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+
+	_, err = repo.ReserveIdempotencyKey(ctx, &domain.IdempotencyRecord{Key: "key", ExpiresAt: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+
+	stats, err := repo.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.Stats{Total: 3, Active: 1, Disabled: 1, Expired: 1, Capacity: 100}, stats)
+}
+
+func Test_SaveURL_ConcurrentRepositories_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	s := newShortener(t)
+	repos := []*dynamorepo.DynamoDBRepository{
+		dynamorepo.New(client, testTable, s, 1000),
+		dynamorepo.New(client, testTable, s, 1000),
+	}
+
+	const links = 50
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		codes = make(map[string]string)
+	)
+
+	for i := range links {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			url := fmt.Sprintf("originURL%d", i)
+
+			code, err := repos[i%len(repos)].SaveURL(ctx, url)
+			assert.NoError(t, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			codes[code] = url
+		}()
+	}
+
+	wg.Wait()
+
+	// Repositories sharing the table never hand out the same id.
+	assert.Len(t, codes, links)
+
+	for code, url := range codes {
+		original, err := repos[0].GetURL(ctx, code)
+		assert.NoError(t, err)
+		assert.Equal(t, url, original)
+	}
+}
+
+func Test_Close_ReturnsUnusedIDs_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	s := newShortener(t)
+	first := dynamorepo.New(client, testTable, s, 100)
+
+	link, err := first.CreateLink(ctx, &domain.Link{Destination: "originURL1"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), link.ID)
+
+	assert.NoError(t, first.Close(ctx))
+
+	// The rest of the block leased by the first repository is leased by the second one.
+	second := dynamorepo.New(client, testTable, s, 100)
+
+	link, err = second.CreateLink(ctx, &domain.Link{Destination: "originURL2"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), link.ID)
+}
+
+func Test_ReserveIdempotencyKey_FirstWins_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	repo := dynamorepo.New(client, testTable, newShortener(t), 100)
+
+	now := time.Now()
+
+	first := &domain.IdempotencyRecord{Key: "key", RequestHash: "first", CreatedAt: now, ExpiresAt: now.Add(time.Minute)}
+	second := &domain.IdempotencyRecord{Key: "key", RequestHash: "second", CreatedAt: now.Add(time.Millisecond), ExpiresAt: now.Add(time.Minute)}
+
+	existing, err := repo.ReserveIdempotencyKey(ctx, first)
+	assert.NoError(t, err)
+	assert.Nil(t, existing)
+
+	existing, err = repo.ReserveIdempotencyKey(ctx, second)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", existing.RequestHash)
+	assert.True(t, existing.InFlight())
+
+	second.StatusCode = 201
+	err = repo.CompleteIdempotencyRecord(ctx, second)
+	assert.IsType(t, &apperrors.ErrIdempotencyRecordNotFound{}, err)
+
+	first.StatusCode, first.Body, first.ExpiresAt = 201, []byte("first"), now.Add(time.Hour)
+	first.Headers = map[string][]string{"Location": {"/v2/links/aaab"}}
+	err = repo.CompleteIdempotencyRecord(ctx, first)
+	assert.NoError(t, err)
+
+	record, err := repo.GetIdempotencyRecord(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, 201, record.StatusCode)
+	assert.Equal(t, []byte("first"), record.Body)
+	assert.Equal(t, first.Headers, record.Headers)
+
+	// A completed record is not released.
+	err = repo.ReleaseIdempotencyKey(ctx, first)
+	assert.NoError(t, err)
+
+	_, err = repo.GetIdempotencyRecord(ctx, "key")
+	assert.NoError(t, err)
+}
+
+func Test_ReserveIdempotencyKey_ReplacesExpired_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	repo := dynamorepo.New(client, testTable, newShortener(t), 100)
+
+	_, err := repo.ReserveIdempotencyKey(ctx, &domain.IdempotencyRecord{
+		Key: "key", RequestHash: "expired", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(-time.Hour),
+	})
+	assert.NoError(t, err)
+
+	_, err = repo.GetIdempotencyRecord(ctx, "key")
+	assert.IsType(t, &apperrors.ErrIdempotencyRecordNotFound{}, err)
+
+	existing, err := repo.ReserveIdempotencyKey(ctx, &domain.IdempotencyRecord{
+		Key: "key", RequestHash: "fresh", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, existing)
+}
+
+func Test_ReleaseIdempotencyKey_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	repo := dynamorepo.New(client, testTable, newShortener(t), 100)
+
+	record := &domain.IdempotencyRecord{Key: "key", RequestHash: "hash", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Minute)}
+
+	_, err := repo.ReserveIdempotencyKey(ctx, record)
+	assert.NoError(t, err)
+
+	err = repo.ReleaseIdempotencyKey(ctx, record)
+	assert.NoError(t, err)
+
+	_, err = repo.GetIdempotencyRecord(ctx, "key")
+	assert.IsType(t, &apperrors.ErrIdempotencyRecordNotFound{}, err)
+}
+
+func Test_DeleteExpiredIdempotencyRecords_Success(t *testing.T) {
+	client, ctx := setupTable(t)
+
+	repo := dynamorepo.New(client, testTable, newShortener(t), 100)
+
+	_, err := repo.ReserveIdempotencyKey(ctx, &domain.IdempotencyRecord{Key: "expired", ExpiresAt: time.Now().Add(-time.Hour)})
+	assert.NoError(t, err)
+
+	_, err = repo.ReserveIdempotencyKey(ctx, &domain.IdempotencyRecord{Key: "fresh", ExpiresAt: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+
+	deleted, err := repo.DeleteExpiredIdempotencyRecords(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	_, err = repo.GetIdempotencyRecord(ctx, "fresh")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,257 @@
+package dynamorepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/domain/apperrors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+)
+
+type idempotencyItem struct {
+	PK          string              `dynamodbav:"pk"`
+	SK          uint64              `dynamodbav:"sk"`
+	RequestHash string              `dynamodbav:"request_hash"`
+	StatusCode  int                 `dynamodbav:"status_code"`
+	ContentType string              `dynamodbav:"content_type"`
+	Headers     map[string][]string `dynamodbav:"headers,omitempty"`
+	Body        []byte              `dynamodbav:"body"`
+	CreatedAt   time.Time           `dynamodbav:"created_at"`
+	ExpiresAt   time.Time           `dynamodbav:"expires_at"`
+	TTL         int64               `dynamodbav:"ttl"`
+}
+
+func (r *DynamoDBRepository) GetIdempotencyRecord(ctx context.Context, key string) (*domain.IdempotencyRecord, error) {
+	out, err := r.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      r.table,
+		Key:            itemKey(idempotencyPrefix+key, 0),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var item idempotencyItem
+
+	if out.Item != nil {
+		if err := dynamodbattribute.UnmarshalMap(out.Item, &item); err != nil {
+			return nil, err
+		}
+	}
+
+	// Expired records are deleted by DynamoDB some time after they expired.
+	if out.Item == nil || !time.Now().Before(item.ExpiresAt) {
+		return nil, &apperrors.ErrIdempotencyRecordNotFound{Message: "idempotency record not found"}
+	}
+
+	return toIdempotencyRecord(key, &item), nil
+}
+
+func (r *DynamoDBRepository) ReserveIdempotencyKey(
+	ctx context.Context,
+	record *domain.IdempotencyRecord,
+) (*domain.IdempotencyRecord, error) {
+	av, err := dynamodbattribute.MarshalMap(newIdempotencyItem(record))
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		// Expired records are replaced, unexpired ones are left untouched. The ttl is in whole seconds,
+		// so a record is replaced up to a second after it expired.
+		expr, err := expression.NewBuilder().WithCondition(expression.Or(
+			expression.AttributeNotExists(expression.Name(attrPK)),
+			expression.Name(attrTTL).LessThan(expression.Value(time.Now().Unix())),
+		)).Build()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = r.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+			TableName:                 r.table,
+			Item:                      av,
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		})
+		if !isAWSError(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+			return nil, err
+		}
+
+		existing, err := r.GetIdempotencyRecord(ctx, record.Key)
+
+		var errNotFound *apperrors.ErrIdempotencyRecordNotFound
+		if !errors.As(err, &errNotFound) {
+			return existing, err
+		}
+
+		// The record expired after the put saw it, try again.
+	}
+}
+
+func (r *DynamoDBRepository) CompleteIdempotencyRecord(ctx context.Context, record *domain.IdempotencyRecord) error {
+	av, err := dynamodbattribute.MarshalMap(newIdempotencyItem(record))
+	if err != nil {
+		return err
+	}
+
+	expr, err := expression.NewBuilder().WithCondition(reservationCondition(record)).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:                 r.table,
+		Item:                      av,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if isAWSError(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return &apperrors.ErrIdempotencyRecordNotFound{Message: "idempotency key is not reserved"}
+	}
+
+	return err
+}
+
+func (r *DynamoDBRepository) ReleaseIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord) error {
+	expr, err := expression.NewBuilder().WithCondition(reservationCondition(record)).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 r.table,
+		Key:                       itemKey(idempotencyPrefix+record.Key, 0),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if isAWSError(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return nil
+	}
+
+	return err
+}
+
+// reservationCondition matches the in-flight record reserved along with record.
+func reservationCondition(record *domain.IdempotencyRecord) expression.ConditionBuilder {
+	return expression.And(
+		expression.Name("status_code").Equal(expression.Value(0)),
+		expression.Name("created_at").Equal(expression.Value(record.CreatedAt.UTC())),
+	)
+}
+
+func newIdempotencyItem(record *domain.IdempotencyRecord) *idempotencyItem {
+	return &idempotencyItem{
+		PK:          idempotencyPrefix + record.Key,
+		RequestHash: record.RequestHash,
+		StatusCode:  record.StatusCode,
+		ContentType: record.ContentType,
+		Headers:     record.Headers,
+		Body:        record.Body,
+		CreatedAt:   record.CreatedAt.UTC(),
+		ExpiresAt:   record.ExpiresAt,
+		TTL:         record.ExpiresAt.Unix(),
+	}
+}
+
+// DeleteExpiredIdempotencyRecords deletes the records DynamoDB has not deleted yet. It scans the
+// whole table.
+func (r *DynamoDBRepository) DeleteExpiredIdempotencyRecords(ctx context.Context, now time.Time) (int64, error) {
+	expr, err := expression.NewBuilder().
+		WithFilter(expression.And(
+			expression.BeginsWith(expression.Name(attrPK), idempotencyPrefix),
+			expression.Name(attrTTL).LessThanEqual(expression.Value(now.Unix())),
+		)).
+		WithProjection(expression.NamesList(expression.Name(attrPK), expression.Name(attrSK), expression.Name("expires_at"))).
+		Build()
+	if err != nil {
+		return 0, err
+	}
+
+	var expired []*idempotencyItem
+
+	var pageErr error
+
+	err = r.client.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+		TableName:                 r.table,
+		FilterExpression:          expr.Filter(),
+		ProjectionExpression:      expr.Projection(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}, func(out *dynamodb.ScanOutput, _ bool) bool {
+		for _, av := range out.Items {
+			var item idempotencyItem
+			if pageErr = dynamodbattribute.UnmarshalMap(av, &item); pageErr != nil {
+				return false
+			}
+
+			if !now.Before(item.ExpiresAt) {
+				expired = append(expired, &item)
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if pageErr != nil {
+		return 0, pageErr
+	}
+
+	var deleted int64
+
+	for _, item := range expired {
+		if err := r.deleteExpired(ctx, item, now); err != nil {
+			return deleted, err
+		}
+
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// deleteExpired deletes the record unless it was replaced since it was scanned.
+func (r *DynamoDBRepository) deleteExpired(ctx context.Context, item *idempotencyItem, now time.Time) error {
+	expr, err := expression.NewBuilder().
+		WithCondition(expression.Name(attrTTL).LessThanEqual(expression.Value(now.Unix()))).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 r.table,
+		Key:                       itemKey(item.PK, item.SK),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if isAWSError(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return nil
+	}
+
+	return err
+}
+
+func toIdempotencyRecord(key string, item *idempotencyItem) *domain.IdempotencyRecord {
+	return &domain.IdempotencyRecord{
+		Key:         key,
+		RequestHash: item.RequestHash,
+		StatusCode:  item.StatusCode,
+		ContentType: item.ContentType,
+		Headers:     item.Headers,
+		Body:        item.Body,
+		CreatedAt:   item.CreatedAt,
+		ExpiresAt:   item.ExpiresAt,
+	}
+}
@@ -0,0 +1,221 @@
+package dynamorepo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/AFK068/compressor/pkg/idalloc"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+)
+
+const attrNextID = "next_id"
+
+// IDLeaser implements idalloc.Leaser with the ids item, a counter updated with conditional writes,
+// and the ids#returned items, so the allocators of every process sharing the table lease disjoint
+// blocks.
+type IDLeaser struct {
+	client dynamodbiface.DynamoDBAPI
+	table  *string
+}
+
+func NewIDLeaser(client dynamodbiface.DynamoDBAPI, table string) *IDLeaser {
+	return &IDLeaser{client: client, table: aws.String(table)}
+}
+
+type returnedBlockItem struct {
+	PK    string `dynamodbav:"pk"`
+	Start uint64 `dynamodbav:"sk"`
+	End   uint64 `dynamodbav:"end_id"`
+}
+
+// errLeaseRaced is returned by lease when another process leased the same ids first.
+var errLeaseRaced = errors.New("ids were leased concurrently")
+
+// Lease prefers the returned block with the lowest ids, like the postgres leaser.
+func (l *IDLeaser) Lease(ctx context.Context, size, limit uint64) (idalloc.Block, error) {
+	for {
+		block, err := l.lease(ctx, size, limit)
+		if err != errLeaseRaced {
+			return block, err
+		}
+	}
+}
+
+func (l *IDLeaser) lease(ctx context.Context, size, limit uint64) (idalloc.Block, error) {
+	keyCondition := expression.Key(attrPK).Equal(expression.Value(returnedIDsKey)).
+		And(expression.Key(attrSK).LessThan(expression.Value(limit)))
+
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).Build()
+	if err != nil {
+		return idalloc.Block{}, err
+	}
+
+	out, err := l.client.QueryWithContext(ctx, &dynamodb.QueryInput{
+		TableName:                 l.table,
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ConsistentRead:            aws.Bool(true),
+		Limit:                     aws.Int64(1),
+	})
+	if err != nil {
+		return idalloc.Block{}, err
+	}
+
+	if len(out.Items) > 0 {
+		var returned returnedBlockItem
+		if err := dynamodbattribute.UnmarshalMap(out.Items[0], &returned); err != nil {
+			return idalloc.Block{}, err
+		}
+
+		return l.leaseReturned(ctx, idalloc.Block{Start: returned.Start, End: returned.End}, size, limit)
+	}
+
+	return l.leaseNext(ctx, size, limit)
+}
+
+// leaseReturned leases the beginning of a returned block, giving the rest of it back in the same
+// transaction.
+func (l *IDLeaser) leaseReturned(ctx context.Context, returned idalloc.Block, size, limit uint64) (idalloc.Block, error) {
+	block := idalloc.Block{Start: returned.Start, End: returned.Start + min(size, returned.Len(), limit-returned.Start)}
+
+	exists, err := expression.NewBuilder().WithCondition(expression.AttributeExists(expression.Name(attrPK))).Build()
+	if err != nil {
+		return idalloc.Block{}, err
+	}
+
+	items := []*dynamodb.TransactWriteItem{{
+		Delete: &dynamodb.Delete{
+			TableName:                 l.table,
+			Key:                       itemKey(returnedIDsKey, returned.Start),
+			ConditionExpression:       exists.Condition(),
+			ExpressionAttributeNames:  exists.Names(),
+			ExpressionAttributeValues: exists.Values(),
+		},
+	}}
+
+	if block.End < returned.End {
+		rest, err := dynamodbattribute.MarshalMap(returnedBlockItem{PK: returnedIDsKey, Start: block.End, End: returned.End})
+		if err != nil {
+			return idalloc.Block{}, err
+		}
+
+		items = append(items, &dynamodb.TransactWriteItem{Put: &dynamodb.Put{TableName: l.table, Item: rest}})
+	}
+
+	_, err = l.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if isAWSError(err, dynamodb.ErrCodeTransactionCanceledException) {
+		return idalloc.Block{}, errLeaseRaced
+	}
+
+	if err != nil {
+		return idalloc.Block{}, err
+	}
+
+	return block, nil
+}
+
+// leaseNext moves the counter past the block, unless another process moved it since it was read.
+func (l *IDLeaser) leaseNext(ctx context.Context, size, limit uint64) (idalloc.Block, error) {
+	next, err := nextID(ctx, l.client, l.table)
+	if err != nil {
+		return idalloc.Block{}, err
+	}
+
+	if next >= limit {
+		return idalloc.Block{}, idalloc.ErrExhausted
+	}
+
+	block := idalloc.Block{Start: next, End: next + min(size, limit-next)}
+
+	condition := expression.Name(attrNextID).Equal(expression.Value(next))
+	if next == 0 {
+		condition = expression.Or(condition, expression.AttributeNotExists(expression.Name(attrNextID)))
+	}
+
+	err = l.setNext(ctx, block.End, condition)
+	if isAWSError(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return idalloc.Block{}, errLeaseRaced
+	}
+
+	if err != nil {
+		return idalloc.Block{}, err
+	}
+
+	return block, nil
+}
+
+// nextID returns the next id that was never leased.
+func nextID(ctx context.Context, client dynamodbiface.DynamoDBAPI, table *string) (uint64, error) {
+	out, err := client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      table,
+		Key:            itemKey(idsKey, 0),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil || out.Item == nil {
+		return 0, err
+	}
+
+	var counter struct {
+		Next uint64 `dynamodbav:"next_id"`
+	}
+
+	err = dynamodbattribute.UnmarshalMap(out.Item, &counter)
+
+	return counter.Next, err
+}
+
+func (l *IDLeaser) setNext(ctx context.Context, next uint64, condition expression.ConditionBuilder) error {
+	expr, err := expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name(attrNextID), expression.Value(next))).
+		WithCondition(condition).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = l.client.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 l.table,
+		Key:                       itemKey(idsKey, 0),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	return err
+}
+
+func (l *IDLeaser) Return(ctx context.Context, blocks []idalloc.Block) error {
+	for _, block := range blocks {
+		item, err := dynamodbattribute.MarshalMap(returnedBlockItem{PK: returnedIDsKey, Start: block.Start, End: block.End})
+		if err != nil {
+			return err
+		}
+
+		if _, err := l.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{TableName: l.table, Item: item}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *IDLeaser) Advance(ctx context.Context, next uint64) error {
+	condition := expression.Or(
+		expression.AttributeNotExists(expression.Name(attrNextID)),
+		expression.Name(attrNextID).LessThan(expression.Value(next)),
+	)
+
+	err := l.setNext(ctx, next, condition)
+	if isAWSError(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		// The counter is already beyond.
+		return nil
+	}
+
+	return err
+}
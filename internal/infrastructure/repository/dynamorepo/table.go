@@ -0,0 +1,172 @@
+package dynamorepo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// The table holds every item under a string partition key and a number sort key:
+//
+//	link#<id/linkBucketSize>  <id>          a link
+//	url#<hash>                0             the link a destination is shortened as
+//	ids                       0             the next id that was never leased
+//	ids#returned              <start id>    a block of ids given back
+//	idempotency#<key>         0             an idempotency record
+const (
+	attrPK     = "pk"
+	attrSK     = "sk"
+	attrLinkID = "link_id"
+	// attrTTL holds when an item expires, in Unix seconds, for DynamoDB to delete it.
+	attrTTL = "ttl"
+
+	linkPrefix        = "link#"
+	urlPrefix         = "url#"
+	idempotencyPrefix = "idempotency#"
+	idsKey            = "ids"
+	returnedIDsKey    = "ids#returned"
+
+	// linkBucketSize is how many consecutive ids share a partition, so a page of links is read
+	// in order with a query or two while new links are spread over partitions.
+	linkBucketSize = 100
+)
+
+type linkItem struct {
+	PK        string     `dynamodbav:"pk"`
+	ID        uint64     `dynamodbav:"sk"`
+	URL       string     `dynamodbav:"url"`
+	CreatedAt time.Time  `dynamodbav:"created_at"`
+	ExpiresAt *time.Time `dynamodbav:"expires_at,omitempty"`
+	TTL       int64      `dynamodbav:"ttl,omitempty"`
+	Owner     string     `dynamodbav:"owner,omitempty"`
+	Disabled  bool       `dynamodbav:"disabled"`
+}
+
+func newLinkItem(id uint64, destination string) *linkItem {
+	return &linkItem{
+		PK:  linkPartition(id),
+		ID:  id,
+		URL: destination,
+	}
+}
+
+// resolvable reports whether the link may be followed: it is neither disabled nor expired.
+func (item *linkItem) resolvable(now time.Time) bool {
+	return !item.Disabled && (item.ExpiresAt == nil || now.Before(*item.ExpiresAt))
+}
+
+// setExpiresAt also sets the ttl, so DynamoDB deletes the link some time after it expired.
+func (item *linkItem) setExpiresAt(expiresAt *time.Time) {
+	item.ExpiresAt = expiresAt
+	if expiresAt != nil {
+		item.TTL = expiresAt.Unix()
+	}
+}
+
+// urlItem makes sure a destination is shortened once. It is written together with the link in a
+// transaction, on the condition that it does not exist or still points to the link it replaces.
+type urlItem struct {
+	PK     string `dynamodbav:"pk"`
+	SK     uint64 `dynamodbav:"sk"`
+	LinkID uint64 `dynamodbav:"link_id"`
+	// TTL is the one of the link, so both are deleted by DynamoDB.
+	TTL int64 `dynamodbav:"ttl,omitempty"`
+}
+
+func newURLItem(link *linkItem) *urlItem {
+	return &urlItem{PK: urlPartition(link.URL), LinkID: link.ID, TTL: link.TTL}
+}
+
+func urlPartition(destination string) string {
+	return urlPrefix + hashURL(destination)
+}
+
+func linkPartition(id uint64) string {
+	return linkPrefix + strconv.FormatUint(id/linkBucketSize, 10)
+}
+
+func linkKey(id uint64) map[string]*dynamodb.AttributeValue {
+	return itemKey(linkPartition(id), id)
+}
+
+func itemKey(pk string, sk uint64) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		attrPK: {S: aws.String(pk)},
+		attrSK: {N: aws.String(strconv.FormatUint(sk, 10))},
+	}
+}
+
+// hashURL keeps the keys of the destinations short, as destinations may be longer than a key may be.
+func hashURL(destination string) string {
+	sum := sha256.Sum256([]byte(destination))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateTable creates the table and enables the expiry of items, unless the table exists. It waits
+// until the table can be used.
+func CreateTable(ctx context.Context, client dynamodbiface.DynamoDBAPI, table string) error {
+	describe := &dynamodb.DescribeTableInput{TableName: aws.String(table)}
+
+	_, err := client.DescribeTableWithContext(ctx, describe)
+	if err == nil {
+		return nil
+	}
+
+	if !isAWSError(err, dynamodb.ErrCodeResourceNotFoundException) {
+		return fmt.Errorf("describing table %s: %w", table, err)
+	}
+
+	_, err = client.CreateTableWithContext(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(table),
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String(attrPK), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+			{AttributeName: aws.String(attrSK), AttributeType: aws.String(dynamodb.ScalarAttributeTypeN)},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String(attrPK), KeyType: aws.String(dynamodb.KeyTypeHash)},
+			{AttributeName: aws.String(attrSK), KeyType: aws.String(dynamodb.KeyTypeRange)},
+		},
+	})
+
+	// Another instance starting at the same time created the table, and enables the expiry.
+	created := err == nil
+	if err != nil && !isAWSError(err, dynamodb.ErrCodeResourceInUseException) {
+		return fmt.Errorf("creating table %s: %w", table, err)
+	}
+
+	if err := client.WaitUntilTableExistsWithContext(ctx, describe); err != nil {
+		return fmt.Errorf("waiting for table %s: %w", table, err)
+	}
+
+	if !created {
+		return nil
+	}
+
+	_, err = client.UpdateTimeToLiveWithContext(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(table),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(attrTTL),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("enabling expiry of table %s: %w", table, err)
+	}
+
+	return nil
+}
+
+func isAWSError(err error, code string) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == code
+}
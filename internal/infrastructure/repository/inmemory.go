@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/AFK068/compressor/internal/infrastructure/repository/inmemoryrepo"
+	"github.com/AFK068/compressor/pkg/idalloc"
+	"github.com/AFK068/compressor/pkg/storage"
+)
+
+// InMemorySettings configure the inmemory storage, read from storage.inmemory.
+type InMemorySettings struct {
+	// MemoryBudget is how many bytes the links may use before writes are refused, so the process
+	// fails requests instead of running out of memory. 0 disables the limit.
+	MemoryBudget uint64 `yaml:"memory_budget" env:"MEMORY_BUDGET" env-default:"0"`
+	// IDLeaseFile keeps the leases of ids across restarts, so codes handed out before are not
+	// handed out again. Leases are kept in memory when empty.
+	IDLeaseFile string `yaml:"id_lease_file" env:"ID_LEASE_FILE"`
+	// Compression compresses the destinations.
+	Compression Compression `yaml:"compression"`
+}
+
+// Compression configures the zstd compression of destinations, with a dictionary read from a file
+// or built from the first destinations stored.
+type Compression struct {
+	Enabled bool `yaml:"enabled" env:"COMPRESSION_ENABLED" env-default:"false"`
+	// DictionaryFile is a zstd dictionary, e.g. built from the destinations of a previous run. When
+	// empty, a dictionary is built once training_samples destinations were stored, which are stored
+	// uncompressed.
+	DictionaryFile  string `yaml:"dictionary_file" env:"COMPRESSION_DICTIONARY_FILE"`
+	TrainingSamples int    `yaml:"training_samples" env:"COMPRESSION_TRAINING_SAMPLES" env-default:"10000"`
+}
+
+type inMemoryDriver struct{}
+
+func (inMemoryDriver) NewSettings() any {
+	return &InMemorySettings{}
+}
+
+func (inMemoryDriver) Open(_ context.Context, opts storage.Options) (storage.Backend, func(), error) {
+	cfg, log := opts.Config, opts.Logger
+	settings := cfg.Storage.DriverSettings.(*InMemorySettings)
+
+	var leaser idalloc.Leaser = idalloc.NewMemoryLeaser()
+	if settings.IDLeaseFile != "" {
+		leaser = idalloc.NewFileLeaser(settings.IDLeaseFile)
+	}
+
+	repoOpts := []inmemoryrepo.Option{
+		inmemoryrepo.WithIDAllocator(newIDAllocator(cfg, leaser)),
+		inmemoryrepo.WithMemoryBudget(settings.MemoryBudget),
+	}
+
+	if settings.Compression.Enabled {
+		compressor, err := newCompressor(settings.Compression)
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating compressor: %w", err)
+		}
+
+		repoOpts = append(repoOpts, inmemoryrepo.WithCompressor(compressor))
+	}
+
+	repo := inmemoryrepo.New(opts.Shortener, cfg.Storage.MaxSize, repoOpts...)
+
+	return repo, func() { closeIDs(repo, log) }, nil
+}
+
+func newCompressor(cfg Compression) (*inmemoryrepo.Compressor, error) {
+	var dictionary []byte
+
+	if cfg.DictionaryFile != "" {
+		var err error
+		if dictionary, err = os.ReadFile(cfg.DictionaryFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return inmemoryrepo.NewCompressor(dictionary, cfg.TrainingSamples)
+}
+
+func (s *InMemorySettings) Validate() error {
+	errs := &fieldErrors{}
+
+	if s.Compression.Enabled && s.Compression.TrainingSamples <= 0 {
+		errs.add("compression.training_samples", errors.New("training_samples must be greater than 0"))
+	}
+
+	return errs.err()
+}
@@ -0,0 +1,33 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/infrastructure/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_InMemorySettings_Success(t *testing.T) {
+	t.Setenv("STORAGE_TYPE", "inmemory")
+	t.Setenv("ID_LEASE_FILE", "/var/lib/compressor/ids.json")
+	t.Setenv("MEMORY_BUDGET", "1073741824")
+
+	cfg, err := config.NewConfig(TestConfigPath)
+	assert.NoError(t, err)
+	assert.Equal(t, &repository.InMemorySettings{
+		MemoryBudget: 1 << 30,
+		IDLeaseFile:  "/var/lib/compressor/ids.json",
+		Compression:  repository.Compression{TrainingSamples: 10000},
+	}, cfg.Storage.DriverSettings)
+}
+
+func Test_InMemorySettings_Validate_Failure(t *testing.T) {
+	t.Setenv("STORAGE_TYPE", "inmemory")
+	t.Setenv("ID_BLOCK_SIZE", "0")
+	t.Setenv("COMPRESSION_ENABLED", "true")
+	t.Setenv("COMPRESSION_TRAINING_SAMPLES", "0")
+
+	_, err := config.NewConfig(TestConfigPath)
+	assert.Equal(t, []string{"storage.inmemory.compression.training_samples", "storage.ids.block_size"}, validationPaths(t, err))
+}
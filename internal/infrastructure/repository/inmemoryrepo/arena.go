@@ -0,0 +1,74 @@
+package inmemoryrepo
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// chunkSize is how many bytes of entries the arena allocates at once. Longer entries get a chunk
+// of their own.
+const chunkSize = 256 << 10
+
+// entryRef locates an entry in the arena.
+type entryRef struct {
+	chunk  uint32
+	offset uint32
+	length uint32
+}
+
+// arena stores the destinations of the links once, encoded by a compressor, in chunks appended to
+// under a lock and read without one. Entries of deleted links are not reclaimed.
+type arena struct {
+	compressor *Compressor
+
+	mu sync.Mutex
+	// chunks is replaced as a whole when a chunk is added. Entries are copied into the unused end
+	// of the last chunk, which readers never look at, and are never modified afterwards.
+	chunks atomic.Pointer[[][]byte]
+	used   int
+}
+
+func newArena(compressor *Compressor) *arena {
+	a := &arena{compressor: compressor}
+	a.chunks.Store(&[][]byte{})
+
+	return a
+}
+
+// put appends the entry of the destination. It calls allocate with the size of a new chunk before
+// allocating it, which may refuse it.
+func (a *arena) put(destination string, allocate func(n uint64) error) (entryRef, error) {
+	entry := a.compressor.encode(nil, destination)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	chunks := *a.chunks.Load()
+
+	if n := len(chunks); n == 0 || len(chunks[n-1])-a.used < len(entry) {
+		size := max(chunkSize, len(entry))
+
+		if err := allocate(uint64(size)); err != nil {
+			return entryRef{}, err
+		}
+
+		chunks = append(slices.Clip(chunks), make([]byte, size))
+		a.chunks.Store(&chunks)
+		a.used = 0
+	}
+
+	last := len(chunks) - 1
+	ref := entryRef{chunk: uint32(last), offset: uint32(a.used), length: uint32(len(entry))} //nolint
+
+	a.used += copy(chunks[last][a.used:], entry)
+
+	return ref, nil
+}
+
+// get returns the destination of the entry.
+func (a *arena) get(ref entryRef) (string, error) {
+	chunk := (*a.chunks.Load())[ref.chunk]
+
+	return a.compressor.decode(chunk[ref.offset : ref.offset+ref.length])
+}
@@ -0,0 +1,246 @@
+package inmemoryrepo
+
+import (
+	"cmp"
+	"errors"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// DefaultTrainingSamples is how many destinations are sampled before the dictionary is built.
+	DefaultTrainingSamples = 10_000
+	// DictionarySize is the size of the history of a built dictionary.
+	DictionarySize = 64 << 10
+
+	// dictionaryID is small, so it takes a single byte in frame headers.
+	dictionaryID = 1
+
+	// The first byte of an arena entry tells how the rest of it is encoded.
+	entryRaw  byte = 0
+	entryZstd byte = 1
+)
+
+// zstdMagic starts every zstd frame. It is stripped from stored entries to save four bytes each.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// Compressor compresses destinations with a zstd dictionary. Without a dictionary it samples the
+// destinations it is given and builds one from them, storing them raw until then. Destinations are
+// compressed one by one, so reading one does not decompress others, which the dictionary makes
+// worthwhile for strings as short as URLs. A nil Compressor stores destinations raw.
+type Compressor struct {
+	mu       sync.Mutex
+	samples  [][]byte
+	training int
+	sampled  bool
+
+	// codec is nil until the dictionary is loaded.
+	codec atomic.Pointer[codec]
+}
+
+type codec struct {
+	enc *zstd.Encoder
+	dec *zstd.Decoder
+}
+
+// NewCompressor uses dictionary, a zstd dictionary, or builds one from the first training
+// destinations when it is empty.
+func NewCompressor(dictionary []byte, training int) (*Compressor, error) {
+	c := &Compressor{training: max(training, 1)}
+
+	if len(dictionary) > 0 {
+		if err := c.load(dictionary); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (c *Compressor) load(dictionary []byte) error {
+	enc, err := zstd.NewWriter(nil,
+		zstd.WithEncoderDict(dictionary),
+		zstd.WithEncoderConcurrency(runtime.GOMAXPROCS(0)),
+		zstd.WithEncoderCRC(false),
+		// Higher levels are twice as slow without compressing URLs any better.
+		zstd.WithEncoderLevel(zstd.SpeedFastest),
+		zstd.WithSingleSegment(true),
+	)
+	if err != nil {
+		return err
+	}
+
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderDicts(dictionary), zstd.WithDecoderConcurrency(0))
+	if err != nil {
+		return err
+	}
+
+	c.codec.Store(&codec{enc: enc, dec: dec})
+
+	return nil
+}
+
+// encode appends the entry of the destination to dst.
+func (c *Compressor) encode(dst []byte, destination string) []byte {
+	if c == nil {
+		return append(append(dst, entryRaw), destination...)
+	}
+
+	codec := c.codec.Load()
+	if codec == nil {
+		c.sample(destination)
+		return append(append(dst, entryRaw), destination...)
+	}
+
+	frame := codec.enc.EncodeAll([]byte(destination), make([]byte, 0, len(destination)))
+	if len(frame)-len(zstdMagic) >= len(destination) {
+		return append(append(dst, entryRaw), destination...)
+	}
+
+	return append(append(dst, entryZstd), frame[len(zstdMagic):]...)
+}
+
+// decode returns the destination of an entry.
+func (c *Compressor) decode(entry []byte) (string, error) {
+	if len(entry) == 0 {
+		return "", errCorruptedEntry
+	}
+
+	switch entry[0] {
+	case entryRaw:
+		return string(entry[1:]), nil
+	case entryZstd:
+		if c == nil {
+			return "", errCorruptedEntry
+		}
+
+		codec := c.codec.Load()
+		if codec == nil {
+			return "", errCorruptedEntry
+		}
+
+		frame := append(slices.Clip(zstdMagic), entry[1:]...)
+
+		destination, err := codec.dec.DecodeAll(frame, nil)
+		if err != nil {
+			return "", err
+		}
+
+		return string(destination), nil
+	default:
+		return "", errCorruptedEntry
+	}
+}
+
+func (c *Compressor) sample(destination string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sampled {
+		return
+	}
+
+	c.samples = append(c.samples, []byte(destination))
+	if len(c.samples) < c.training {
+		return
+	}
+
+	samples := c.samples
+	c.samples, c.sampled = nil, true
+
+	// Destinations keep being stored raw when no dictionary can be built from the samples.
+	dictionary, err := BuildDictionary(samples, DictionarySize)
+	if err == nil {
+		_ = c.load(dictionary)
+	}
+}
+
+var errCorruptedEntry = errors.New("corrupted destination entry")
+
+// BuildDictionary builds a zstd dictionary of at most size bytes of history for destinations like
+// the samples. The history is made of the URL fragments that would save the most bytes, e.g. hosts
+// and query parameter names, the most valuable last, where matches are the cheapest to encode.
+func BuildDictionary(samples [][]byte, size int) ([]byte, error) {
+	counts := make(map[string]int)
+
+	for _, sample := range samples {
+		for _, fragment := range urlFragments(string(sample)) {
+			counts[fragment]++
+		}
+	}
+
+	type scored struct {
+		fragment string
+		score    int
+	}
+
+	fragments := make([]scored, 0, len(counts))
+
+	for fragment, count := range counts {
+		if count > 1 {
+			fragments = append(fragments, scored{fragment, (count - 1) * len(fragment)})
+		}
+	}
+
+	slices.SortFunc(fragments, func(a, b scored) int {
+		return cmp.Or(cmp.Compare(b.score, a.score), strings.Compare(a.fragment, b.fragment))
+	})
+
+	var picked []string
+
+	length := 0
+
+	for _, f := range fragments {
+		if length+len(f.fragment) > size {
+			continue
+		}
+
+		picked = append(picked, f.fragment)
+		length += len(f.fragment)
+	}
+
+	if length < 8 {
+		return nil, errors.New("samples have too few common fragments")
+	}
+
+	slices.Reverse(picked)
+
+	return zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       dictionaryID,
+		Contents: samples,
+		History:  []byte(strings.Join(picked, "")),
+		Offsets:  [3]int{1, 4, 8},
+		Level:    zstd.SpeedDefault,
+	})
+}
+
+// urlFragments splits a URL after every separator, e.g. "https://", "example.com/" and "utm_source=".
+func urlFragments(url string) []string {
+	var fragments []string
+
+	start := 0
+
+	for i := 0; i < len(url); i++ {
+		switch url[i] {
+		case '/', '?', '&', '=', '.', '#':
+			// "//" stays in one fragment with the scheme.
+			if url[i] == '/' && i+1 < len(url) && url[i+1] == '/' {
+				continue
+			}
+
+			fragments = append(fragments, url[start:i+1])
+			start = i + 1
+		}
+	}
+
+	if start < len(url) {
+		fragments = append(fragments, url[start:])
+	}
+
+	return fragments
+}
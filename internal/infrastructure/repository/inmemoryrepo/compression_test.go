@@ -0,0 +1,96 @@
+package inmemoryrepo_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/AFK068/compressor/internal/infrastructure/repository/inmemoryrepo"
+	"github.com/AFK068/compressor/pkg/shortener"
+	"github.com/stretchr/testify/assert"
+)
+
+func trackingURLs(n int) []string {
+	urls := make([]string, n)
+
+	for i := range urls {
+		urls[i] = fmt.Sprintf("https://shop.example.com/products/%d?utm_source=newsletter&utm_medium=email&utm_campaign=spring-%d", i, i%7)
+	}
+
+	return urls
+}
+
+func saveAll(t *testing.T, repo *inmemoryrepo.InMemoryRepository, urls []string) []string {
+	t.Helper()
+
+	codes := make([]string, len(urls))
+
+	for i, url := range urls {
+		code, err := repo.SaveURL(context.Background(), url)
+		assert.NoError(t, err)
+
+		codes[i] = code
+	}
+
+	return codes
+}
+
+func Test_Compressor_TrainedDictionary_Success(t *testing.T) {
+	s, err := shortener.NewShortener("abcdefgh", 10)
+	assert.NoError(t, err)
+
+	compressor, err := inmemoryrepo.NewCompressor(nil, 100)
+	assert.NoError(t, err)
+
+	raw := inmemoryrepo.New(s, 20_000)
+	compressed := inmemoryrepo.New(s, 20_000, inmemoryrepo.WithCompressor(compressor))
+
+	urls := trackingURLs(10_000)
+
+	saveAll(t, raw, urls)
+	codes := saveAll(t, compressed, urls)
+
+	// Destinations stored before and after the dictionary was built read back the same.
+	for i, code := range codes {
+		destination, err := compressed.GetURL(context.Background(), code)
+		assert.NoError(t, err)
+		assert.Equal(t, urls[i], destination)
+	}
+
+	// Saving a destination again finds it through the hash index.
+	code, err := compressed.SaveURL(context.Background(), urls[5000])
+	assert.NoError(t, err)
+	assert.Equal(t, codes[5000], code)
+
+	assert.Less(t, compressed.Footprint(), raw.Footprint())
+}
+
+func Test_Compressor_GivenDictionary_Success(t *testing.T) {
+	samples := make([][]byte, 0, 100)
+	for _, url := range trackingURLs(100) {
+		samples = append(samples, []byte(url))
+	}
+
+	dictionary, err := inmemoryrepo.BuildDictionary(samples, inmemoryrepo.DictionarySize)
+	assert.NoError(t, err)
+
+	compressor, err := inmemoryrepo.NewCompressor(dictionary, inmemoryrepo.DefaultTrainingSamples)
+	assert.NoError(t, err)
+
+	s, err := shortener.NewShortener("abcdefgh", 10)
+	assert.NoError(t, err)
+
+	repo := inmemoryrepo.New(s, 1000, inmemoryrepo.WithCompressor(compressor))
+	urls := trackingURLs(10)
+
+	for i, code := range saveAll(t, repo, urls) {
+		link, err := repo.GetLink(context.Background(), code)
+		assert.NoError(t, err)
+		assert.Equal(t, urls[i], link.Destination)
+	}
+}
+
+func Test_NewCompressor_InvalidDictionary_Failure(t *testing.T) {
+	_, err := inmemoryrepo.NewCompressor([]byte("not a dictionary"), inmemoryrepo.DefaultTrainingSamples)
+	assert.Error(t, err)
+}
@@ -0,0 +1,94 @@
+package inmemoryrepo
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/domain/apperrors"
+)
+
+func (r *InMemoryRepository) GetIdempotencyRecord(_ context.Context, key string) (*domain.IdempotencyRecord, error) {
+	r.idempotencyMu.Lock()
+	defer r.idempotencyMu.Unlock()
+
+	record, ok := r.idempotency[key]
+	if !ok || !time.Now().Before(record.ExpiresAt) {
+		return nil, &apperrors.ErrIdempotencyRecordNotFound{Message: "idempotency record not found"}
+	}
+
+	return copyRecord(record), nil
+}
+
+func (r *InMemoryRepository) ReserveIdempotencyKey(
+	_ context.Context,
+	record *domain.IdempotencyRecord,
+) (*domain.IdempotencyRecord, error) {
+	r.idempotencyMu.Lock()
+	defer r.idempotencyMu.Unlock()
+
+	if existing, ok := r.idempotency[record.Key]; ok && time.Now().Before(existing.ExpiresAt) {
+		return copyRecord(existing), nil
+	}
+
+	r.idempotency[record.Key] = copyRecord(record)
+
+	return nil, nil
+}
+
+func (r *InMemoryRepository) CompleteIdempotencyRecord(_ context.Context, record *domain.IdempotencyRecord) error {
+	r.idempotencyMu.Lock()
+	defer r.idempotencyMu.Unlock()
+
+	if !r.isReservation(record) {
+		return &apperrors.ErrIdempotencyRecordNotFound{Message: "idempotency key is not reserved"}
+	}
+
+	r.idempotency[record.Key] = copyRecord(record)
+
+	return nil
+}
+
+func (r *InMemoryRepository) ReleaseIdempotencyKey(_ context.Context, record *domain.IdempotencyRecord) error {
+	r.idempotencyMu.Lock()
+	defer r.idempotencyMu.Unlock()
+
+	if r.isReservation(record) {
+		delete(r.idempotency, record.Key)
+	}
+
+	return nil
+}
+
+// isReservation reports whether the in-flight record stored under the key of record was reserved
+// along with record. It must be called with idempotencyMu held.
+func (r *InMemoryRepository) isReservation(record *domain.IdempotencyRecord) bool {
+	existing, ok := r.idempotency[record.Key]
+
+	return ok && existing.InFlight() && existing.CreatedAt.Equal(record.CreatedAt)
+}
+
+func (r *InMemoryRepository) DeleteExpiredIdempotencyRecords(_ context.Context, now time.Time) (int64, error) {
+	r.idempotencyMu.Lock()
+	defer r.idempotencyMu.Unlock()
+
+	var deleted int64
+
+	for key, record := range r.idempotency {
+		if !now.Before(record.ExpiresAt) {
+			delete(r.idempotency, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func copyRecord(record *domain.IdempotencyRecord) *domain.IdempotencyRecord {
+	c := *record
+	c.Body = append([]byte(nil), record.Body...)
+	c.Headers = http.Header(record.Headers).Clone()
+
+	return &c
+}
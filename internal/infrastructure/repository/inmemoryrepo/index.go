@@ -0,0 +1,83 @@
+package inmemoryrepo
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+// indexShards is a power of two, so a shard is picked by masking the hash.
+const indexShards = 64
+
+// urlIndex maps destinations to the ids of their links. It is keyed by the hash of the destination,
+// which is stored once in the arena, and split into shards with a lock each, so links to different
+// destinations are created without waiting for each other.
+type urlIndex struct {
+	seed   maphash.Seed
+	shards [indexShards]indexShard
+}
+
+// indexShard is locked by callers around lookups and updates.
+type indexShard struct {
+	sync.RWMutex
+	ids map[uint64]uint64
+	// collisions holds the destinations whose hash was already taken by another destination.
+	collisions map[string]uint64
+}
+
+func newURLIndex() *urlIndex {
+	idx := &urlIndex{seed: maphash.MakeSeed()}
+
+	for i := range idx.shards {
+		idx.shards[i].ids = make(map[uint64]uint64)
+		idx.shards[i].collisions = make(map[string]uint64)
+	}
+
+	return idx
+}
+
+func (idx *urlIndex) hash(destination string) uint64 {
+	return maphash.String(idx.seed, destination)
+}
+
+func (idx *urlIndex) shard(hash uint64) *indexShard {
+	return &idx.shards[hash&(indexShards-1)]
+}
+
+// lookup returns the id of the destination. matches tells whether the link with the id has the
+// destination, as another destination may have the same hash.
+func (s *indexShard) lookup(hash uint64, destination string, matches func(id uint64) bool) (uint64, bool) {
+	if id, ok := s.ids[hash]; ok && matches(id) {
+		return id, true
+	}
+
+	if len(s.collisions) == 0 {
+		return 0, false
+	}
+
+	id, ok := s.collisions[destination]
+
+	return id, ok
+}
+
+// add must only be called for a destination that is not in the index.
+func (s *indexShard) add(hash uint64, destination string, id uint64) {
+	if _, ok := s.ids[hash]; ok {
+		s.collisions[destination] = id
+		return
+	}
+
+	s.ids[hash] = id
+}
+
+// remove does nothing when the destination is in the index with another id, which happens once
+// a disabled or expired link of the destination is replaced.
+func (s *indexShard) remove(hash uint64, destination string, id uint64) {
+	if stored, ok := s.ids[hash]; ok && stored == id {
+		delete(s.ids, hash)
+		return
+	}
+
+	if stored, ok := s.collisions[destination]; ok && stored == id {
+		delete(s.collisions, destination)
+	}
+}
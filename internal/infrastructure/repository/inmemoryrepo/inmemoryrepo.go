@@ -2,74 +2,423 @@ package inmemoryrepo
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/AFK068/compressor/internal/domain"
 	"github.com/AFK068/compressor/internal/domain/apperrors"
-
-	rbt "github.com/emirpasic/gods/trees/redblacktree"
+	"github.com/AFK068/compressor/pkg/idalloc"
 )
 
+// InMemoryRepository reads links without locks: every slot of links holds an immutable record, which
+// is replaced as a whole on changes. Writes lock the shard of urls the destination belongs to.
 type InMemoryRepository struct {
-	urls      []string
-	urlTree   *rbt.Tree
-	shortener domain.Shortener
-	counter   uint64
-	mu        sync.Mutex
-	maxSize   uint64
+	links      *storage
+	urls       *urlIndex
+	shortener  domain.Shortener
+	ids        *idalloc.Allocator
+	compressor *Compressor
+	budget     uint64
+	// counter is one past the highest id stored.
+	counter atomic.Uint64
+	maxSize uint64
+
+	idempotency   map[string]*domain.IdempotencyRecord
+	idempotencyMu sync.Mutex
 }
 
-func New(shortener domain.Shortener, maxSize uint64) *InMemoryRepository {
-	return &InMemoryRepository{
-		urls:      make([]string, maxSize),
-		shortener: shortener,
-		urlTree:   rbt.NewWithStringComparator(),
-		maxSize:   maxSize,
+type Option func(r *InMemoryRepository)
+
+// WithIDAllocator sets the allocator of the ids of new links, which leases blocks from an
+// idalloc.MemoryLeaser by default. An allocator backed by an idalloc.FileLeaser keeps codes handed
+// out before a restart from pointing to new destinations.
+func WithIDAllocator(ids *idalloc.Allocator) Option {
+	return func(r *InMemoryRepository) {
+		r.ids = ids
 	}
 }
 
-func (r *InMemoryRepository) SaveURL(_ context.Context, originalURL string) (string, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// WithMemoryBudget makes writes fail with apperrors.ErrRepositoryIsFull once the links would use
+// more than budget bytes. The budget is unlimited when 0, the default.
+func WithMemoryBudget(budget uint64) Option {
+	return func(r *InMemoryRepository) {
+		r.budget = budget
+	}
+}
 
-	if val, ok := r.urlTree.Get(originalURL); ok {
-		return val.(string), nil
+// WithCompressor compresses the stored destinations, which are stored raw by default.
+func WithCompressor(compressor *Compressor) Option {
+	return func(r *InMemoryRepository) {
+		r.compressor = compressor
 	}
+}
 
-	if r.counter >= r.maxSize {
-		return "", &apperrors.ErrRepositoryIsFull{Message: "repository is full"}
+func New(shortener domain.Shortener, maxSize uint64, opts ...Option) *InMemoryRepository {
+	r := &InMemoryRepository{
+		urls:        newURLIndex(),
+		shortener:   shortener,
+		ids:         idalloc.New(idalloc.NewMemoryLeaser(), maxSize),
+		maxSize:     maxSize,
+		idempotency: make(map[string]*domain.IdempotencyRecord),
 	}
 
-	shortenedURL, err := r.shortener.Encode(r.counter)
-	if err != nil {
-		return "", err
+	for _, opt := range opts {
+		opt(r)
 	}
 
-	r.urls[r.counter] = originalURL
-	r.urlTree.Put(originalURL, shortenedURL)
+	r.links = newStorage(maxSize, newArena(r.compressor))
+	r.links.budget = r.budget
 
-	r.counter++
+	return r
+}
+
+// Close returns the ids leased by the repository but not used.
+func (r *InMemoryRepository) Close(ctx context.Context) error {
+	return r.ids.Close(ctx)
+}
+
+// WithTransaction implements domain.Transactor. Every operation is applied on its own and cannot be
+// rolled back, so it fails without running fn.
+func (r *InMemoryRepository) WithTransaction(context.Context, func(ctx context.Context) error) error {
+	return &apperrors.ErrNotSupported{Message: "transactions are not supported by the inmemory storage"}
+}
+
+func (r *InMemoryRepository) SaveURL(ctx context.Context, originalURL string) (string, error) {
+	link, err := r.CreateLink(ctx, &domain.Link{Destination: originalURL})
+	if err != nil {
+		return "", err
+	}
 
-	return shortenedURL, nil
+	return link.Code, nil
 }
 
-func (r *InMemoryRepository) GetURL(_ context.Context, shortenedURL string) (string, error) {
-	id, err := r.shortener.Decode(shortenedURL)
+func (r *InMemoryRepository) GetURL(ctx context.Context, shortenedURL string) (string, error) {
+	link, err := r.ResolveLink(ctx, shortenedURL)
 	if err != nil {
 		return "", err
 	}
 
-	if id >= uint64(len(r.urls)) {
+	if !link.IsResolvable(time.Now()) {
 		return "", &apperrors.ErrURLNotFound{Message: "url not found"}
 	}
 
-	r.mu.Lock()
-	originalURL := r.urls[id]
-	r.mu.Unlock()
+	return link.Destination, nil
+}
 
-	if originalURL == "" {
-		return "", &apperrors.ErrURLNotFound{Message: "url not found"}
+func (r *InMemoryRepository) CreateLink(ctx context.Context, link *domain.Link) (*domain.Link, error) {
+	hash := r.urls.hash(link.Destination)
+	shard := r.urls.shard(hash)
+
+	shard.Lock()
+	defer shard.Unlock()
+
+	existing, found := shard.lookup(hash, link.Destination, r.hasDestination(link.Destination))
+	if found {
+		if rec := r.links.load(existing); rec.resolvable(time.Now()) {
+			return r.toLink(rec)
+		}
+	}
+
+	stored := &record{
+		createdAt: time.Now().UTC(),
+		expiresAt: link.ExpiresAt,
+		owner:     link.Owner,
+	}
+
+	if err := r.storeNew(ctx, stored, link.Destination); err != nil {
+		return nil, err
+	}
+
+	// A disabled or expired link keeps resolving to its state, but the destination is shortened
+	// as the new link from now on.
+	if found {
+		shard.remove(hash, link.Destination, existing)
+	}
+
+	shard.add(hash, link.Destination, stored.id)
+
+	return newLink(stored, link.Destination), nil
+}
+
+// storeNew stores the record with the next id that is not taken by an imported link.
+func (r *InMemoryRepository) storeNew(ctx context.Context, rec *record, destination string) error {
+	for {
+		id, err := r.ids.Next(ctx)
+		if errors.Is(err, idalloc.ErrExhausted) {
+			return &apperrors.ErrRepositoryIsFull{Message: "repository is full"}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if r.links.load(id) != nil {
+			continue
+		}
+
+		rec.id = id
+
+		rec.code, err = r.shortener.Encode(id)
+		if err != nil {
+			r.ids.Release(id)
+			return err
+		}
+
+		stored, err := r.links.store(rec, destination)
+		if err != nil {
+			r.ids.Release(id)
+			return err
+		}
+
+		if stored {
+			r.raiseCounter(id)
+			return nil
+		}
+	}
+}
+
+// raiseCounter makes the counter one past id, unless it already is beyond.
+func (r *InMemoryRepository) raiseCounter(id uint64) {
+	for {
+		counter := r.counter.Load()
+		if counter > id || r.counter.CompareAndSwap(counter, id+1) {
+			return
+		}
+	}
+}
+
+// hasDestination returns whether the link with an id has the destination.
+func (r *InMemoryRepository) hasDestination(destination string) func(id uint64) bool {
+	return func(id uint64) bool {
+		rec := r.links.load(id)
+		if rec == nil {
+			return false
+		}
+
+		stored, err := r.links.destination(rec)
+
+		return err == nil && stored == destination
+	}
+}
+
+func (r *InMemoryRepository) GetLink(_ context.Context, code string) (*domain.Link, error) {
+	id, err := r.shortener.Decode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := r.links.load(id)
+	if rec == nil {
+		return nil, &apperrors.ErrURLNotFound{Message: "url not found"}
+	}
+
+	return r.toLink(rec)
+}
+
+// ResolveLink is GetLink, the repository has no replicas.
+func (r *InMemoryRepository) ResolveLink(ctx context.Context, code string) (*domain.Link, error) {
+	return r.GetLink(ctx, code)
+}
+
+func (r *InMemoryRepository) DeleteLink(_ context.Context, code string) error {
+	id, err := r.shortener.Decode(code)
+	if err != nil {
+		return err
+	}
+
+	for {
+		rec := r.links.load(id)
+		if rec == nil {
+			return &apperrors.ErrURLNotFound{Message: "url not found"}
+		}
+
+		deleted, err := r.delete(rec)
+		if err != nil || deleted {
+			return err
+		}
+	}
+}
+
+// delete removes the record unless it was changed, e.g. disabled, since it was loaded.
+func (r *InMemoryRepository) delete(rec *record) (bool, error) {
+	destination, err := r.links.destination(rec)
+	if err != nil {
+		return false, err
+	}
+
+	hash := r.urls.hash(destination)
+	shard := r.urls.shard(hash)
+
+	shard.Lock()
+	defer shard.Unlock()
+
+	if !r.links.remove(rec) {
+		return false, nil
 	}
 
-	return originalURL, nil
+	shard.remove(hash, destination, rec.id)
+
+	return true, nil
+}
+
+func (r *InMemoryRepository) ImportLink(ctx context.Context, link *domain.Link) (*domain.Link, error) {
+	id, err := r.shortener.Decode(link.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	if id >= r.maxSize {
+		return nil, &apperrors.ErrRepositoryIsFull{Message: "code is beyond the repository capacity"}
+	}
+
+	codeConflict := &apperrors.ErrLinkConflict{Message: fmt.Sprintf("code %q already exists", link.Code)}
+
+	if r.links.load(id) != nil {
+		return nil, codeConflict
+	}
+
+	hash := r.urls.hash(link.Destination)
+	shard := r.urls.shard(hash)
+
+	shard.Lock()
+	defer shard.Unlock()
+
+	existing, found := shard.lookup(hash, link.Destination, r.hasDestination(link.Destination))
+
+	// Like a new link, the imported one replaces a disabled or expired link of the destination.
+	// Otherwise it is stored without being indexed, so it resolves by its code only.
+	now := time.Now()
+	replaces := !found || (link.IsResolvable(now) && !r.links.load(existing).resolvable(now))
+
+	if err := r.ids.Advance(ctx, id+1); err != nil {
+		return nil, err
+	}
+
+	stored := &record{
+		id:        id,
+		code:      link.Code,
+		createdAt: link.CreatedAt,
+		expiresAt: link.ExpiresAt,
+		owner:     link.Owner,
+		disabled:  link.Disabled,
+	}
+
+	if stored.createdAt.IsZero() {
+		stored.createdAt = time.Now().UTC()
+	}
+
+	ok, err := r.links.store(stored, link.Destination)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, codeConflict
+	}
+
+	r.raiseCounter(id)
+
+	if replaces {
+		if found {
+			shard.remove(hash, link.Destination, existing)
+		}
+
+		shard.add(hash, link.Destination, id)
+	}
+
+	return newLink(stored, link.Destination), nil
+}
+
+func (r *InMemoryRepository) ListLinks(_ context.Context, params domain.ListLinksParams) ([]*domain.Link, error) {
+	links := make([]*domain.Link, 0, params.Limit)
+	counter := r.counter.Load()
+
+	for id := params.FromID; id < counter && len(links) < params.Limit; id++ {
+		rec := r.links.load(id)
+		if rec == nil {
+			continue
+		}
+
+		link, err := r.toLink(rec)
+		if err != nil {
+			return nil, err
+		}
+
+		links = append(links, link)
+	}
+
+	return links, nil
+}
+
+func (r *InMemoryRepository) DisableLink(_ context.Context, code string) error {
+	id, err := r.shortener.Decode(code)
+	if err != nil {
+		return err
+	}
+
+	for {
+		rec := r.links.load(id)
+		if rec == nil {
+			return &apperrors.ErrURLNotFound{Message: "url not found"}
+		}
+
+		disabled := *rec
+		disabled.disabled = true
+
+		if r.links.replace(rec, &disabled) {
+			return nil
+		}
+	}
+}
+
+// Stats counts the links without stopping writes, so links created meanwhile may be missed.
+func (r *InMemoryRepository) Stats(_ context.Context) (*domain.Stats, error) {
+	stats := &domain.Stats{Capacity: r.maxSize}
+	now := time.Now()
+	counter := r.counter.Load()
+
+	for id := uint64(0); id < counter; id++ {
+		rec := r.links.load(id)
+		if rec == nil {
+			continue
+		}
+
+		stats.Total++
+
+		switch {
+		case rec.disabled:
+			stats.Disabled++
+		case rec.expiresAt != nil && !now.Before(*rec.expiresAt):
+			stats.Expired++
+		default:
+			stats.Active++
+		}
+	}
+
+	return stats, nil
+}
+
+// toLink returns the link stored as the record, reading its destination from the arena.
+func (r *InMemoryRepository) toLink(rec *record) (*domain.Link, error) {
+	destination, err := r.links.destination(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	return newLink(rec, destination), nil
+}
+
+func newLink(rec *record, destination string) *domain.Link {
+	return &domain.Link{
+		ID:          rec.id,
+		Code:        rec.code,
+		Destination: destination,
+		CreatedAt:   rec.createdAt,
+		ExpiresAt:   rec.expiresAt,
+		Owner:       rec.owner,
+		Disabled:    rec.disabled,
+	}
 }
@@ -0,0 +1,173 @@
+package inmemoryrepo_test
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/AFK068/compressor/internal/infrastructure/repository/inmemoryrepo"
+	"github.com/AFK068/compressor/pkg/shortener"
+)
+
+const (
+	benchmarkMaxSize = 1 << 22
+	benchmarkLinks   = 10_000
+	// Every benchmarkWriteEvery-th operation of BenchmarkMixed creates a link.
+	benchmarkWriteEvery = 10
+)
+
+// setupBenchmark returns a repository holding benchmarkLinks links and their codes.
+func setupBenchmark(b *testing.B) (*inmemoryrepo.InMemoryRepository, []string) {
+	b.Helper()
+
+	s, err := shortener.NewShortener("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_", 10)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	repo := inmemoryrepo.New(s, benchmarkMaxSize)
+	codes := make([]string, benchmarkLinks)
+
+	for i := range codes {
+		if codes[i], err = repo.SaveURL(context.Background(), fmt.Sprintf("http://example.com/%d", i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+
+	return repo, codes
+}
+
+// Run with: go test -run '^$' -bench . -cpu 1,2,4,8 ./internal/infrastructure/repository/inmemoryrepo/
+func BenchmarkGetURL(b *testing.B) {
+	repo, codes := setupBenchmark(b)
+
+	var next atomic.Uint64
+
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := context.Background()
+		i := next.Add(benchmarkLinks / 7)
+
+		for pb.Next() {
+			i++
+
+			if _, err := repo.GetURL(ctx, codes[i%benchmarkLinks]); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkSaveURL(b *testing.B) {
+	repo, _ := setupBenchmark(b)
+
+	var next atomic.Uint64
+
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := context.Background()
+
+		for pb.Next() {
+			if _, err := repo.SaveURL(ctx, fmt.Sprintf("http://example.com/new/%d", next.Add(1))); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkMixed(b *testing.B) {
+	repo, codes := setupBenchmark(b)
+
+	var next atomic.Uint64
+
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := context.Background()
+
+		for pb.Next() {
+			i := next.Add(1)
+
+			var err error
+			if i%benchmarkWriteEvery == 0 {
+				_, err = repo.SaveURL(ctx, fmt.Sprintf("http://example.com/new/%d", i))
+			} else {
+				_, err = repo.GetURL(ctx, codes[i%benchmarkLinks])
+			}
+
+			if err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// benchmarkFootprintLinks is how many links BenchmarkFootprint stores, so it needs a few GB of
+// memory.
+const benchmarkFootprintLinks = 10_000_000
+
+var (
+	benchmarkHosts    = []string{"shop.example.com", "news.example.org", "www.example.net", "blog.example.io", "m.example.com"}
+	benchmarkSources  = []string{"newsletter", "twitter", "facebook", "google", "partner"}
+	benchmarkMediums  = []string{"email", "social", "cpc", "referral"}
+	benchmarkSections = []string{"products", "articles", "campaigns", "offers", "posts"}
+)
+
+// trackingURL returns a destination like the long tracking URLs that dominate real traffic.
+func trackingURL(i int) string {
+	return fmt.Sprintf("https://%s/%s/%d/%x?utm_source=%s&utm_medium=%s&utm_campaign=spring-%d&utm_content=%x&ref=%d",
+		benchmarkHosts[i%len(benchmarkHosts)], benchmarkSections[i/7%len(benchmarkSections)], i/100, i*2654435761,
+		benchmarkSources[i/3%len(benchmarkSources)], benchmarkMediums[i/11%len(benchmarkMediums)], i%50, i*40503, i)
+}
+
+// Run with: go test -run '^$' -bench Footprint -benchtime 1x ./internal/infrastructure/repository/inmemoryrepo/
+func BenchmarkFootprint(b *testing.B) {
+	s, err := shortener.NewShortener("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_", 10)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	variants := []struct {
+		name string
+		opts func(b *testing.B) []inmemoryrepo.Option
+	}{
+		{"raw", func(*testing.B) []inmemoryrepo.Option { return nil }},
+		{"zstd", func(b *testing.B) []inmemoryrepo.Option {
+			compressor, err := inmemoryrepo.NewCompressor(nil, inmemoryrepo.DefaultTrainingSamples)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			return []inmemoryrepo.Option{inmemoryrepo.WithCompressor(compressor)}
+		}},
+	}
+
+	for _, variant := range variants {
+		b.Run(variant.name, func(b *testing.B) {
+			for range b.N {
+				var before, after runtime.MemStats
+
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+
+				repo := inmemoryrepo.New(s, benchmarkFootprintLinks, variant.opts(b)...)
+
+				for i := range benchmarkFootprintLinks {
+					if _, err := repo.SaveURL(context.Background(), trackingURL(i)); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+
+				b.ReportMetric(float64(repo.Footprint())/benchmarkFootprintLinks, "footprint-B/link")
+				b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/benchmarkFootprintLinks, "heap-B/link")
+				runtime.KeepAlive(repo)
+			}
+		})
+	}
+}
@@ -2,10 +2,18 @@ package inmemoryrepo_test
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/AFK068/compressor/internal/domain"
 	"github.com/AFK068/compressor/internal/domain/apperrors"
 	"github.com/AFK068/compressor/internal/infrastructure/repository/inmemoryrepo"
+	"github.com/AFK068/compressor/pkg/idalloc"
+	"github.com/AFK068/compressor/pkg/shortener"
 	"github.com/stretchr/testify/assert"
 
 	shortenermock "github.com/AFK068/compressor/internal/domain/mocks"
@@ -62,6 +70,28 @@ func Test_SaveURL_RepoIsFull_Failure(t *testing.T) {
 	shortenerMock.AssertExpectations(t)
 }
 
+func Test_CreateLink_RepoIsFull_KeepsFootprint_Failure(t *testing.T) {
+	shortenerMock := shortenermock.NewShortener(t)
+	repo := inmemoryrepo.New(shortenerMock, 1)
+
+	shortenerMock.On("Encode", uint64(0)).Return("shortenedURL", nil).Once()
+
+	_, err := repo.SaveURL(context.Background(), "http://example.com")
+	assert.NoError(t, err)
+
+	footprint := repo.Footprint()
+
+	// The destination needs an arena chunk of its own, which must not be allocated for a link
+	// that is not stored.
+	long := "http://example.com/" + strings.Repeat("a", 300<<10)
+
+	_, err = repo.CreateLink(context.Background(), &domain.Link{Destination: long})
+	assert.IsType(t, &apperrors.ErrRepositoryIsFull{}, err)
+	assert.Equal(t, footprint, repo.Footprint())
+
+	shortenerMock.AssertExpectations(t)
+}
+
 func Test_SaveURL_EncodeError_Failure(t *testing.T) {
 	shortenerMock := shortenermock.NewShortener(t)
 	repo := inmemoryrepo.New(shortenerMock, 10)
@@ -117,3 +147,490 @@ func Test_GetURL_URLNotFound_Failure(t *testing.T) {
 
 	shortenerMock.AssertExpectations(t)
 }
+
+func Test_CreateLink_Success(t *testing.T) {
+	shortenerMock := shortenermock.NewShortener(t)
+	repo := inmemoryrepo.New(shortenerMock, 10)
+
+	shortenerMock.On("Encode", uint64(0)).Return("shortenedURL", nil).Once()
+	shortenerMock.On("Decode", "shortenedURL").Return(uint64(0), nil).Once()
+
+	expiresAt := time.Now().Add(time.Hour)
+
+	created, err := repo.CreateLink(context.Background(), &domain.Link{
+		Destination: "http://example.com",
+		ExpiresAt:   &expiresAt,
+		Owner:       "team-a",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), created.ID)
+	assert.Equal(t, "shortenedURL", created.Code)
+	assert.False(t, created.CreatedAt.IsZero())
+
+	link, err := repo.GetLink(context.Background(), "shortenedURL")
+	assert.NoError(t, err)
+	assert.Equal(t, created, link)
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_CreateLink_DisabledExisting_Success(t *testing.T) {
+	shortenerMock := shortenermock.NewShortener(t)
+	repo := inmemoryrepo.New(shortenerMock, 10)
+
+	shortenerMock.On("Encode", uint64(0)).Return("shortenedURL", nil).Once()
+	shortenerMock.On("Encode", uint64(1)).Return("shortenedURL2", nil).Once()
+	shortenerMock.On("Decode", "shortenedURL").Return(uint64(0), nil).Twice()
+
+	_, err := repo.SaveURL(context.Background(), "http://example.com")
+	assert.NoError(t, err)
+
+	err = repo.DisableLink(context.Background(), "shortenedURL")
+	assert.NoError(t, err)
+
+	created, err := repo.CreateLink(context.Background(), &domain.Link{Destination: "http://example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, "shortenedURL2", created.Code)
+	assert.False(t, created.Disabled)
+
+	again, err := repo.CreateLink(context.Background(), &domain.Link{Destination: "http://example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, created, again)
+
+	link, err := repo.GetLink(context.Background(), "shortenedURL")
+	assert.NoError(t, err)
+	assert.True(t, link.Disabled)
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_CreateLink_ExpiredExisting_Success(t *testing.T) {
+	shortenerMock := shortenermock.NewShortener(t)
+	repo := inmemoryrepo.New(shortenerMock, 10)
+
+	shortenerMock.On("Encode", uint64(0)).Return("shortenedURL", nil).Once()
+	shortenerMock.On("Encode", uint64(1)).Return("shortenedURL2", nil).Once()
+	shortenerMock.On("Decode", "shortenedURL").Return(uint64(0), nil).Once()
+
+	expiresAt := time.Now().Add(-time.Minute)
+
+	_, err := repo.CreateLink(context.Background(), &domain.Link{Destination: "http://example.com", ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+
+	created, err := repo.CreateLink(context.Background(), &domain.Link{Destination: "http://example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, "shortenedURL2", created.Code)
+
+	// Deleting the replaced link keeps the destination shortened as the new one.
+	err = repo.DeleteLink(context.Background(), "shortenedURL")
+	assert.NoError(t, err)
+
+	again, err := repo.CreateLink(context.Background(), &domain.Link{Destination: "http://example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, created, again)
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_GetURL_Expired_Failure(t *testing.T) {
+	shortenerMock := shortenermock.NewShortener(t)
+	repo := inmemoryrepo.New(shortenerMock, 10)
+
+	shortenerMock.On("Encode", uint64(0)).Return("shortenedURL", nil).Once()
+	shortenerMock.On("Decode", "shortenedURL").Return(uint64(0), nil).Once()
+
+	expiresAt := time.Now().Add(-time.Second)
+
+	_, err := repo.CreateLink(context.Background(), &domain.Link{Destination: "http://example.com", ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+
+	_, err = repo.GetURL(context.Background(), "shortenedURL")
+	assert.IsType(t, &apperrors.ErrURLNotFound{}, err)
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_DeleteLink_Success(t *testing.T) {
+	shortenerMock := shortenermock.NewShortener(t)
+	repo := inmemoryrepo.New(shortenerMock, 10)
+
+	shortenerMock.On("Encode", uint64(0)).Return("shortenedURL", nil).Once()
+	shortenerMock.On("Encode", uint64(1)).Return("shortenedURL2", nil).Once()
+	shortenerMock.On("Decode", "shortenedURL").Return(uint64(0), nil).Times(3)
+
+	_, err := repo.SaveURL(context.Background(), "http://example.com")
+	assert.NoError(t, err)
+
+	err = repo.DeleteLink(context.Background(), "shortenedURL")
+	assert.NoError(t, err)
+
+	_, err = repo.GetURL(context.Background(), "shortenedURL")
+	assert.IsType(t, &apperrors.ErrURLNotFound{}, err)
+
+	err = repo.DeleteLink(context.Background(), "shortenedURL")
+	assert.IsType(t, &apperrors.ErrURLNotFound{}, err)
+
+	short, err := repo.SaveURL(context.Background(), "http://example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "shortenedURL2", short)
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_WithTransaction_NotSupported_Failure(t *testing.T) {
+	repo := inmemoryrepo.New(shortenermock.NewShortener(t), 10)
+	called := false
+
+	err := repo.WithTransaction(context.Background(), func(context.Context) error {
+		called = true
+		return nil
+	})
+	assert.IsType(t, &apperrors.ErrNotSupported{}, err)
+	assert.False(t, called)
+}
+
+func Test_ReserveIdempotencyKey_FirstWins_Success(t *testing.T) {
+	repo := inmemoryrepo.New(shortenermock.NewShortener(t), 10)
+
+	now := time.Now()
+
+	first := &domain.IdempotencyRecord{Key: "key", RequestHash: "hash-1", CreatedAt: now, ExpiresAt: now.Add(time.Minute)}
+
+	existing, err := repo.ReserveIdempotencyKey(context.Background(), first)
+	assert.NoError(t, err)
+	assert.Nil(t, existing)
+
+	second := &domain.IdempotencyRecord{Key: "key", RequestHash: "hash-2", CreatedAt: now.Add(time.Millisecond), ExpiresAt: now.Add(time.Minute)}
+
+	existing, err = repo.ReserveIdempotencyKey(context.Background(), second)
+	assert.NoError(t, err)
+	assert.Equal(t, "hash-1", existing.RequestHash)
+	assert.True(t, existing.InFlight())
+
+	// Only the request that reserved the key completes it.
+	second.StatusCode = 200
+	err = repo.CompleteIdempotencyRecord(context.Background(), second)
+	assert.IsType(t, &apperrors.ErrIdempotencyRecordNotFound{}, err)
+
+	first.StatusCode, first.Body, first.ExpiresAt = 200, []byte("first"), now.Add(time.Hour)
+	first.Headers = map[string][]string{"Location": {"/v2/links/aaab"}}
+	err = repo.CompleteIdempotencyRecord(context.Background(), first)
+	assert.NoError(t, err)
+
+	record, err := repo.GetIdempotencyRecord(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.False(t, record.InFlight())
+	assert.Equal(t, []byte("first"), record.Body)
+	assert.Equal(t, first.Headers, record.Headers)
+
+	// A completed record is not released.
+	err = repo.ReleaseIdempotencyKey(context.Background(), first)
+	assert.NoError(t, err)
+
+	_, err = repo.GetIdempotencyRecord(context.Background(), "key")
+	assert.NoError(t, err)
+}
+
+func Test_ReleaseIdempotencyKey_Success(t *testing.T) {
+	repo := inmemoryrepo.New(shortenermock.NewShortener(t), 10)
+
+	now := time.Now()
+	record := &domain.IdempotencyRecord{Key: "key", RequestHash: "hash", CreatedAt: now, ExpiresAt: now.Add(time.Minute)}
+
+	_, err := repo.ReserveIdempotencyKey(context.Background(), record)
+	assert.NoError(t, err)
+
+	err = repo.ReleaseIdempotencyKey(context.Background(), record)
+	assert.NoError(t, err)
+
+	existing, err := repo.ReserveIdempotencyKey(context.Background(), record)
+	assert.NoError(t, err)
+	assert.Nil(t, existing)
+}
+
+func Test_GetIdempotencyRecord_Expired_Failure(t *testing.T) {
+	repo := inmemoryrepo.New(shortenermock.NewShortener(t), 10)
+
+	_, err := repo.ReserveIdempotencyKey(context.Background(), &domain.IdempotencyRecord{
+		Key: "key", RequestHash: "hash", ExpiresAt: time.Now().Add(-time.Second),
+	})
+	assert.NoError(t, err)
+
+	_, err = repo.GetIdempotencyRecord(context.Background(), "key")
+	assert.IsType(t, &apperrors.ErrIdempotencyRecordNotFound{}, err)
+
+	deleted, err := repo.DeleteExpiredIdempotencyRecords(context.Background(), time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func Test_ListLinks_Success(t *testing.T) {
+	shortenerMock := shortenermock.NewShortener(t)
+	repo := inmemoryrepo.New(shortenerMock, 10)
+
+	shortenerMock.On("Encode", uint64(0)).Return("shortenedURL", nil).Once()
+	shortenerMock.On("Encode", uint64(1)).Return("shortenedURL2", nil).Once()
+	shortenerMock.On("Encode", uint64(2)).Return("shortenedURL3", nil).Once()
+
+	for _, url := range []string{"http://example.com", "http://example.com/2", "http://example.com/3"} {
+		_, err := repo.SaveURL(context.Background(), url)
+		assert.NoError(t, err)
+	}
+
+	links, err := repo.ListLinks(context.Background(), domain.ListLinksParams{Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, links, 2)
+	assert.Equal(t, "shortenedURL2", links[1].Code)
+
+	links, err = repo.ListLinks(context.Background(), domain.ListLinksParams{FromID: links[1].ID + 1, Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, links, 1)
+	assert.Equal(t, "http://example.com/3", links[0].Destination)
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_DisableLink_Success(t *testing.T) {
+	shortenerMock := shortenermock.NewShortener(t)
+	repo := inmemoryrepo.New(shortenerMock, 10)
+
+	shortenerMock.On("Encode", uint64(0)).Return("shortenedURL", nil).Once()
+	shortenerMock.On("Encode", uint64(1)).Return("shortenedURL2", nil).Once()
+	shortenerMock.On("Decode", "shortenedURL").Return(uint64(0), nil).Twice()
+
+	_, err := repo.SaveURL(context.Background(), "http://example.com")
+	assert.NoError(t, err)
+
+	_, err = repo.SaveURL(context.Background(), "http://example.com/2")
+	assert.NoError(t, err)
+
+	err = repo.DisableLink(context.Background(), "shortenedURL")
+	assert.NoError(t, err)
+
+	link, err := repo.GetLink(context.Background(), "shortenedURL")
+	assert.NoError(t, err)
+	assert.True(t, link.Disabled)
+
+	stats, err := repo.Stats(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.Stats{Total: 2, Active: 1, Disabled: 1, Capacity: 10}, stats)
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_DisableLink_ConcurrentGetLink_Success(t *testing.T) {
+	shortenerMock := shortenermock.NewShortener(t)
+	repo := inmemoryrepo.New(shortenerMock, 10)
+
+	shortenerMock.On("Encode", uint64(0)).Return("shortenedURL", nil).Once()
+	shortenerMock.On("Decode", "shortenedURL").Return(uint64(0), nil)
+
+	_, err := repo.SaveURL(context.Background(), "http://example.com")
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+
+	for range 10 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, _ = repo.GetLink(context.Background(), "shortenedURL")
+		}()
+	}
+
+	err = repo.DisableLink(context.Background(), "shortenedURL")
+	assert.NoError(t, err)
+
+	wg.Wait()
+
+	link, err := repo.GetLink(context.Background(), "shortenedURL")
+	assert.NoError(t, err)
+	assert.True(t, link.Disabled)
+}
+
+func Test_ImportLink_Success(t *testing.T) {
+	shortenerMock := shortenermock.NewShortener(t)
+	repo := inmemoryrepo.New(shortenerMock, 10)
+
+	createdAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	shortenerMock.On("Decode", "importedURL").Return(uint64(5), nil).Twice()
+	shortenerMock.On("Encode", uint64(6)).Return("shortenedURL", nil).Once()
+
+	link, err := repo.ImportLink(context.Background(), &domain.Link{
+		Code: "importedURL", Destination: "http://example.com", CreatedAt: createdAt, Owner: "team-a",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), link.ID)
+	assert.Equal(t, createdAt, link.CreatedAt)
+
+	_, err = repo.ImportLink(context.Background(), &domain.Link{Code: "importedURL", Destination: "http://example.com/2"})
+	assert.IsType(t, &apperrors.ErrLinkConflict{}, err)
+
+	short, err := repo.SaveURL(context.Background(), "http://example.com/2")
+	assert.NoError(t, err)
+	assert.Equal(t, "shortenedURL", short)
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_ImportLink_NonCanonical_Success(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := shortener.NewShortener("abcdefgh", 4)
+	assert.NoError(t, err)
+
+	repo := inmemoryrepo.New(s, 10)
+
+	importLink := func(id uint64, destination string, disabled bool) string {
+		code, err := s.Encode(id)
+		assert.NoError(t, err)
+
+		_, err = repo.ImportLink(ctx, &domain.Link{Code: code, Destination: destination, Disabled: disabled})
+		assert.NoError(t, err)
+
+		return code
+	}
+
+	// The disabled link is imported first and replaced, or imported after its replacement and kept
+	// out of the index.
+	importLink(1, "http://example.com/1", true)
+	replacement1 := importLink(2, "http://example.com/1", false)
+	replacement2 := importLink(3, "http://example.com/2", false)
+	disabled2 := importLink(4, "http://example.com/2", true)
+	importLink(5, "http://example.com/2", false)
+
+	for destination, code := range map[string]string{"http://example.com/1": replacement1, "http://example.com/2": replacement2} {
+		link, err := repo.CreateLink(ctx, &domain.Link{Destination: destination})
+		assert.NoError(t, err)
+		assert.Equal(t, code, link.Code)
+	}
+
+	link, err := repo.GetLink(ctx, disabled2)
+	assert.NoError(t, err)
+	assert.True(t, link.Disabled)
+
+	// Deleting a link that is not indexed leaves the destination to the indexed one.
+	assert.NoError(t, repo.DeleteLink(ctx, disabled2))
+
+	link, err = repo.CreateLink(ctx, &domain.Link{Destination: "http://example.com/2"})
+	assert.NoError(t, err)
+	assert.Equal(t, replacement2, link.Code)
+}
+
+func Test_CreateLink_SkipsImportedID_Success(t *testing.T) {
+	shortenerMock := shortenermock.NewShortener(t)
+	repo := inmemoryrepo.New(shortenerMock, 10)
+
+	shortenerMock.On("Encode", uint64(0)).Return("firstURL", nil).Once()
+	shortenerMock.On("Decode", "importedURL").Return(uint64(1), nil).Once()
+	shortenerMock.On("Encode", uint64(2)).Return("secondURL", nil).Once()
+
+	_, err := repo.SaveURL(context.Background(), "http://example.com/1")
+	assert.NoError(t, err)
+
+	// The id of the imported link belongs to the block the repository already leased.
+	_, err = repo.ImportLink(context.Background(), &domain.Link{Code: "importedURL", Destination: "http://example.com/imported"})
+	assert.NoError(t, err)
+
+	short, err := repo.SaveURL(context.Background(), "http://example.com/2")
+	assert.NoError(t, err)
+	assert.Equal(t, "secondURL", short)
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_Close_FileAllocator_Success(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ids.json")
+
+	shortenerMock := shortenermock.NewShortener(t)
+	shortenerMock.On("Encode", uint64(0)).Return("firstURL", nil).Once()
+	shortenerMock.On("Encode", uint64(1)).Return("secondURL", nil).Once()
+
+	repo := inmemoryrepo.New(shortenerMock, 10, inmemoryrepo.WithIDAllocator(idalloc.New(idalloc.NewFileLeaser(path), 10)))
+
+	_, err := repo.SaveURL(ctx, "http://example.com/1")
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Close(ctx))
+
+	// After a restart the codes handed out before are not handed out again.
+	restarted := inmemoryrepo.New(shortenerMock, 10, inmemoryrepo.WithIDAllocator(idalloc.New(idalloc.NewFileLeaser(path), 10)))
+
+	short, err := restarted.SaveURL(ctx, "http://example.com/2")
+	assert.NoError(t, err)
+	assert.Equal(t, "secondURL", short)
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_SaveURL_Concurrent_Success(t *testing.T) {
+	const workers, destinations = 16, 50
+
+	s, err := shortener.NewShortener("abcdefgh", 4)
+	assert.NoError(t, err)
+
+	repo := inmemoryrepo.New(s, 1000)
+	codes := make([][]string, workers)
+
+	var wg sync.WaitGroup
+
+	for i := range workers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			// Every worker shortens the same destinations.
+			for j := range destinations {
+				code, err := repo.SaveURL(context.Background(), fmt.Sprintf("http://example.com/%d", j))
+				assert.NoError(t, err)
+
+				codes[i] = append(codes[i], code)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	for i := range workers {
+		assert.Equal(t, codes[0], codes[i])
+	}
+
+	stats, err := repo.Stats(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(destinations), stats.Total)
+}
+
+func Test_CreateLink_GrowsLazily_Success(t *testing.T) {
+	s, err := shortener.NewShortener("abcdefgh", 10)
+	assert.NoError(t, err)
+
+	// The capacity would take gigabytes if it was allocated upfront.
+	repo := inmemoryrepo.New(s, 3e9)
+	assert.Zero(t, repo.Footprint())
+
+	_, err = repo.SaveURL(context.Background(), "http://example.com")
+	assert.NoError(t, err)
+	assert.Less(t, repo.Footprint(), uint64(1<<20))
+}
+
+func Test_CreateLink_MemoryBudget_Failure(t *testing.T) {
+	const budget = 4 << 20
+
+	s, err := shortener.NewShortener("abcdefgh", 10)
+	assert.NoError(t, err)
+
+	repo := inmemoryrepo.New(s, 3e9, inmemoryrepo.WithMemoryBudget(budget))
+
+	var saveErr error
+
+	for i := 0; saveErr == nil; i++ {
+		_, saveErr = repo.SaveURL(context.Background(), fmt.Sprintf("http://example.com/%d", i))
+	}
+
+	assert.IsType(t, &apperrors.ErrRepositoryIsFull{}, saveErr)
+	assert.LessOrEqual(t, repo.Footprint(), uint64(budget))
+}
@@ -0,0 +1,32 @@
+package inmemoryrepo
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	footprintDesc = prometheus.NewDesc(
+		"compressor_inmemory_footprint_bytes",
+		"Approximate memory used by the stored links and their segments.", nil, nil,
+	)
+	memoryBudgetDesc = prometheus.NewDesc(
+		"compressor_inmemory_memory_budget_bytes",
+		"Memory the links may use before writes are refused, 0 when unlimited.", nil, nil,
+	)
+)
+
+// Footprint returns the approximate memory in bytes used by the stored links and their segments.
+// Idempotency records are not accounted for.
+func (r *InMemoryRepository) Footprint() uint64 {
+	return r.links.footprint.Load()
+}
+
+// Describe implements prometheus.Collector.
+func (r *InMemoryRepository) Describe(ch chan<- *prometheus.Desc) {
+	ch <- footprintDesc
+	ch <- memoryBudgetDesc
+}
+
+// Collect implements prometheus.Collector.
+func (r *InMemoryRepository) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(footprintDesc, prometheus.GaugeValue, float64(r.Footprint()))
+	ch <- prometheus.MustNewConstMetric(memoryBudgetDesc, prometheus.GaugeValue, float64(r.links.budget))
+}
@@ -0,0 +1,175 @@
+package inmemoryrepo
+
+import (
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/AFK068/compressor/internal/domain/apperrors"
+)
+
+const (
+	// segmentSize is how many link slots are allocated at once, 512 KiB worth of pointers.
+	segmentSize  = 1 << 16
+	segmentBytes = segmentSize * uint64(unsafe.Sizeof(atomic.Pointer[record]{}))
+
+	// indexEntryBytes approximates what an entry of the destination index costs.
+	indexEntryBytes = 24
+	recordBytes     = uint64(unsafe.Sizeof(record{})) + indexEntryBytes
+)
+
+// record is a stored link. Its destination is kept in the arena.
+type record struct {
+	id          uint64
+	code        string
+	destination entryRef
+	createdAt   time.Time
+	expiresAt   *time.Time
+	owner       string
+	disabled    bool
+}
+
+// resolvable reports whether the link may be followed: it is neither disabled nor expired.
+func (rec *record) resolvable(now time.Time) bool {
+	return !rec.disabled && (rec.expiresAt == nil || now.Before(*rec.expiresAt))
+}
+
+// reserved holds the slot of a link while its destination is put in the arena. It is never
+// returned by load.
+var reserved = &record{}
+
+type segment [segmentSize]atomic.Pointer[record]
+
+// storage holds the links in segments allocated when the first of their ids is stored, so the
+// memory used grows with the links instead of the capacity, and their destinations in an arena.
+// It accounts for the memory used by segments, records and arena chunks, and refuses to grow
+// beyond the budget.
+type storage struct {
+	segments  []atomic.Pointer[segment]
+	arena     *arena
+	footprint atomic.Uint64
+	budget    uint64
+}
+
+func newStorage(capacity uint64, arena *arena) *storage {
+	return &storage{
+		segments: make([]atomic.Pointer[segment], (capacity+segmentSize-1)/segmentSize),
+		arena:    arena,
+	}
+}
+
+func (s *storage) destination(rec *record) (string, error) {
+	return s.arena.get(rec.destination)
+}
+
+// load returns the link with the id, or nil when there is none.
+func (s *storage) load(id uint64) *record {
+	if id/segmentSize >= uint64(len(s.segments)) {
+		return nil
+	}
+
+	seg := s.segments[id/segmentSize].Load()
+	if seg == nil {
+		return nil
+	}
+
+	if rec := seg[id%segmentSize].Load(); rec != reserved {
+		return rec
+	}
+
+	return nil
+}
+
+// slot returns the slot of the id, allocating its segment when needed. The id must be below the
+// capacity.
+func (s *storage) slot(id uint64) (*atomic.Pointer[record], error) {
+	seg := s.segments[id/segmentSize].Load()
+
+	if seg == nil {
+		if err := s.reserve(segmentBytes); err != nil {
+			return nil, err
+		}
+
+		seg = new(segment)
+
+		if !s.segments[id/segmentSize].CompareAndSwap(nil, seg) {
+			s.release(segmentBytes)
+			seg = s.segments[id/segmentSize].Load()
+		}
+	}
+
+	return &seg[id%segmentSize], nil
+}
+
+// store stores the link with its id unless the id is taken, which is reported by ok. The
+// destination is put in the arena, which never gives entries back, only once the slot and the
+// memory of the link are reserved, so a link that is not stored leaves no entry behind.
+func (s *storage) store(rec *record, destination string) (ok bool, err error) {
+	slot, err := s.slot(rec.id)
+	if err != nil {
+		return false, err
+	}
+
+	if err := s.reserve(sizeOf(rec)); err != nil {
+		return false, err
+	}
+
+	if !slot.CompareAndSwap(nil, reserved) {
+		s.release(sizeOf(rec))
+		return false, nil
+	}
+
+	rec.destination, err = s.arena.put(destination, s.reserve)
+	if err != nil {
+		slot.Store(nil)
+		s.release(sizeOf(rec))
+
+		return false, err
+	}
+
+	slot.Store(rec)
+
+	return true, nil
+}
+
+// remove clears the slot of the link unless it holds another version of it.
+func (s *storage) remove(rec *record) bool {
+	seg := s.segments[rec.id/segmentSize].Load()
+
+	if !seg[rec.id%segmentSize].CompareAndSwap(rec, nil) {
+		return false
+	}
+
+	s.release(sizeOf(rec))
+
+	return true
+}
+
+// replace swaps the version of a link for another one of the same size.
+func (s *storage) replace(old, updated *record) bool {
+	seg := s.segments[old.id/segmentSize].Load()
+
+	return seg[old.id%segmentSize].CompareAndSwap(old, updated)
+}
+
+func (s *storage) reserve(n uint64) error {
+	for {
+		footprint := s.footprint.Load()
+		if s.budget > 0 && footprint+n > s.budget {
+			return &apperrors.ErrRepositoryIsFull{Message: "memory budget of the repository is exhausted"}
+		}
+
+		if s.footprint.CompareAndSwap(footprint, footprint+n) {
+			return nil
+		}
+	}
+}
+
+func (s *storage) release(n uint64) {
+	s.footprint.Add(-n)
+}
+
+// sizeOf approximates the memory a stored link uses besides its destination.
+func sizeOf(rec *record) uint64 {
+	return recordBytes + uint64(len(rec.code)+len(rec.owner))
+}
@@ -0,0 +1,380 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/infrastructure/repository/postgresdb"
+	"github.com/AFK068/compressor/internal/migration"
+	"github.com/AFK068/compressor/pkg/storage"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresSettings configure the postgres storage, read from storage.postgres.
+type PostgresSettings struct {
+	Host         string `yaml:"host" env:"POSTGRES_HOST"`
+	Port         string `yaml:"port" env:"POSTGRES_PORT"`
+	DatabaseName string `yaml:"database_name" env:"POSTGRES_DATABASE_NAME"`
+	User         string `yaml:"user" env:"POSTGRES_USER"`
+	Password     string `yaml:"password" env:"POSTGRES_PASSWORD"`
+	// PasswordFile is read on startup and replaces password, e.g. to use a Docker or Kubernetes secret.
+	PasswordFile string `yaml:"password_file" env:"POSTGRES_PASSWORD_FILE"`
+
+	TLS  PostgresTLS  `yaml:"tls"`
+	Pool PostgresPool `yaml:"pool"`
+
+	// ConnectTimeout limits establishing a connection, 0 waits as long as the dialer does.
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"POSTGRES_CONNECT_TIMEOUT" env-default:"5s"`
+	// StatementTimeout aborts queries of the service that run longer, 0 disables the limit.
+	// Migrations are not limited.
+	StatementTimeout time.Duration `yaml:"statement_timeout" env:"POSTGRES_STATEMENT_TIMEOUT" env-default:"0s"`
+	// ApplicationName is shown in pg_stat_activity, unless the DSN sets one.
+	ApplicationName string `yaml:"application_name" env:"POSTGRES_APPLICATION_NAME" env-default:"compressor"`
+
+	// PrimaryDSN is the postgres:// URL of the primary, used instead of host, port, database_name,
+	// user and password when set.
+	PrimaryDSN string `yaml:"primary_dsn" env:"POSTGRES_PRIMARY_DSN"`
+	// ReplicaDSNs are the read replicas links are resolved from, in turn. Links a replica does not
+	// have yet, e.g. just created ones, are resolved from the primary.
+	ReplicaDSNs []string `yaml:"replica_dsns" env:"POSTGRES_REPLICA_DSNS" env-separator:","`
+}
+
+// PostgresTLS is applied to the connection string built from host, port, database_name, user and
+// password. DSNs carry their own sslmode, sslrootcert, sslcert and sslkey parameters.
+type PostgresTLS struct {
+	// Mode is the libpq sslmode: disable, allow, prefer, require, verify-ca or verify-full.
+	Mode string `yaml:"mode" env:"POSTGRES_SSL_MODE" env-default:"disable"`
+	// RootCert is the CA certificate file the server certificate is verified against.
+	RootCert string `yaml:"root_cert" env:"POSTGRES_SSL_ROOT_CERT"`
+	// Cert and Key are the client certificate and its private key, for certificate authentication.
+	Cert string `yaml:"cert" env:"POSTGRES_SSL_CERT"`
+	Key  string `yaml:"key" env:"POSTGRES_SSL_KEY"`
+}
+
+// PostgresPool sizes the primary pool and every replica pool. Zero values keep the pgxpool defaults.
+type PostgresPool struct {
+	MaxConns        int32         `yaml:"max_conns" env:"POSTGRES_MAX_CONNS"`
+	MinConns        int32         `yaml:"min_conns" env:"POSTGRES_MIN_CONNS"`
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime" env:"POSTGRES_MAX_CONN_LIFETIME"`
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time" env:"POSTGRES_MAX_CONN_IDLE_TIME"`
+}
+
+// postgresDriver applies the pending changesets on startup, unless migrations.disable_auto_migrate
+// is set, and connects to the primary and the replicas.
+type postgresDriver struct{}
+
+func (postgresDriver) NewSettings() any {
+	return &PostgresSettings{}
+}
+
+func (postgresDriver) Open(ctx context.Context, opts storage.Options) (storage.Backend, func(), error) {
+	cfg, log := opts.Config, opts.Logger
+	settings := cfg.Storage.DriverSettings.(*PostgresSettings)
+
+	if !cfg.Migration.DisableAutoMigrate {
+		if err := migration.RunMigration(cfg, settings.MigrationDSN(), log); err != nil {
+			return nil, nil, fmt.Errorf("running migration: %w", err)
+		}
+	}
+
+	dbPool, err := newPool(ctx, settings, settings.ConnectionString())
+	if err != nil {
+		return nil, nil, fmt.Errorf("connecting to database: %w", err)
+	}
+
+	replicas := make([]*pgxpool.Pool, 0, len(settings.ReplicaDSNs))
+
+	closePools := func() {
+		for _, replica := range replicas {
+			replica.Close()
+		}
+
+		dbPool.Close()
+	}
+
+	for i, dsn := range settings.ReplicaDSNs {
+		replica, err := newPool(ctx, settings, dsn)
+		if err != nil {
+			closePools()
+			return nil, nil, fmt.Errorf("connecting to replica %d: %w", i, err)
+		}
+
+		replicas = append(replicas, replica)
+	}
+
+	repo := postgresdb.New(dbPool, opts.Shortener, cfg.Storage.MaxSize,
+		postgresdb.WithReplicas(replicas...),
+		postgresdb.WithIDAllocator(newIDAllocator(cfg, postgresdb.NewIDLeaser(dbPool))),
+	)
+
+	return repo, func() {
+		closeIDs(repo, log)
+		closePools()
+	}, nil
+}
+
+func newPool(ctx context.Context, settings *PostgresSettings, dsn string) (*pgxpool.Pool, error) {
+	poolConfig, err := settings.PoolConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgxpool.NewWithConfig(ctx, poolConfig)
+}
+
+func (s *PostgresSettings) Validate() error {
+	errs := &fieldErrors{}
+
+	if s.PrimaryDSN != "" {
+		u, err := url.Parse(s.PrimaryDSN)
+		if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") || u.Host == "" {
+			errs.add("primary_dsn", errors.New("primary_dsn must be a postgres:// URL"))
+		}
+	} else {
+		required := []struct{ path, value string }{
+			{"host", s.Host},
+			{"port", s.Port},
+			{"database_name", s.DatabaseName},
+			{"user", s.User},
+			{"password", s.Password},
+		}
+
+		for _, field := range required {
+			if field.value == "" {
+				errs.add(field.path, errors.New("required when primary_dsn is not set"))
+			}
+		}
+	}
+
+	switch s.TLS.Mode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		errs.add("tls.mode", fmt.Errorf(
+			"unknown sslmode %q, expected disable, allow, prefer, require, verify-ca or verify-full", s.TLS.Mode))
+	}
+
+	if (s.TLS.Cert == "") != (s.TLS.Key == "") {
+		errs.add("tls", errors.New("cert and key must be set together"))
+	}
+
+	s.validatePool(errs)
+
+	for i, dsn := range s.ReplicaDSNs {
+		if _, err := pgxpool.ParseConfig(dsn); err != nil {
+			errs.add(fmt.Sprintf("replica_dsns[%d]", i), errors.New("replica DSN cannot be parsed"))
+		}
+	}
+
+	return errs.err()
+}
+
+func (s *PostgresSettings) validatePool(errs *fieldErrors) {
+	if s.Pool.MaxConns < 0 {
+		errs.add("pool.max_conns", errors.New("max_conns must not be negative"))
+	}
+
+	if s.Pool.MinConns < 0 {
+		errs.add("pool.min_conns", errors.New("min_conns must not be negative"))
+	}
+
+	if s.Pool.MaxConns > 0 && s.Pool.MinConns > s.Pool.MaxConns {
+		errs.add("pool.min_conns", fmt.Errorf("min_conns %d exceeds max_conns %d", s.Pool.MinConns, s.Pool.MaxConns))
+	}
+
+	durations := []struct {
+		path  string
+		value time.Duration
+	}{
+		{"pool.max_conn_lifetime", s.Pool.MaxConnLifetime},
+		{"pool.max_conn_idle_time", s.Pool.MaxConnIdleTime},
+		{"connect_timeout", s.ConnectTimeout},
+		{"statement_timeout", s.StatementTimeout},
+	}
+
+	for _, duration := range durations {
+		if duration.value < 0 {
+			errs.add(duration.path, errors.New("duration must not be negative"))
+		}
+	}
+}
+
+// Resolve replaces the password with the content of the password file, without the trailing newline.
+func (s *PostgresSettings) Resolve() error {
+	if s.PasswordFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.PasswordFile)
+	if err != nil {
+		return &config.ValidationError{Fields: []*config.FieldError{{Path: "password_file", Err: err}}}
+	}
+
+	s.Password = strings.TrimRight(string(data), "\r\n")
+
+	return nil
+}
+
+// Redacted returns a copy of the settings with the password and the passwords of the DSNs replaced.
+func (s *PostgresSettings) Redacted() any {
+	redacted := *s
+
+	if redacted.Password != "" {
+		redacted.Password = config.RedactedValue
+	}
+
+	redacted.PrimaryDSN = redactDSN(redacted.PrimaryDSN)
+
+	if len(redacted.ReplicaDSNs) > 0 {
+		redacted.ReplicaDSNs = make([]string, 0, len(s.ReplicaDSNs))
+		for _, dsn := range s.ReplicaDSNs {
+			redacted.ReplicaDSNs = append(redacted.ReplicaDSNs, redactDSN(dsn))
+		}
+	}
+
+	return &redacted
+}
+
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// redactDSN replaces the password of a postgres:// URL or a keyword/value connection string.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), config.RedactedValue)
+		}
+
+		if u.Query().Has("password") {
+			query := u.Query()
+			query.Set("password", config.RedactedValue)
+			u.RawQuery = query.Encode()
+		}
+
+		return u.String()
+	}
+
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+config.RedactedValue)
+}
+
+// ConnectionString returns the connection string of the primary. It only holds settings libpq
+// understands too, so it is also the MigrationDSN.
+func (s *PostgresSettings) ConnectionString() string {
+	if s.PrimaryDSN != "" {
+		return s.primaryDSN()
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", s.TLS.Mode)
+
+	params := []struct{ key, value string }{
+		{"sslrootcert", s.TLS.RootCert},
+		{"sslcert", s.TLS.Cert},
+		{"sslkey", s.TLS.Key},
+		{"application_name", s.ApplicationName},
+	}
+
+	for _, param := range params {
+		if param.value != "" {
+			query.Set(param.key, param.value)
+		}
+	}
+
+	if s.ConnectTimeout > 0 {
+		// libpq only takes whole seconds.
+		query.Set("connect_timeout", strconv.Itoa(int(math.Ceil(s.ConnectTimeout.Seconds()))))
+	}
+
+	u := url.URL{
+		Scheme:   "postgresql",
+		User:     url.UserPassword(s.User, s.Password),
+		Host:     net.JoinHostPort(s.Host, s.Port),
+		Path:     "/" + s.DatabaseName,
+		RawQuery: query.Encode(),
+	}
+
+	return u.String()
+}
+
+// MigrationDSN returns the connection string of the primary, which the changesets are applied to.
+func (s *PostgresSettings) MigrationDSN() string {
+	return s.ConnectionString()
+}
+
+// Location returns the host, port and database of the primary, which identify the storage whatever
+// the credentials, TLS or pool settings.
+func (s *PostgresSettings) Location() string {
+	poolConfig, err := pgxpool.ParseConfig(s.ConnectionString())
+	if err != nil {
+		return s.ConnectionString()
+	}
+
+	conn := poolConfig.ConnConfig
+
+	return net.JoinHostPort(conn.Host, strconv.Itoa(int(conn.Port))) + "/" + conn.Database
+}
+
+// primaryDSN returns the primary DSN with the password read from the password file, if any.
+func (s *PostgresSettings) primaryDSN() string {
+	u, err := url.Parse(s.PrimaryDSN)
+	if s.PasswordFile == "" || err != nil {
+		return s.PrimaryDSN
+	}
+
+	u.User = url.UserPassword(u.User.Username(), s.Password)
+
+	return u.String()
+}
+
+// PoolConfig parses dsn, the primary connection string or a replica DSN, and applies the pool
+// sizing, timeouts and application name.
+func (s *PostgresSettings) PoolConfig(dsn string) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Pool.MaxConns > 0 {
+		poolConfig.MaxConns = s.Pool.MaxConns
+	}
+
+	if s.Pool.MinConns > 0 {
+		poolConfig.MinConns = s.Pool.MinConns
+	}
+
+	if s.Pool.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = s.Pool.MaxConnLifetime
+	}
+
+	if s.Pool.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = s.Pool.MaxConnIdleTime
+	}
+
+	connConfig := poolConfig.ConnConfig
+
+	if s.ConnectTimeout > 0 {
+		connConfig.ConnectTimeout = s.ConnectTimeout
+	}
+
+	if s.StatementTimeout > 0 {
+		connConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(s.StatementTimeout.Milliseconds(), 10)
+	}
+
+	if _, ok := connConfig.RuntimeParams["application_name"]; !ok && s.ApplicationName != "" {
+		connConfig.RuntimeParams["application_name"] = s.ApplicationName
+	}
+
+	if s.PasswordFile != "" {
+		connConfig.Password = s.Password
+	}
+
+	return poolConfig, nil
+}
@@ -0,0 +1,195 @@
+package postgresdb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/domain/apperrors"
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+)
+
+var idempotencyColumns = []string{
+	"key", "request_hash", "status_code", "content_type", "headers", "body", "created_at", "expires_at",
+}
+
+func (r *PostgresRepository) GetIdempotencyRecord(ctx context.Context, key string) (*domain.IdempotencyRecord, error) {
+	query, args, err := squirrel.Select(idempotencyColumns...).
+		From("idempotency_keys").
+		Where(squirrel.Eq{"key": key}).
+		Where("expires_at > now()").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := scanIdempotencyRecord(r.querier(ctx).QueryRow(ctx, query, args...))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, &apperrors.ErrIdempotencyRecordNotFound{Message: "idempotency record not found"}
+		}
+
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func (r *PostgresRepository) ReserveIdempotencyKey(
+	ctx context.Context,
+	record *domain.IdempotencyRecord,
+) (*domain.IdempotencyRecord, error) {
+	// Expired records are replaced, unexpired ones are left untouched, so the statement
+	// returns no row when another request reserved the key first.
+	query, args, err := squirrel.Insert("idempotency_keys").
+		Columns(idempotencyColumns...).
+		Values(
+			record.Key, record.RequestHash, record.StatusCode, record.ContentType, nonNilHeaders(record.Headers),
+			nonNilBytes(record.Body), reservationTime(record), record.ExpiresAt,
+		).
+		Suffix(`ON CONFLICT (key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			status_code = EXCLUDED.status_code,
+			content_type = EXCLUDED.content_type,
+			headers = EXCLUDED.headers,
+			body = EXCLUDED.body,
+			created_at = EXCLUDED.created_at,
+			expires_at = EXCLUDED.expires_at
+			WHERE idempotency_keys.expires_at <= now()`).
+		Suffix("RETURNING key").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var key string
+
+		err = r.querier(ctx).QueryRow(ctx, query, args...).Scan(&key)
+		if err == nil {
+			return nil, nil
+		}
+
+		if err != pgx.ErrNoRows {
+			return nil, err
+		}
+
+		existing, err := r.GetIdempotencyRecord(ctx, record.Key)
+
+		var errNotFound *apperrors.ErrIdempotencyRecordNotFound
+		if !errors.As(err, &errNotFound) {
+			return existing, err
+		}
+
+		// The record expired after the insert saw it, try again.
+	}
+}
+
+func (r *PostgresRepository) CompleteIdempotencyRecord(ctx context.Context, record *domain.IdempotencyRecord) error {
+	query, args, err := squirrel.Update("idempotency_keys").
+		Set("status_code", record.StatusCode).
+		Set("content_type", record.ContentType).
+		Set("headers", nonNilHeaders(record.Headers)).
+		Set("body", nonNilBytes(record.Body)).
+		Set("expires_at", record.ExpiresAt).
+		Where(reservationCondition(record)).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	tag, err := r.querier(ctx).Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return &apperrors.ErrIdempotencyRecordNotFound{Message: "idempotency key is not reserved"}
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) ReleaseIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord) error {
+	query, args, err := squirrel.Delete("idempotency_keys").
+		Where(reservationCondition(record)).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.querier(ctx).Exec(ctx, query, args...)
+
+	return err
+}
+
+// reservationCondition matches the in-flight record reserved along with record.
+func reservationCondition(record *domain.IdempotencyRecord) squirrel.Eq {
+	return squirrel.Eq{"key": record.Key, "status_code": 0, "created_at": reservationTime(record)}
+}
+
+// reservationTime is the creation time of record as stored, timestamptz keeps microseconds.
+func reservationTime(record *domain.IdempotencyRecord) time.Time {
+	return record.CreatedAt.Truncate(time.Microsecond)
+}
+
+// nonNilHeaders keeps the NOT NULL headers column from receiving a nil map, which pgx sends as NULL.
+func nonNilHeaders(headers map[string][]string) map[string][]string {
+	if headers == nil {
+		return map[string][]string{}
+	}
+
+	return headers
+}
+
+// nonNilBytes keeps the NOT NULL body column from receiving a nil slice, which pgx sends as NULL.
+func nonNilBytes(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+
+	return b
+}
+
+func (r *PostgresRepository) DeleteExpiredIdempotencyRecords(ctx context.Context, now time.Time) (int64, error) {
+	query, args, err := squirrel.Delete("idempotency_keys").
+		Where(squirrel.LtOrEq{"expires_at": now}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := r.querier(ctx).Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func scanIdempotencyRecord(row pgx.Row) (*domain.IdempotencyRecord, error) {
+	var record domain.IdempotencyRecord
+
+	err := row.Scan(
+		&record.Key,
+		&record.RequestHash,
+		&record.StatusCode,
+		&record.ContentType,
+		&record.Headers,
+		&record.Body,
+		&record.CreatedAt,
+		&record.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
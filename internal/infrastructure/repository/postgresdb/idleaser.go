@@ -0,0 +1,101 @@
+package postgresdb
+
+import (
+	"context"
+
+	"github.com/AFK068/compressor/pkg/idalloc"
+	"github.com/AFK068/compressor/pkg/txs"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// IDLeaser implements idalloc.Leaser with the id_allocator and id_returned_blocks tables, so the
+// allocators of every process sharing the database lease disjoint blocks.
+type IDLeaser struct {
+	pool *pgxpool.Pool
+}
+
+func NewIDLeaser(pool *pgxpool.Pool) *IDLeaser {
+	return &IDLeaser{pool: pool}
+}
+
+// Lease runs in a transaction of its own, never in the one carried by ctx, so a lease is not rolled
+// back with the caller's transaction and ids are not handed out twice.
+func (l *IDLeaser) Lease(ctx context.Context, size, limit uint64) (block idalloc.Block, err error) {
+	err = pgx.BeginFunc(ctx, l.pool, func(tx pgx.Tx) (err error) {
+		block, err = l.lease(ctx, tx, size, limit)
+		return err
+	})
+
+	return block, err
+}
+
+func (l *IDLeaser) lease(ctx context.Context, querier txs.Querier, size, limit uint64) (idalloc.Block, error) {
+	var returned idalloc.Block
+
+	// SKIP LOCKED lets concurrent leases take different returned blocks instead of waiting.
+	err := querier.QueryRow(ctx, `SELECT start_id, end_id FROM id_returned_blocks
+		WHERE start_id < $1 ORDER BY start_id LIMIT 1 FOR UPDATE SKIP LOCKED`, limit,
+	).Scan(&returned.Start, &returned.End)
+	if err == nil {
+		return l.leaseReturned(ctx, querier, returned, size, limit)
+	}
+
+	if err != pgx.ErrNoRows {
+		return idalloc.Block{}, err
+	}
+
+	var next uint64
+
+	err = querier.QueryRow(ctx, `SELECT next_id FROM id_allocator FOR UPDATE`).Scan(&next)
+	if err != nil {
+		return idalloc.Block{}, err
+	}
+
+	if next >= limit {
+		return idalloc.Block{}, idalloc.ErrExhausted
+	}
+
+	block := idalloc.Block{Start: next, End: next + min(size, limit-next)}
+
+	if _, err := querier.Exec(ctx, `UPDATE id_allocator SET next_id = $1`, block.End); err != nil {
+		return idalloc.Block{}, err
+	}
+
+	return block, nil
+}
+
+// leaseReturned leases the beginning of a returned block, giving the rest of it back.
+func (l *IDLeaser) leaseReturned(ctx context.Context, querier txs.Querier, returned idalloc.Block, size, limit uint64) (idalloc.Block, error) {
+	block := idalloc.Block{Start: returned.Start, End: returned.Start + min(size, returned.Len(), limit-returned.Start)}
+
+	_, err := querier.Exec(ctx, `DELETE FROM id_returned_blocks WHERE start_id = $1`, returned.Start)
+	if err != nil {
+		return idalloc.Block{}, err
+	}
+
+	if block.End < returned.End {
+		_, err = querier.Exec(ctx, `INSERT INTO id_returned_blocks (start_id, end_id) VALUES ($1, $2)`, block.End, returned.End)
+		if err != nil {
+			return idalloc.Block{}, err
+		}
+	}
+
+	return block, nil
+}
+
+func (l *IDLeaser) Return(ctx context.Context, blocks []idalloc.Block) error {
+	batch := &pgx.Batch{}
+
+	for _, block := range blocks {
+		batch.Queue(`INSERT INTO id_returned_blocks (start_id, end_id) VALUES ($1, $2)`, block.Start, block.End)
+	}
+
+	return l.pool.SendBatch(ctx, batch).Close()
+}
+
+// Advance joins the transaction carried by ctx, so it is rolled back with it.
+func (l *IDLeaser) Advance(ctx context.Context, next uint64) error {
+	_, err := txs.GetQuerier(ctx, l.pool).Exec(ctx, `UPDATE id_allocator SET next_id = GREATEST(next_id, $1)`, next)
+	return err
+}
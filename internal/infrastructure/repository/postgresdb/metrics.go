@@ -0,0 +1,111 @@
+package postgresdb
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	PrimaryPool = "primary"
+	// ReplicaPoolPrefix is followed by the index of the replica in storage.postgres.replica_dsns, e.g. "replica-0".
+	ReplicaPoolPrefix = "replica-"
+
+	ReplicaReadHit   = "hit"
+	ReplicaReadMiss  = "miss"
+	ReplicaReadError = "error"
+)
+
+type replica struct {
+	pool *pgxpool.Pool
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	errors atomic.Uint64
+}
+
+var (
+	poolLabels = []string{"pool"}
+
+	acquiredConnsDesc = prometheus.NewDesc(
+		"compressor_postgres_pool_acquired_connections",
+		"Number of connections currently in use.", poolLabels, nil,
+	)
+	idleConnsDesc = prometheus.NewDesc(
+		"compressor_postgres_pool_idle_connections",
+		"Number of idle connections.", poolLabels, nil,
+	)
+	totalConnsDesc = prometheus.NewDesc(
+		"compressor_postgres_pool_total_connections",
+		"Number of open connections, including the ones being established.", poolLabels, nil,
+	)
+	maxConnsDesc = prometheus.NewDesc(
+		"compressor_postgres_pool_max_connections",
+		"Maximum size of the pool.", poolLabels, nil,
+	)
+	acquiresDesc = prometheus.NewDesc(
+		"compressor_postgres_pool_acquires_total",
+		"Number of connections acquired from the pool.", poolLabels, nil,
+	)
+	emptyAcquiresDesc = prometheus.NewDesc(
+		"compressor_postgres_pool_empty_acquires_total",
+		"Number of acquires that waited because the pool had no idle connection.", poolLabels, nil,
+	)
+	canceledAcquiresDesc = prometheus.NewDesc(
+		"compressor_postgres_pool_canceled_acquires_total",
+		"Number of acquires canceled by their context.", poolLabels, nil,
+	)
+	acquireSecondsDesc = prometheus.NewDesc(
+		"compressor_postgres_pool_acquire_seconds_total",
+		"Total time spent acquiring connections.", poolLabels, nil,
+	)
+	replicaReadsDesc = prometheus.NewDesc(
+		"compressor_postgres_replica_reads_total",
+		"Links resolved from a replica by result. Misses and errors are resolved again from the primary.",
+		[]string{"pool", "result"}, nil,
+	)
+)
+
+// Describe implements prometheus.Collector.
+func (r *PostgresRepository) Describe(ch chan<- *prometheus.Desc) {
+	ch <- acquiredConnsDesc
+	ch <- idleConnsDesc
+	ch <- totalConnsDesc
+	ch <- maxConnsDesc
+	ch <- acquiresDesc
+	ch <- emptyAcquiresDesc
+	ch <- canceledAcquiresDesc
+	ch <- acquireSecondsDesc
+	ch <- replicaReadsDesc
+}
+
+// Collect implements prometheus.Collector, reporting the statistics of every pool and the reads
+// served by each replica.
+func (r *PostgresRepository) Collect(ch chan<- prometheus.Metric) {
+	collectPoolStats(ch, PrimaryPool, r.pool)
+
+	for i, replica := range r.replicas {
+		name := ReplicaPoolPrefix + strconv.Itoa(i)
+
+		collectPoolStats(ch, name, replica.pool)
+
+		ch <- prometheus.MustNewConstMetric(replicaReadsDesc, prometheus.CounterValue, float64(replica.hits.Load()), name, ReplicaReadHit)
+		ch <- prometheus.MustNewConstMetric(replicaReadsDesc, prometheus.CounterValue, float64(replica.misses.Load()), name, ReplicaReadMiss)
+		ch <- prometheus.MustNewConstMetric(replicaReadsDesc, prometheus.CounterValue, float64(replica.errors.Load()), name, ReplicaReadError)
+	}
+}
+
+func collectPoolStats(ch chan<- prometheus.Metric, name string, pool *pgxpool.Pool) {
+	stat := pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(acquiredConnsDesc, prometheus.GaugeValue, float64(stat.AcquiredConns()), name)
+	ch <- prometheus.MustNewConstMetric(idleConnsDesc, prometheus.GaugeValue, float64(stat.IdleConns()), name)
+	ch <- prometheus.MustNewConstMetric(totalConnsDesc, prometheus.GaugeValue, float64(stat.TotalConns()), name)
+	ch <- prometheus.MustNewConstMetric(maxConnsDesc, prometheus.GaugeValue, float64(stat.MaxConns()), name)
+	ch <- prometheus.MustNewConstMetric(acquiresDesc, prometheus.CounterValue, float64(stat.AcquireCount()), name)
+	ch <- prometheus.MustNewConstMetric(emptyAcquiresDesc, prometheus.CounterValue, float64(stat.EmptyAcquireCount()), name)
+	ch <- prometheus.MustNewConstMetric(canceledAcquiresDesc, prometheus.CounterValue, float64(stat.CanceledAcquireCount()), name)
+	ch <- prometheus.MustNewConstMetric(acquireSecondsDesc, prometheus.CounterValue, stat.AcquireDuration().Seconds(), name)
+}
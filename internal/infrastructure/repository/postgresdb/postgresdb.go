@@ -3,162 +3,606 @@ package postgresdb
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/AFK068/compressor/internal/domain"
 	"github.com/AFK068/compressor/internal/domain/apperrors"
+	"github.com/AFK068/compressor/pkg/idalloc"
+	"github.com/AFK068/compressor/pkg/txs"
 	"github.com/Masterminds/squirrel"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type PostgresRepository struct {
-	pool      *pgxpool.Pool
-	shortener domain.Shortener
-	maxSize   uint64
+	pool       *pgxpool.Pool
+	transactor *txs.TxBeginner
+	isoLevel   pgx.TxIsoLevel
+	ids        *idalloc.Allocator
+	replicas   []*replica
+	shortener  domain.Shortener
+	maxSize    uint64
+
+	nextReplica atomic.Uint64
 }
 
-func New(pool *pgxpool.Pool, shortener domain.Shortener, maxSize uint64) *PostgresRepository {
-	return &PostgresRepository{
+type Option func(r *PostgresRepository)
+
+// WithReplicas makes GetURL and ResolveLink read links from the replicas in turn, falling back to the
+// primary pool for links a replica does not have yet and when a replica fails.
+func WithReplicas(pools ...*pgxpool.Pool) Option {
+	return func(r *PostgresRepository) {
+		for _, pool := range pools {
+			r.replicas = append(r.replicas, &replica{pool: pool})
+		}
+	}
+}
+
+// WithIDAllocator sets the allocator of the ids of new links, which leases blocks of
+// idalloc.DefaultBlockSize ids with an IDLeaser by default.
+func WithIDAllocator(ids *idalloc.Allocator) Option {
+	return func(r *PostgresRepository) {
+		r.ids = ids
+	}
+}
+
+// WithIsolationLevel sets the isolation level of the transactions, read committed by default.
+// Serialization failures, which WithTransaction retries, only occur with repeatable read and serializable.
+func WithIsolationLevel(isoLevel pgx.TxIsoLevel) Option {
+	return func(r *PostgresRepository) {
+		r.isoLevel = isoLevel
+	}
+}
+
+func New(pool *pgxpool.Pool, shortener domain.Shortener, maxSize uint64, opts ...Option) *PostgresRepository {
+	r := &PostgresRepository{
 		pool:      pool,
+		isoLevel:  pgx.ReadCommitted,
+		ids:       idalloc.New(NewIDLeaser(pool), maxSize),
 		shortener: shortener,
 		maxSize:   maxSize,
 	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	r.transactor = txs.NewTxBeginner(pool, txs.WithTxOptions(pgx.TxOptions{IsoLevel: r.isoLevel}))
+
+	return r
+}
+
+func (r *PostgresRepository) SaveURL(ctx context.Context, originalURL string) (string, error) {
+	link, err := r.CreateLink(ctx, &domain.Link{Destination: originalURL})
+	if err != nil {
+		return "", err
+	}
+
+	return link.Code, nil
 }
 
-func (r *PostgresRepository) SaveURL(ctx context.Context, originalURL string) (shortenedURL string, err error) {
-	tx, err := r.pool.Begin(ctx)
+func (r *PostgresRepository) GetURL(ctx context.Context, shortenedURL string) (string, error) {
+	link, err := r.ResolveLink(ctx, shortenedURL)
 	if err != nil {
 		return "", err
 	}
 
-	defer func() {
+	if link.Destination == "" || !link.IsResolvable(time.Now()) {
+		return "", &apperrors.ErrURLNotFound{Message: "url not found"}
+	}
+
+	return link.Destination, nil
+}
+
+// WithTransaction implements domain.Transactor. Deadlocks, and the serialization failures of the
+// stricter isolation levels set with WithIsolationLevel, are retried. The ids of the links created
+// by a rolled back attempt are handed out again.
+func (r *PostgresRepository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if txs.InTransaction(ctx) {
+		return fn(ctx)
+	}
+
+	return r.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		var created []uint64
+
+		err := fn(context.WithValue(ctx, createdIDsKey{}, &created))
 		if err != nil {
-			err = errors.Join(err, tx.Rollback(ctx))
+			for _, id := range created {
+				r.ids.Release(id)
+			}
+		}
+
+		return err
+	})
+}
+
+type createdIDsKey struct{}
+
+// trackCreatedID records the id of a link created in a transaction started by WithTransaction.
+func trackCreatedID(ctx context.Context, id uint64) {
+	if created, ok := ctx.Value(createdIDsKey{}).(*[]uint64); ok {
+		*created = append(*created, id)
+	}
+}
+
+// Close returns the ids leased by the repository but not used to the database, so other processes
+// use them.
+func (r *PostgresRepository) Close(ctx context.Context) error {
+	return r.ids.Close(ctx)
+}
+
+// CreateLink looks the destination up and inserts it in a single statement, so a new link costs
+// one round trip besides the occasional lease of ids. Inside a transaction started by
+// WithTransaction the statement joins it.
+func (r *PostgresRepository) CreateLink(ctx context.Context, link *domain.Link) (*domain.Link, error) {
+	for {
+		id, err := r.ids.Next(ctx)
+		if errors.Is(err, idalloc.ErrExhausted) {
+			return r.getExistingOrFull(ctx, link.Destination)
 		}
-	}()
 
-	existingShortURL, err := r.getExistingShortURL(ctx, tx, originalURL)
-	if err == nil {
-		err = tx.Commit(ctx)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
-		return existingShortURL, nil
+		created, err := r.createLink(ctx, id, link)
+
+		switch {
+		case err == errIDTaken:
+			continue
+		case err != nil:
+			r.ids.Release(id)
+			return nil, err
+		case created.ID != id:
+			// The destination is already shortened.
+			r.ids.Release(id)
+		default:
+			trackCreatedID(ctx, id)
+		}
+
+		created.Code, err = r.shortener.Encode(created.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return created, nil
 	}
+}
+
+// errIDTaken is returned by createLink when an imported link already has the id.
+var errIDTaken = errors.New("id is taken")
 
+func (r *PostgresRepository) createLink(ctx context.Context, id uint64, link *domain.Link) (*domain.Link, error) {
+	created, err := scanLink(r.querier(ctx).QueryRow(ctx, createLinkQuery, id, link.Destination, link.ExpiresAt, nullString(link.Owner)))
 	if err != pgx.ErrNoRows {
-		return "", err
+		return created, err
+	}
+
+	// Either a concurrent request inserted the destination after the statement took its snapshot,
+	// the destination is shortened as a disabled or expired link, or the id was taken.
+	created, err = r.getExistingLink(ctx, link.Destination, squirrel.Expr(resolvableCondition))
+	if err != pgx.ErrNoRows {
+		return created, err
 	}
 
-	id, err := r.insertURL(ctx, tx, originalURL)
+	retired, err := r.retireLink(ctx, link.Destination)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	if id >= r.maxSize {
-		return "", &apperrors.ErrRepositoryIsFull{Message: "repository is full"}
+	if retired {
+		return r.createLink(ctx, id, link)
 	}
 
-	shortenedURL, err = r.shortener.Encode(id)
+	// A concurrent request may have retired the link instead, which costs an id at worst.
+	return nil, errIDTaken
+}
+
+// retireLink marks the disabled or expired link the destination is shortened as a duplicate of
+// itself. It keeps resolving by its code, but frees the destination for a new link.
+func (r *PostgresRepository) retireLink(ctx context.Context, originalURL string) (bool, error) {
+	query, args, err := squirrel.Update("urls").
+		Set("duplicate_of", squirrel.Expr("id")).
+		Where("url_hash = urls_url_hash(?)", originalURL).
+		Where(squirrel.Eq{"url": originalURL, "duplicate_of": nil}).
+		Where("NOT (" + resolvableCondition + ")").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
 	if err != nil {
-		return "", err
+		return false, err
 	}
 
-	err = r.updateShortURL(ctx, tx, id, shortenedURL)
+	tag, err := r.querier(ctx).Exec(ctx, query, args...)
 	if err != nil {
-		return "", err
+		return false, err
 	}
 
-	err = tx.Commit(ctx)
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *PostgresRepository) getExistingOrFull(ctx context.Context, originalURL string) (*domain.Link, error) {
+	existing, err := r.getExistingLink(ctx, originalURL, squirrel.Expr(resolvableCondition))
+	if err == pgx.ErrNoRows {
+		return nil, &apperrors.ErrRepositoryIsFull{Message: "repository is full"}
+	}
+
+	return existing, err
+}
+
+func (r *PostgresRepository) GetLink(ctx context.Context, code string) (*domain.Link, error) {
+	id, err := r.decode(code)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return shortenedURL, nil
+	return r.getLink(ctx, r.querier(ctx), id, code)
 }
 
-func (r *PostgresRepository) GetURL(ctx context.Context, shortenedURL string) (string, error) {
-	id, err := r.shortener.Decode(shortenedURL)
+// ResolveLink reads the link from the next replica, or from the primary when the replica misses it.
+// Inside a transaction the link is read from the transaction, which sees its own writes.
+func (r *PostgresRepository) ResolveLink(ctx context.Context, code string) (*domain.Link, error) {
+	id, err := r.decode(code)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if len(r.replicas) == 0 || txs.InTransaction(ctx) {
+		return r.getLink(ctx, r.querier(ctx), id, code)
+	}
+
+	replica := r.replicas[r.nextReplica.Add(1)%uint64(len(r.replicas))]
+
+	link, err := r.getLink(ctx, replica.pool, id, code)
+	if err == nil {
+		replica.hits.Add(1)
+		return link, nil
+	}
+
+	var errURLNotFound *apperrors.ErrURLNotFound
+	if errors.As(err, &errURLNotFound) {
+		replica.misses.Add(1)
+	} else {
+		replica.errors.Add(1)
+	}
+
+	if ctx.Err() != nil {
+		return nil, err
+	}
+
+	return r.getLink(ctx, r.querier(ctx), id, code)
+}
+
+func (r *PostgresRepository) decode(code string) (uint64, error) {
+	id, err := r.shortener.Decode(code)
+	if err != nil {
+		return 0, err
 	}
 
 	if id >= r.maxSize {
-		return "", &apperrors.ErrURLNotFound{Message: "url not found"}
+		return 0, &apperrors.ErrURLNotFound{Message: "url not found"}
 	}
 
-	query, args, err := squirrel.Select("url").
+	return id, nil
+}
+
+func (r *PostgresRepository) getLink(ctx context.Context, querier txs.Querier, id uint64, code string) (*domain.Link, error) {
+	query, args, err := squirrel.Select(linkColumns...).
 		From("urls").
 		Where(squirrel.Eq{"id": id}).
 		PlaceholderFormat(squirrel.Dollar).
 		ToSql()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	var originalURL string
-
-	err = r.pool.QueryRow(ctx, query, args...).Scan(&originalURL)
+	link, err := scanLink(querier.QueryRow(ctx, query, args...))
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return "", &apperrors.ErrURLNotFound{Message: "url not found"}
+			return nil, &apperrors.ErrURLNotFound{Message: "url not found"}
 		}
 
-		return "", err
+		return nil, err
 	}
 
-	if originalURL == "" {
-		return "", &apperrors.ErrURLNotFound{Message: "url not found"}
-	}
+	link.Code = code
 
-	return originalURL, nil
+	return link, nil
 }
 
-func (r *PostgresRepository) getExistingShortURL(ctx context.Context, tx pgx.Tx, originalURL string) (string, error) {
-	query, args, err := squirrel.Select("short_url").
-		From("urls").
-		Where(squirrel.Eq{"url": originalURL}).
+func (r *PostgresRepository) DeleteLink(ctx context.Context, code string) error {
+	id, err := r.shortener.Decode(code)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := squirrel.Delete("urls").
+		Where(squirrel.Eq{"id": id}).
 		PlaceholderFormat(squirrel.Dollar).
 		ToSql()
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	var shortURL string
-	err = tx.QueryRow(ctx, query, args...).Scan(&shortURL)
+	tag, err := r.querier(ctx).Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return &apperrors.ErrURLNotFound{Message: "url not found"}
+	}
 
-	return shortURL, err
+	return nil
 }
 
-func (r *PostgresRepository) insertURL(ctx context.Context, tx pgx.Tx, originalURL string) (uint64, error) {
-	query, args, err := squirrel.Insert("urls").
-		Columns("url").
-		Values(originalURL).
-		Suffix("RETURNING id").
+func (r *PostgresRepository) ImportLink(ctx context.Context, link *domain.Link) (*domain.Link, error) {
+	id, err := r.shortener.Decode(link.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	if id >= r.maxSize {
+		return nil, &apperrors.ErrRepositoryIsFull{Message: "code is beyond the repository capacity"}
+	}
+
+	var imported *domain.Link
+
+	err = r.transactor.WithTransaction(ctx, func(ctx context.Context) (err error) {
+		imported, err = r.importLink(ctx, id, link)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	imported.Code = link.Code
+
+	return imported, nil
+}
+
+func (r *PostgresRepository) importLink(ctx context.Context, id uint64, link *domain.Link) (*domain.Link, error) {
+	// Concurrent inserts wait for the import to commit, so none of them can shorten the destination
+	// between checking and inserting it.
+	if _, err := r.querier(ctx).Exec(ctx, "LOCK TABLE urls IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return nil, err
+	}
+
+	_, err := r.getExistingLink(ctx, link.Destination)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+
+	// Like a new link, the imported one replaces a disabled or expired link of the destination.
+	// Otherwise it is imported retired, so it resolves by its code only.
+	retired := err == nil
+	if retired && link.IsResolvable(time.Now()) {
+		replaced, err := r.retireLink(ctx, link.Destination)
+		if err != nil {
+			return nil, err
+		}
+
+		retired = !replaced
+	}
+
+	if err = r.ids.Advance(ctx, id+1); err != nil {
+		return nil, err
+	}
+
+	imported, err := r.insertImportedLink(ctx, id, link, retired)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, &apperrors.ErrLinkConflict{Message: fmt.Sprintf("code %q already exists", link.Code)}
+		}
+
+		return nil, err
+	}
+
+	imported.Code = link.Code
+
+	return imported, nil
+}
+
+func (r *PostgresRepository) ListLinks(ctx context.Context, params domain.ListLinksParams) ([]*domain.Link, error) {
+	query, args, err := squirrel.Select(linkColumns...).
+		From("urls").
+		Where(squirrel.GtOrEq{"id": params.FromID}).
+		OrderBy("id").
+		Limit(uint64(params.Limit)). //nolint
 		PlaceholderFormat(squirrel.Dollar).
 		ToSql()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	var id uint64
-	err = tx.QueryRow(ctx, query, args...).Scan(&id)
+	rows, err := r.querier(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	return id, err
+	links := make([]*domain.Link, 0, params.Limit)
+
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		link.Code, err = r.shortener.Encode(link.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
 }
 
-func (r *PostgresRepository) updateShortURL(ctx context.Context, tx pgx.Tx, id uint64, shortURL string) error {
+func (r *PostgresRepository) DisableLink(ctx context.Context, code string) error {
+	id, err := r.shortener.Decode(code)
+	if err != nil {
+		return err
+	}
+
+	// Links merged into this one are disabled with it, like they are deleted with it.
 	query, args, err := squirrel.Update("urls").
-		Set("short_url", shortURL).
-		Where(squirrel.Eq{"id": id}).
+		Set("disabled", true).
+		Where(squirrel.Or{squirrel.Eq{"id": id}, squirrel.Eq{"duplicate_of": id}}).
 		PlaceholderFormat(squirrel.Dollar).
 		ToSql()
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec(ctx, query, args...)
+	tag, err := r.querier(ctx).Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return &apperrors.ErrURLNotFound{Message: "url not found"}
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) Stats(ctx context.Context) (*domain.Stats, error) {
+	query, args, err := squirrel.Select(
+		"count(*)",
+		"count(*) FILTER (WHERE NOT disabled AND (expires_at IS NULL OR expires_at > now()))",
+		"count(*) FILTER (WHERE disabled)",
+		"count(*) FILTER (WHERE NOT disabled AND expires_at <= now())",
+	).
+		From("urls").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &domain.Stats{Capacity: r.maxSize}
+
+	err = r.querier(ctx).QueryRow(ctx, query, args...).Scan(&stats.Total, &stats.Active, &stats.Disabled, &stats.Expired)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// getExistingLink returns the link the destination was shortened as, if it matches preds. Links
+// merged into it by the url_dedup changeset and retired links are skipped.
+func (r *PostgresRepository) getExistingLink(
+	ctx context.Context, originalURL string, preds ...squirrel.Sqlizer,
+) (*domain.Link, error) {
+	builder := squirrel.Select(linkColumns...).
+		From("urls").
+		Where("url_hash = urls_url_hash(?)", originalURL).
+		Where(squirrel.Eq{"url": originalURL, "duplicate_of": nil})
+
+	for _, pred := range preds {
+		builder = builder.Where(pred)
+	}
+
+	query, args, err := builder.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := scanLink(r.querier(ctx).QueryRow(ctx, query, args...))
+	if err != nil {
+		return nil, err
+	}
+
+	link.Code, err = r.shortener.Encode(link.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+// insertImportedLink returns pgx.ErrNoRows when the id or the code is already taken. A retired link
+// is a duplicate of itself, like the links retired by retireLink.
+func (r *PostgresRepository) insertImportedLink(
+	ctx context.Context, id uint64, link *domain.Link, retired bool,
+) (*domain.Link, error) {
+	createdAt := link.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+
+	var duplicateOf *uint64
+	if retired {
+		duplicateOf = &id
+	}
+
+	query, args, err := squirrel.Insert("urls").
+		Columns("id", "url", "short_url", "created_at", "expires_at", "owner", "disabled", "duplicate_of").
+		Values(id, link.Destination, link.Code, createdAt, link.ExpiresAt, nullString(link.Owner), link.Disabled, duplicateOf).
+		Suffix("ON CONFLICT DO NOTHING").
+		Suffix("RETURNING " + strings.Join(linkColumns, ", ")).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	return scanLink(r.querier(ctx).QueryRow(ctx, query, args...))
+}
+
+// querier returns the transaction carried by ctx, or the primary pool outside of transactions.
+func (r *PostgresRepository) querier(ctx context.Context) txs.Querier {
+	return txs.GetQuerier(ctx, r.pool)
+}
+
+// linkColumns are scanned by scanLink. The code is not read, it is always the id encoded by the
+// shortener, so new links do not store short_url.
+var linkColumns = []string{"id", "url", "created_at", "expires_at", "COALESCE(owner, '') AS owner", "disabled"}
+
+// resolvableCondition matches the links that are neither disabled nor expired, the only ones new
+// links are deduplicated into.
+const resolvableCondition = "NOT disabled AND (expires_at IS NULL OR expires_at > now())"
+
+// createLinkQuery returns the link the destination is already shortened as, or inserts it, in one
+// round trip. It returns no row when a concurrent transaction inserted the destination first, the
+// destination is shortened as a disabled or expired link, or the id is taken.
+var createLinkQuery = `
+WITH existing AS (
+	SELECT ` + strings.Join(linkColumns, ", ") + `
+	FROM urls
+	WHERE url_hash = urls_url_hash($2::text) AND url = $2::text AND duplicate_of IS NULL
+		AND ` + resolvableCondition + `
+), inserted AS (
+	INSERT INTO urls (id, url, expires_at, owner)
+	SELECT $1::bigint, $2::text, $3::timestamptz, $4::text
+	WHERE NOT EXISTS (SELECT 1 FROM existing)
+	ON CONFLICT DO NOTHING
+	RETURNING ` + strings.Join(linkColumns, ", ") + `
+)
+SELECT * FROM existing
+UNION ALL
+SELECT * FROM inserted`
+
+func scanLink(row pgx.Row) (*domain.Link, error) {
+	var link domain.Link
+
+	err := row.Scan(&link.ID, &link.Destination, &link.CreatedAt, &link.ExpiresAt, &link.Owner, &link.Disabled)
+	if err != nil {
+		return nil, err
+	}
+
+	return &link, nil
+}
+
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
 
-	return err
+	return &s
 }
@@ -0,0 +1,141 @@
+package postgresdb_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/AFK068/compressor/internal/domain/apperrors"
+	"github.com/AFK068/compressor/internal/infrastructure/repository/postgresdb"
+	"github.com/AFK068/compressor/pkg/shortener"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	benchmarkMaxSize = 1 << 40
+)
+
+type saveURLFunc func(ctx context.Context, originalURL string) (string, error)
+
+// Run with make bench_postgres. The sub-benchmarks are named path=<write path>/op=<operation>, so
+// benchstat -col /path compares the write paths side by side.
+func BenchmarkSaveURL(b *testing.B) {
+	dbPool, ctx := setupDB(b)
+
+	s, err := shortener.NewShortener("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_", 10)
+	assert.NoError(b, err)
+
+	implementations := []struct {
+		name    string
+		saveURL saveURLFunc
+	}{
+		{"path=legacy", func(ctx context.Context, originalURL string) (string, error) {
+			return legacySaveURL(ctx, dbPool, s, originalURL)
+		}},
+		{"path=single_round_trip", postgresdb.New(dbPool, s, benchmarkMaxSize).SaveURL},
+	}
+
+	for _, implementation := range implementations {
+		b.Run(implementation.name+"/op=new", func(b *testing.B) {
+			truncateURLs(b, ctx, dbPool)
+
+			for i := range b.N {
+				if _, err := implementation.saveURL(ctx, fmt.Sprintf("http://example.com/%d", i)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(implementation.name+"/op=existing", func(b *testing.B) {
+			truncateURLs(b, ctx, dbPool)
+
+			if _, err := implementation.saveURL(ctx, "http://example.com"); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+
+			for range b.N {
+				if _, err := implementation.saveURL(ctx, "http://example.com"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(implementation.name+"/op=new_parallel", func(b *testing.B) {
+			truncateURLs(b, ctx, dbPool)
+
+			var next atomic.Uint64
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := implementation.saveURL(ctx, fmt.Sprintf("http://example.com/%d", next.Add(1))); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func truncateURLs(b *testing.B, ctx context.Context, dbPool *pgxpool.Pool) {
+	b.Helper()
+
+	if _, err := dbPool.Exec(ctx, "TRUNCATE urls RESTART IDENTITY"); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+}
+
+// legacySaveURL is the write path SaveURL replaced: a transaction with a lookup, an insert and an
+// update of short_url, four round trips for a new link.
+func legacySaveURL(ctx context.Context, dbPool *pgxpool.Pool, s *shortener.Shortener, originalURL string) (code string, err error) {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, tx.Rollback(ctx))
+		}
+	}()
+
+	err = tx.QueryRow(ctx, `SELECT short_url FROM urls
+		WHERE url_hash = urls_url_hash($1) AND url = $1 AND duplicate_of IS NULL`, originalURL).Scan(&code)
+	if err == nil {
+		return code, tx.Commit(ctx)
+	}
+
+	if err != pgx.ErrNoRows {
+		return "", err
+	}
+
+	var id uint64
+
+	err = tx.QueryRow(ctx, `INSERT INTO urls (url) VALUES ($1)
+		ON CONFLICT (url_hash) WHERE duplicate_of IS NULL DO NOTHING RETURNING id`, originalURL).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+
+	if id >= benchmarkMaxSize {
+		return "", &apperrors.ErrRepositoryIsFull{Message: "repository is full"}
+	}
+
+	if code, err = s.Encode(id); err != nil {
+		return "", err
+	}
+
+	if _, err = tx.Exec(ctx, `UPDATE urls SET short_url = $1 WHERE id = $2`, code, id); err != nil {
+		return "", err
+	}
+
+	return code, tx.Commit(ctx)
+}
@@ -2,23 +2,44 @@ package postgresdb_test
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/domain"
 	"github.com/AFK068/compressor/internal/domain/apperrors"
 	"github.com/AFK068/compressor/internal/infrastructure/repository/postgresdb"
+	"github.com/AFK068/compressor/internal/migration"
 	"github.com/AFK068/compressor/internal/testcontainer"
+	"github.com/AFK068/compressor/pkg/shortener"
+	"github.com/AFK068/compressor/pkg/txs"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
 
 	shortenermock "github.com/AFK068/compressor/internal/domain/mocks"
+
+	// Registers the storage types.
+	_ "github.com/AFK068/compressor/internal/infrastructure/repository"
 )
 
 const (
 	TestConfigPath = "../../../../config/test.yaml"
 )
 
-func setupDB(t *testing.T) (*pgxpool.Pool, context.Context) {
+func setupDB(t testing.TB) (*pgxpool.Pool, context.Context) {
+	dbPool, _, ctx := setupContainer(t)
+
+	return dbPool, ctx
+}
+
+func setupContainer(t testing.TB) (*pgxpool.Pool, *testcontainer.PostgresTestcontainer, context.Context) {
 	ctx := context.Background()
 
 	config, err := config.NewConfig(TestConfigPath)
@@ -34,7 +55,7 @@ func setupDB(t *testing.T) (*pgxpool.Pool, context.Context) {
 		assert.NoError(t, cleanup())
 	})
 
-	return dbPool, ctx
+	return dbPool, testContainer, ctx
 }
 
 func Test_SaveURL_Success(t *testing.T) {
@@ -63,7 +84,8 @@ func Test_URLIsAlreadyExists_Success(t *testing.T) {
 	dbPool, ctx := setupDB(t)
 
 	shortenerMock := shortenermock.NewShortener(t)
-	shortenerMock.On("Encode", uint64(0)).Return("shortURL", nil).Once()
+	// The code of an existing link is encoded from its id again.
+	shortenerMock.On("Encode", uint64(0)).Return("shortURL", nil).Twice()
 
 	repo := postgresdb.New(dbPool, shortenerMock, 10)
 	short, err := repo.SaveURL(ctx, "originURL")
@@ -136,3 +158,609 @@ func Test_GetURl_LinkNotFound_Failure(t *testing.T) {
 
 	shortenerMock.AssertExpectations(t)
 }
+
+func Test_CreateLink_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+
+	shortenerMock := shortenermock.NewShortener(t)
+	shortenerMock.On("Encode", uint64(0)).Return("shortURL", nil).Once()
+	shortenerMock.On("Decode", "shortURL").Return(uint64(0), nil).Once()
+
+	repo := postgresdb.New(dbPool, shortenerMock, 10)
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+
+	created, err := repo.CreateLink(ctx, &domain.Link{
+		Destination: "originURL",
+		ExpiresAt:   &expiresAt,
+		Owner:       "team-a",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "shortURL", created.Code)
+	assert.False(t, created.CreatedAt.IsZero())
+
+	link, err := repo.GetLink(ctx, "shortURL")
+	assert.NoError(t, err)
+	assert.Equal(t, "originURL", link.Destination)
+	assert.Equal(t, "team-a", link.Owner)
+	assert.True(t, expiresAt.Equal(*link.ExpiresAt))
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_CreateLink_DisabledExisting_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+
+	shortenerMock := shortenermock.NewShortener(t)
+	shortenerMock.On("Encode", uint64(0)).Return("shortURL", nil).Once()
+	shortenerMock.On("Encode", uint64(1)).Return("shortURL2", nil).Twice()
+	shortenerMock.On("Decode", "shortURL").Return(uint64(0), nil).Twice()
+
+	repo := postgresdb.New(dbPool, shortenerMock, 10)
+
+	_, err := repo.SaveURL(ctx, "originURL")
+	assert.NoError(t, err)
+
+	err = repo.DisableLink(ctx, "shortURL")
+	assert.NoError(t, err)
+
+	created, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL"})
+	assert.NoError(t, err)
+	assert.Equal(t, "shortURL2", created.Code)
+	assert.False(t, created.Disabled)
+
+	again, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL"})
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, again.ID)
+
+	link, err := repo.GetLink(ctx, "shortURL")
+	assert.NoError(t, err)
+	assert.True(t, link.Disabled)
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_CreateLink_ExpiredExisting_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+
+	shortenerMock := shortenermock.NewShortener(t)
+	shortenerMock.On("Encode", uint64(0)).Return("shortURL", nil).Once()
+	shortenerMock.On("Encode", uint64(1)).Return("shortURL2", nil).Twice()
+	shortenerMock.On("Decode", "shortURL").Return(uint64(0), nil).Once()
+
+	repo := postgresdb.New(dbPool, shortenerMock, 10)
+
+	expiresAt := time.Now().Add(-time.Minute)
+
+	_, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL", ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+
+	created, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL"})
+	assert.NoError(t, err)
+	assert.Equal(t, "shortURL2", created.Code)
+
+	// Deleting the replaced link keeps the destination shortened as the new one.
+	err = repo.DeleteLink(ctx, "shortURL")
+	assert.NoError(t, err)
+
+	again, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL"})
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, again.ID)
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_DeleteLink_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+
+	shortenerMock := shortenermock.NewShortener(t)
+	shortenerMock.On("Encode", uint64(0)).Return("shortURL", nil).Once()
+	shortenerMock.On("Decode", "shortURL").Return(uint64(0), nil).Times(3)
+
+	repo := postgresdb.New(dbPool, shortenerMock, 10)
+
+	_, err := repo.SaveURL(ctx, "originURL")
+	assert.NoError(t, err)
+
+	err = repo.DeleteLink(ctx, "shortURL")
+	assert.NoError(t, err)
+
+	_, err = repo.GetURL(ctx, "shortURL")
+	assert.IsType(t, &apperrors.ErrURLNotFound{}, err)
+
+	err = repo.DeleteLink(ctx, "shortURL")
+	assert.IsType(t, &apperrors.ErrURLNotFound{}, err)
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_ReserveIdempotencyKey_FirstWins_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+
+	repo := postgresdb.New(dbPool, shortenermock.NewShortener(t), 10)
+
+	now := time.Now()
+
+	first := &domain.IdempotencyRecord{Key: "key", RequestHash: "hash-1", CreatedAt: now, ExpiresAt: now.Add(time.Minute)}
+
+	existing, err := repo.ReserveIdempotencyKey(ctx, first)
+	assert.NoError(t, err)
+	assert.Nil(t, existing)
+
+	second := &domain.IdempotencyRecord{Key: "key", RequestHash: "hash-2", CreatedAt: now.Add(time.Millisecond), ExpiresAt: now.Add(time.Minute)}
+
+	existing, err = repo.ReserveIdempotencyKey(ctx, second)
+	assert.NoError(t, err)
+	assert.Equal(t, "hash-1", existing.RequestHash)
+	assert.True(t, existing.InFlight())
+
+	second.StatusCode = 200
+	err = repo.CompleteIdempotencyRecord(ctx, second)
+	assert.IsType(t, &apperrors.ErrIdempotencyRecordNotFound{}, err)
+
+	first.StatusCode, first.ContentType, first.Body = 200, "application/json", []byte("first")
+	first.Headers = map[string][]string{"Location": {"/v2/links/aaab"}}
+	first.ExpiresAt = now.Add(time.Hour)
+	err = repo.CompleteIdempotencyRecord(ctx, first)
+	assert.NoError(t, err)
+
+	record, err := repo.GetIdempotencyRecord(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, record.StatusCode)
+	assert.Equal(t, []byte("first"), record.Body)
+	assert.Equal(t, first.Headers, record.Headers)
+
+	// A completed record is not released.
+	err = repo.ReleaseIdempotencyKey(ctx, first)
+	assert.NoError(t, err)
+
+	_, err = repo.GetIdempotencyRecord(ctx, "key")
+	assert.NoError(t, err)
+}
+
+func Test_ReserveIdempotencyKey_ReplacesExpired_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+
+	repo := postgresdb.New(dbPool, shortenermock.NewShortener(t), 10)
+
+	_, err := repo.ReserveIdempotencyKey(ctx, &domain.IdempotencyRecord{
+		Key: "key", RequestHash: "hash-1", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(-time.Second),
+	})
+	assert.NoError(t, err)
+
+	_, err = repo.GetIdempotencyRecord(ctx, "key")
+	assert.IsType(t, &apperrors.ErrIdempotencyRecordNotFound{}, err)
+
+	existing, err := repo.ReserveIdempotencyKey(ctx, &domain.IdempotencyRecord{
+		Key: "key", RequestHash: "hash-2", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, existing)
+
+	record, err := repo.GetIdempotencyRecord(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "hash-2", record.RequestHash)
+}
+
+func Test_ReleaseIdempotencyKey_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+
+	repo := postgresdb.New(dbPool, shortenermock.NewShortener(t), 10)
+
+	record := &domain.IdempotencyRecord{Key: "key", RequestHash: "hash", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Minute)}
+
+	_, err := repo.ReserveIdempotencyKey(ctx, record)
+	assert.NoError(t, err)
+
+	err = repo.ReleaseIdempotencyKey(ctx, record)
+	assert.NoError(t, err)
+
+	_, err = repo.GetIdempotencyRecord(ctx, "key")
+	assert.IsType(t, &apperrors.ErrIdempotencyRecordNotFound{}, err)
+}
+
+func Test_DisableLink_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+
+	shortenerMock := shortenermock.NewShortener(t)
+	// ListLinks encodes the codes from the ids.
+	shortenerMock.On("Encode", uint64(0)).Return("shortURL", nil).Twice()
+	shortenerMock.On("Encode", uint64(1)).Return("shortURL2", nil).Twice()
+	shortenerMock.On("Decode", "shortURL").Return(uint64(0), nil).Twice()
+
+	repo := postgresdb.New(dbPool, shortenerMock, 10)
+
+	_, err := repo.SaveURL(ctx, "originURL")
+	assert.NoError(t, err)
+
+	_, err = repo.SaveURL(ctx, "originURL2")
+	assert.NoError(t, err)
+
+	err = repo.DisableLink(ctx, "shortURL")
+	assert.NoError(t, err)
+
+	link, err := repo.GetLink(ctx, "shortURL")
+	assert.NoError(t, err)
+	assert.True(t, link.Disabled)
+
+	links, err := repo.ListLinks(ctx, domain.ListLinksParams{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, links, 2)
+
+	stats, err := repo.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.Stats{Total: 2, Active: 1, Disabled: 1, Capacity: 10}, stats)
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_ImportLink_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+
+	createdAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	shortenerMock := shortenermock.NewShortener(t)
+	shortenerMock.On("Decode", "importedURL").Return(uint64(5), nil).Twice()
+	shortenerMock.On("Encode", uint64(6)).Return("shortURL", nil).Once()
+
+	repo := postgresdb.New(dbPool, shortenerMock, 10)
+
+	link, err := repo.ImportLink(ctx, &domain.Link{
+		Code: "importedURL", Destination: "originURL", CreatedAt: createdAt, Owner: "team-a",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), link.ID)
+	assert.True(t, createdAt.Equal(link.CreatedAt))
+
+	_, err = repo.ImportLink(ctx, &domain.Link{Code: "importedURL", Destination: "originURL2"})
+	assert.IsType(t, &apperrors.ErrLinkConflict{}, err)
+
+	short, err := repo.SaveURL(ctx, "originURL2")
+	assert.NoError(t, err)
+	assert.Equal(t, "shortURL", short)
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_ImportLink_NonCanonical_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+
+	s, err := shortener.NewShortener("abcdefgh", 4)
+	assert.NoError(t, err)
+
+	repo := postgresdb.New(dbPool, s, 10)
+
+	importLink := func(id uint64, destination string, disabled bool) string {
+		code, err := s.Encode(id)
+		assert.NoError(t, err)
+
+		_, err = repo.ImportLink(ctx, &domain.Link{Code: code, Destination: destination, Disabled: disabled})
+		assert.NoError(t, err)
+
+		return code
+	}
+
+	// The disabled link is imported first and replaced, or imported after its replacement and retired.
+	importLink(1, "originURL1", true)
+	replacement1 := importLink(2, "originURL1", false)
+	replacement2 := importLink(3, "originURL2", false)
+	disabled2 := importLink(4, "originURL2", true)
+	importLink(5, "originURL2", false)
+
+	for destination, code := range map[string]string{"originURL1": replacement1, "originURL2": replacement2} {
+		short, err := repo.SaveURL(ctx, destination)
+		assert.NoError(t, err)
+		assert.Equal(t, code, short)
+	}
+
+	link, err := repo.GetLink(ctx, disabled2)
+	assert.NoError(t, err)
+	assert.True(t, link.Disabled)
+
+	links, err := repo.ListLinks(ctx, domain.ListLinksParams{FromID: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, links, 5)
+}
+
+// setupReplica returns a pool whose urls table is an empty copy of the primary one, standing in for
+// a replica that has not caught up yet.
+func setupReplica(t *testing.T, ctx context.Context, dbPool *pgxpool.Pool) *pgxpool.Pool {
+	_, err := dbPool.Exec(ctx, `CREATE SCHEMA replica; CREATE TABLE replica.urls (LIKE public.urls INCLUDING ALL)`)
+	assert.NoError(t, err)
+
+	replicaConfig := dbPool.Config().Copy()
+	replicaConfig.ConnConfig.RuntimeParams["search_path"] = "replica"
+
+	replicaPool, err := pgxpool.NewWithConfig(ctx, replicaConfig)
+	assert.NoError(t, err)
+
+	t.Cleanup(replicaPool.Close)
+
+	return replicaPool
+}
+
+func Test_GetURL_ReplicaFallback_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+	replicaPool := setupReplica(t, ctx, dbPool)
+
+	shortenerMock := shortenermock.NewShortener(t)
+	shortenerMock.On("Encode", uint64(0)).Return("shortURL", nil).Once()
+	shortenerMock.On("Decode", "shortURL").Return(uint64(0), nil).Twice()
+
+	repo := postgresdb.New(dbPool, shortenerMock, 10, postgresdb.WithReplicas(replicaPool))
+
+	_, err := repo.SaveURL(ctx, "originURL")
+	assert.NoError(t, err)
+
+	originalURL, err := repo.GetURL(ctx, "shortURL")
+	assert.NoError(t, err)
+	assert.Equal(t, "originURL", originalURL)
+
+	_, err = replicaPool.Exec(ctx, `INSERT INTO urls (id, url, short_url) VALUES (0, 'replicatedURL', 'shortURL')`)
+	assert.NoError(t, err)
+
+	originalURL, err = repo.GetURL(ctx, "shortURL")
+	assert.NoError(t, err)
+	assert.Equal(t, "replicatedURL", originalURL)
+
+	expected := `
+# HELP compressor_postgres_replica_reads_total Links resolved from a replica by result. Misses and errors are resolved again from the primary.
+# TYPE compressor_postgres_replica_reads_total counter
+compressor_postgres_replica_reads_total{pool="replica-0",result="error"} 0
+compressor_postgres_replica_reads_total{pool="replica-0",result="hit"} 1
+compressor_postgres_replica_reads_total{pool="replica-0",result="miss"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(repo, strings.NewReader(expected), "compressor_postgres_replica_reads_total"))
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_ResolveLink_ReadsReplica_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+	replicaPool := setupReplica(t, ctx, dbPool)
+
+	shortenerMock := shortenermock.NewShortener(t)
+	shortenerMock.On("Encode", uint64(0)).Return("shortURL", nil).Once()
+	shortenerMock.On("Decode", "shortURL").Return(uint64(0), nil).Twice()
+
+	repo := postgresdb.New(dbPool, shortenerMock, 10, postgresdb.WithReplicas(replicaPool))
+
+	_, err := repo.SaveURL(ctx, "originURL")
+	assert.NoError(t, err)
+
+	_, err = replicaPool.Exec(ctx, `INSERT INTO urls (id, url, short_url) VALUES (0, 'replicatedURL', 'shortURL')`)
+	assert.NoError(t, err)
+
+	link, err := repo.ResolveLink(ctx, "shortURL")
+	assert.NoError(t, err)
+	assert.Equal(t, "replicatedURL", link.Destination)
+
+	link, err = repo.GetLink(ctx, "shortURL")
+	assert.NoError(t, err)
+	assert.Equal(t, "originURL", link.Destination)
+
+	shortenerMock.AssertExpectations(t)
+}
+
+func Test_WithTransaction_RollsBackAllOperations_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+
+	s, err := shortener.NewShortener("abcdefgh", 4)
+	assert.NoError(t, err)
+
+	repo := postgresdb.New(dbPool, s, 10)
+	errQuotaExceeded := errors.New("quota exceeded")
+
+	err = repo.WithTransaction(ctx, func(ctx context.Context) error {
+		link, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL1"})
+		assert.NoError(t, err)
+
+		// Reads inside the transaction see its writes.
+		destination, err := repo.GetURL(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, "originURL1", destination)
+
+		_, err = repo.CreateLink(ctx, &domain.Link{Destination: "originURL2"})
+		assert.NoError(t, err)
+
+		return errQuotaExceeded
+	})
+	assert.ErrorIs(t, err, errQuotaExceeded)
+
+	stats, err := repo.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), stats.Total)
+
+	// The ids of the rolled back links are handed out again.
+	link, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL3"})
+	assert.NoError(t, err)
+	assert.Less(t, link.ID, uint64(2))
+}
+
+func Test_WithTransaction_IsolationLevel_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+
+	s, err := shortener.NewShortener("abcdefgh", 4)
+	assert.NoError(t, err)
+
+	for isoLevel, expected := range map[pgx.TxIsoLevel]string{
+		"":               "read committed",
+		pgx.Serializable: "serializable",
+	} {
+		var opts []postgresdb.Option
+		if isoLevel != "" {
+			opts = append(opts, postgresdb.WithIsolationLevel(isoLevel))
+		}
+
+		repo := postgresdb.New(dbPool, s, 10, opts...)
+
+		err = repo.WithTransaction(ctx, func(ctx context.Context) error {
+			var level string
+			if err := txs.GetQuerier(ctx, dbPool).QueryRow(ctx, "SHOW transaction_isolation").Scan(&level); err != nil {
+				return err
+			}
+
+			assert.Equal(t, expected, level)
+
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+}
+
+func Test_WithTransaction_RetriesSerializationFailure_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+
+	s, err := shortener.NewShortener("abcdefgh", 4)
+	assert.NoError(t, err)
+
+	repo := postgresdb.New(dbPool, s, 10)
+	attempts := 0
+
+	err = repo.WithTransaction(ctx, func(ctx context.Context) error {
+		attempts++
+
+		if _, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL"}); err != nil {
+			return err
+		}
+
+		if attempts == 1 {
+			return &pgconn.PgError{Code: "40001", Message: "could not serialize access"}
+		}
+
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+
+	stats, err := repo.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), stats.Total)
+}
+
+func Test_SaveURL_Concurrent_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+
+	s, err := shortener.NewShortener("abcdefgh", 4)
+	assert.NoError(t, err)
+
+	repo := postgresdb.New(dbPool, s, 100)
+
+	const workers = 32
+
+	codes := make([]string, workers)
+	errs := make([]error, workers)
+	start := make(chan struct{})
+
+	var wg sync.WaitGroup
+
+	for i := range workers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			<-start
+
+			codes[i], errs[i] = repo.SaveURL(ctx, "originURL")
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+
+	for i := range workers {
+		assert.NoError(t, errs[i])
+		assert.Equal(t, codes[0], codes[i])
+	}
+
+	var rows int
+	err = dbPool.QueryRow(ctx, `SELECT count(*) FROM urls WHERE url = 'originURL'`).Scan(&rows)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rows)
+}
+
+func Test_Close_ReturnsUnusedIDs_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+
+	s, err := shortener.NewShortener("abcdefgh", 4)
+	assert.NoError(t, err)
+
+	first := postgresdb.New(dbPool, s, 100)
+
+	link, err := first.CreateLink(ctx, &domain.Link{Destination: "originURL1"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), link.ID)
+
+	assert.NoError(t, first.Close(ctx))
+
+	// The rest of the block leased by the first repository is leased by the second one.
+	second := postgresdb.New(dbPool, s, 100)
+
+	link, err = second.CreateLink(ctx, &domain.Link{Destination: "originURL2"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), link.ID)
+}
+
+func Test_CreateLink_SkipsImportedID_Success(t *testing.T) {
+	dbPool, ctx := setupDB(t)
+
+	s, err := shortener.NewShortener("abcdefgh", 4)
+	assert.NoError(t, err)
+
+	repo := postgresdb.New(dbPool, s, 100)
+
+	_, err = repo.CreateLink(ctx, &domain.Link{Destination: "originURL1"})
+	assert.NoError(t, err)
+
+	code, err := s.Encode(1)
+	assert.NoError(t, err)
+
+	// The id of the imported link belongs to the block the repository already leased.
+	_, err = repo.ImportLink(ctx, &domain.Link{Code: code, Destination: "importedURL"})
+	assert.NoError(t, err)
+
+	link, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL2"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), link.ID)
+}
+
+func Test_URLDedupMigration_MergesDuplicates_Success(t *testing.T) {
+	dbPool, testContainer, ctx := setupContainer(t)
+
+	migrator, err := migration.NewMigrator(testContainer.Config, testContainer.ConnectionString(), zap.NewNop())
+	assert.NoError(t, err)
+
+	defer migrator.Close()
+
+	assert.NoError(t, migrator.Goto(3))
+
+	_, err = dbPool.Exec(ctx, `INSERT INTO urls (id, url, short_url) VALUES
+		(0, 'originURL', 'aaaa'), (1, 'otherURL', 'aaab'), (2, 'originURL', 'aaac')`)
+	assert.NoError(t, err)
+
+	assert.NoError(t, migrator.Up(0))
+
+	var duplicateOf *int64
+	err = dbPool.QueryRow(ctx, `SELECT duplicate_of FROM urls WHERE id = 2`).Scan(&duplicateOf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), *duplicateOf)
+
+	s, err := shortener.NewShortener("abcdefgh", 4)
+	assert.NoError(t, err)
+
+	repo := postgresdb.New(dbPool, s, 100)
+
+	link, err := repo.CreateLink(ctx, &domain.Link{Destination: "originURL"})
+	assert.NoError(t, err)
+	assert.Equal(t, "aaaa", link.Code)
+
+	originalURL, err := repo.GetURL(ctx, "aaac")
+	assert.NoError(t, err)
+	assert.Equal(t, "originURL", originalURL)
+
+	assert.NoError(t, repo.DeleteLink(ctx, "aaaa"))
+
+	_, err = repo.GetURL(ctx, "aaac")
+	assert.IsType(t, &apperrors.ErrURLNotFound{}, err)
+}
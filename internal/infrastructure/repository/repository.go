@@ -0,0 +1,56 @@
+// Package repository registers the built-in storage drivers, inmemory, postgres and dynamodb, with
+// the storage package. Binaries import it for its side effects.
+//
+// The settings of each driver are read from storage.<type> and are in Config.Storage.DriverSettings,
+// e.g. a *PostgresSettings for postgres.
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/pkg/idalloc"
+	"github.com/AFK068/compressor/pkg/storage"
+	"go.uber.org/zap"
+)
+
+const closeIDsTimeout = 5 * time.Second
+
+func init() {
+	storage.Register(string(domain.InMemoryRepository), inMemoryDriver{})
+	storage.Register(string(domain.PostgresRepository), postgresDriver{})
+	storage.Register(string(domain.DynamoDBRepository), dynamoDBDriver{})
+}
+
+// fieldErrors collects the problems of driver settings, with paths relative to storage.<type>.
+type fieldErrors struct {
+	fields []*config.FieldError
+}
+
+func (e *fieldErrors) add(path string, err error) {
+	e.fields = append(e.fields, &config.FieldError{Path: path, Err: err})
+}
+
+func (e *fieldErrors) err() error {
+	if len(e.fields) == 0 {
+		return nil
+	}
+
+	return &config.ValidationError{Fields: e.fields}
+}
+
+func newIDAllocator(cfg *config.Config, leaser idalloc.Leaser) *idalloc.Allocator {
+	return idalloc.New(leaser, cfg.Storage.MaxSize, idalloc.WithBlockSize(cfg.Storage.IDs.BlockSize))
+}
+
+// closeIDs gives the unused ids back. When it fails they are skipped, which is logged but harmless.
+func closeIDs(repo storage.Backend, log *zap.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), closeIDsTimeout)
+	defer cancel()
+
+	if err := repo.Close(ctx); err != nil {
+		log.Warn("Failed to return unused ids", zap.Error(err))
+	}
+}
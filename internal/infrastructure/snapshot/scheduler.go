@@ -0,0 +1,79 @@
+package snapshot
+
+import (
+	"context"
+	"time"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// Scheduler creates a snapshot every interval while the service runs and prunes the old ones.
+type Scheduler struct {
+	store    *Store
+	repo     domain.Repository
+	interval time.Duration
+	keep     int
+}
+
+// NewScheduler keeps the keep newest snapshots, or all of them when keep is 0.
+func NewScheduler(store *Store, repo domain.Repository, interval time.Duration, keep int) *Scheduler {
+	return &Scheduler{store: store, repo: repo, interval: interval, keep: keep}
+}
+
+func (s *Scheduler) RegisterHooks(lc fx.Lifecycle, log *zap.Logger) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go s.run(ctx, log)
+
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+
+			return nil
+		},
+	})
+}
+
+func (s *Scheduler) run(ctx context.Context, log *zap.Logger) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.snapshot(ctx, log)
+		}
+	}
+}
+
+func (s *Scheduler) snapshot(ctx context.Context, log *zap.Logger) {
+	manifest, err := s.store.Create(ctx, s.repo)
+	if err != nil {
+		log.Error("Failed to create snapshot", zap.Error(err))
+		return
+	}
+
+	log.Info("Created snapshot",
+		zap.String("id", manifest.ID), zap.Int("links", manifest.Links), zap.Int64("size", manifest.Size))
+
+	if s.keep == 0 {
+		return
+	}
+
+	deleted, err := s.store.Prune(ctx, s.keep)
+	if err != nil {
+		log.Error("Failed to delete old snapshots", zap.Error(err))
+		return
+	}
+
+	if deleted > 0 {
+		log.Info("Deleted old snapshots", zap.Int("count", deleted))
+	}
+}
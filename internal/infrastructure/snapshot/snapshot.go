@@ -0,0 +1,312 @@
+// Package snapshot exports the links of a repository to an S3-compatible bucket and restores them.
+//
+// A snapshot is the JSON Lines export of the links, compressed with gzip, and a manifest holding
+// its SHA-256 checksum. The manifest is written once the export is uploaded, so snapshots that were
+// interrupted are never listed nor restored.
+package snapshot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/infrastructure/linkio"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+const (
+	// IDLayout formats the creation time of a snapshot into its id, so ids sort chronologically. The
+	// id ends with a random suffix, so snapshots created at the same moment do not overwrite each other.
+	IDLayout = "20060102T150405.000Z"
+	// idSuffixBytes is the length of the random suffix before it is hex encoded.
+	idSuffixBytes = 4
+
+	dataSuffix     = ".jsonl.gz"
+	manifestSuffix = ".json"
+)
+
+// ErrChecksumMismatch is returned when a downloaded snapshot differs from its manifest.
+var ErrChecksumMismatch = errors.New("snapshot does not match its checksum")
+
+// Manifest describes a complete snapshot.
+type Manifest struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Links     int       `json:"links"`
+	// Size and SHA256 are the ones of the compressed export.
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Store keeps the snapshots under a prefix of a bucket.
+type Store struct {
+	client s3iface.S3API
+	bucket *string
+	prefix string
+}
+
+func NewStore(client s3iface.S3API, bucket, prefix string) *Store {
+	return &Store{client: client, bucket: aws.String(bucket), prefix: prefix}
+}
+
+// New connects to the bucket configured by cfg.
+func New(cfg config.Snapshots) (*Store, error) {
+	awsConfig := aws.NewConfig().WithRegion(cfg.Region).WithS3ForcePathStyle(cfg.PathStyle)
+	if cfg.Endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(cfg.Endpoint)
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *awsConfig,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating aws session: %w", err)
+	}
+
+	return NewStore(s3.New(sess), cfg.Bucket, cfg.Prefix), nil
+}
+
+// Create exports every link of repo into a new snapshot. The export is streamed to the bucket in
+// parts rather than built in memory.
+func (s *Store) Create(ctx context.Context, repo domain.Repository) (*Manifest, error) {
+	manifest := &Manifest{CreatedAt: time.Now().UTC()}
+
+	suffix := make([]byte, idSuffixBytes)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+
+	manifest.ID = manifest.CreatedAt.Format(IDLayout) + "-" + hex.EncodeToString(suffix)
+
+	reader, writer := io.Pipe()
+	hash := sha256.New()
+	size := &countingWriter{}
+
+	exported := make(chan error, 1)
+
+	go func() {
+		compressed := gzip.NewWriter(io.MultiWriter(writer, hash, size))
+
+		links, err := linkio.Export(ctx, repo, linkio.NewWriter(compressed, linkio.FormatJSONL))
+		err = errors.Join(err, compressed.Close())
+
+		manifest.Links = links
+
+		writer.CloseWithError(err)
+		exported <- err
+	}()
+
+	_, err := s3manager.NewUploaderWithClient(s.client).UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:      s.bucket,
+		Key:         aws.String(s.dataKey(manifest.ID)),
+		Body:        reader,
+		ContentType: aws.String("application/gzip"),
+	})
+	// Stops the export when the upload failed.
+	reader.CloseWithError(err)
+
+	if err := errors.Join(err, <-exported); err != nil {
+		return nil, fmt.Errorf("uploading snapshot %s: %w", manifest.ID, err)
+	}
+
+	manifest.Size = size.n
+	manifest.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      s.bucket,
+		Key:         aws.String(s.manifestKey(manifest.ID)),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("uploading manifest of snapshot %s: %w", manifest.ID, err)
+	}
+
+	return manifest, nil
+}
+
+// List returns the complete snapshots, the oldest first.
+func (s *Store) List(ctx context.Context) ([]*Manifest, error) {
+	var ids []string
+
+	err := s.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: s.bucket,
+		Prefix: aws.String(s.prefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, object := range page.Contents {
+			id, ok := strings.CutSuffix(strings.TrimPrefix(aws.StringValue(object.Key), s.prefix), manifestSuffix)
+			if ok && !strings.Contains(id, "/") {
+				ids = append(ids, id)
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing snapshots: %w", err)
+	}
+
+	slices.Sort(ids)
+
+	manifests := make([]*Manifest, 0, len(ids))
+
+	for _, id := range ids {
+		manifest, err := s.Manifest(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		manifests = append(manifests, manifest)
+	}
+
+	return manifests, nil
+}
+
+// Manifest returns the manifest of the snapshot with the id.
+func (s *Store) Manifest(ctx context.Context, id string) (*Manifest, error) {
+	out, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: s.bucket,
+		Key:    aws.String(s.manifestKey(id)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest of snapshot %s: %w", id, err)
+	}
+	defer out.Body.Close()
+
+	var manifest Manifest
+	if err := json.NewDecoder(out.Body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest of snapshot %s: %w", id, err)
+	}
+
+	return &manifest, nil
+}
+
+// Restore imports the links of the snapshot with the id into repo, keeping their codes. The
+// snapshot is downloaded and checked against its checksum before anything is imported. Links
+// that fail to import are passed to onError and skipped.
+func (s *Store) Restore(
+	ctx context.Context,
+	id string,
+	repo domain.Repository,
+	onError func(*linkio.RowError),
+) (linkio.ImportReport, error) {
+	manifest, err := s.Manifest(ctx, id)
+	if err != nil {
+		return linkio.ImportReport{}, err
+	}
+
+	file, err := os.CreateTemp("", "snapshot-*"+dataSuffix)
+	if err != nil {
+		return linkio.ImportReport{}, err
+	}
+
+	defer func() {
+		file.Close()
+		os.Remove(file.Name())
+	}()
+
+	if err := s.download(ctx, manifest, file); err != nil {
+		return linkio.ImportReport{}, err
+	}
+
+	compressed, err := gzip.NewReader(file)
+	if err != nil {
+		return linkio.ImportReport{}, fmt.Errorf("reading snapshot %s: %w", id, err)
+	}
+
+	return linkio.Import(ctx, repo, linkio.NewReader(compressed, linkio.FormatJSONL), onError)
+}
+
+// download writes the snapshot to file, checks it and rewinds file.
+func (s *Store) download(ctx context.Context, manifest *Manifest, file *os.File) error {
+	_, err := s3manager.NewDownloaderWithClient(s.client).DownloadWithContext(ctx, file, &s3.GetObjectInput{
+		Bucket: s.bucket,
+		Key:    aws.String(s.dataKey(manifest.ID)),
+	})
+	if err != nil {
+		return fmt.Errorf("downloading snapshot %s: %w", manifest.ID, err)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return err
+	}
+
+	if size != manifest.Size || hex.EncodeToString(hash.Sum(nil)) != manifest.SHA256 {
+		return fmt.Errorf("snapshot %s: %w", manifest.ID, ErrChecksumMismatch)
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+
+	return err
+}
+
+// Prune deletes all but the keep newest snapshots and returns how many were deleted.
+func (s *Store) Prune(ctx context.Context, keep int) (int, error) {
+	manifests, err := s.List(ctx)
+	if err != nil || len(manifests) <= keep {
+		return 0, err
+	}
+
+	deleted := 0
+
+	for _, manifest := range manifests[:len(manifests)-keep] {
+		// The manifest goes first, so a snapshot is never listed without its export.
+		for _, key := range []string{s.manifestKey(manifest.ID), s.dataKey(manifest.ID)} {
+			_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{Bucket: s.bucket, Key: aws.String(key)})
+			if err != nil {
+				return deleted, fmt.Errorf("deleting snapshot %s: %w", manifest.ID, err)
+			}
+		}
+
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+func (s *Store) dataKey(id string) string {
+	return s.prefix + id + dataSuffix
+}
+
+func (s *Store) manifestKey(id string) string {
+	return s.prefix + id + manifestSuffix
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,184 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/infrastructure/linkio"
+	"github.com/AFK068/compressor/internal/infrastructure/repository/inmemoryrepo"
+	"github.com/AFK068/compressor/internal/infrastructure/snapshot"
+	"github.com/AFK068/compressor/internal/testcontainer"
+	"github.com/AFK068/compressor/pkg/shortener"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testBucket = "snapshots"
+	testPrefix = "compressor/"
+)
+
+func setupBucket(t *testing.T) (*s3.S3, context.Context) {
+	ctx := context.Background()
+
+	testContainer, err := testcontainer.NewMinIOTestcontainer(ctx)
+	assert.NoError(t, err)
+
+	client, cleanup, err := testContainer.SetupTestMinIOContainer(ctx, testBucket)
+	assert.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, cleanup())
+	})
+
+	return client, ctx
+}
+
+func newRepository(t *testing.T) *inmemoryrepo.InMemoryRepository {
+	s, err := shortener.NewShortener("abcdefgh", 4)
+	assert.NoError(t, err)
+
+	return inmemoryrepo.New(s, 1000)
+}
+
+func failOnRowError(t *testing.T) func(*linkio.RowError) {
+	return func(rowErr *linkio.RowError) {
+		t.Errorf("unexpected row error: %v", rowErr)
+	}
+}
+
+func Test_CreateRestore_RoundTrip_Success(t *testing.T) {
+	client, ctx := setupBucket(t)
+	store := snapshot.NewStore(client, testBucket, testPrefix)
+
+	source := newRepository(t)
+
+	for i := range 300 {
+		_, err := source.CreateLink(ctx, &domain.Link{Destination: fmt.Sprintf("http://example.com/%d", i), Owner: "team-a"})
+		assert.NoError(t, err)
+	}
+
+	manifest, err := store.Create(ctx, source)
+	assert.NoError(t, err)
+	assert.Equal(t, 300, manifest.Links)
+
+	manifests, err := store.List(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []*snapshot.Manifest{manifest}, manifests)
+
+	target := newRepository(t)
+
+	report, err := store.Restore(ctx, manifest.ID, target, failOnRowError(t))
+	assert.NoError(t, err)
+	assert.Equal(t, linkio.ImportReport{Imported: 300}, report)
+
+	want, err := source.ListLinks(ctx, domain.ListLinksParams{Limit: 1000})
+	assert.NoError(t, err)
+
+	got, err := target.ListLinks(ctx, domain.ListLinksParams{Limit: 1000})
+	assert.NoError(t, err)
+	assert.Len(t, got, len(want))
+
+	for i := range want {
+		assert.Equal(t, want[i].Code, got[i].Code)
+		assert.Equal(t, want[i].Destination, got[i].Destination)
+		assert.Equal(t, want[i].Owner, got[i].Owner)
+	}
+}
+
+func Test_Restore_Corrupted_Failure(t *testing.T) {
+	client, ctx := setupBucket(t)
+	store := snapshot.NewStore(client, testBucket, testPrefix)
+
+	source := newRepository(t)
+
+	_, err := source.SaveURL(ctx, "http://example.com")
+	assert.NoError(t, err)
+
+	manifest, err := store.Create(ctx, source)
+	assert.NoError(t, err)
+
+	_, err = client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(testBucket),
+		Key:    aws.String(testPrefix + manifest.ID + ".jsonl.gz"),
+		Body:   bytes.NewReader([]byte("corrupted")),
+	})
+	assert.NoError(t, err)
+
+	target := newRepository(t)
+
+	_, err = store.Restore(ctx, manifest.ID, target, failOnRowError(t))
+	assert.ErrorIs(t, err, snapshot.ErrChecksumMismatch)
+
+	// Nothing was imported.
+	stats, err := target.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), stats.Total)
+}
+
+func Test_List_SkipsIncomplete_Success(t *testing.T) {
+	client, ctx := setupBucket(t)
+	store := snapshot.NewStore(client, testBucket, testPrefix)
+
+	// An export whose manifest was never written, e.g. because the process stopped.
+	_, err := client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(testBucket),
+		Key:    aws.String(testPrefix + "20200101T000000Z.jsonl.gz"),
+		Body:   bytes.NewReader(nil),
+	})
+	assert.NoError(t, err)
+
+	manifests, err := store.List(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, manifests)
+}
+
+func Test_Create_SameMoment_Success(t *testing.T) {
+	client, ctx := setupBucket(t)
+	store := snapshot.NewStore(client, testBucket, testPrefix)
+
+	repo := newRepository(t)
+
+	first, err := store.Create(ctx, repo)
+	assert.NoError(t, err)
+
+	second, err := store.Create(ctx, repo)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+
+	manifests, err := store.List(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, manifests, 2)
+}
+
+func Test_Prune_KeepsNewest_Success(t *testing.T) {
+	client, ctx := setupBucket(t)
+	store := snapshot.NewStore(client, testBucket, testPrefix)
+
+	repo := newRepository(t)
+
+	var created []*snapshot.Manifest
+
+	for range 3 {
+		manifest, err := store.Create(ctx, repo)
+		assert.NoError(t, err)
+
+		created = append(created, manifest)
+
+		// Snapshots created within the same millisecond are ordered by their random suffixes instead.
+		time.Sleep(time.Millisecond)
+	}
+
+	deleted, err := store.Prune(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	manifests, err := store.List(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, created[1:], manifests)
+}
@@ -0,0 +1,304 @@
+// Package migratecmd implements the migrate command shared by the server binary and compressorctl.
+package migratecmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/migration"
+	"go.uber.org/zap"
+)
+
+const Usage = "[up [--dry-run] [N] | down (N | --all) | goto V | version | force V]"
+
+// ErrUsage is returned after the usage of the command was printed for invalid arguments.
+var ErrUsage = errors.New("usage")
+
+// Printer prints the outcome of the migrate command.
+type Printer interface {
+	PrintChangesets(changesets []migration.Changeset) error
+	// PrintMigrationVersion prints the applied version, nil when no changeset has been applied.
+	PrintMigrationVersion(version *uint, dirty bool) error
+}
+
+// Command runs the migrate actions against the migrator returned by Open, which is only called once
+// the arguments are valid. Closing the migrator is left to the caller.
+type Command struct {
+	// Program prefixes the usage, e.g. "compressorctl".
+	Program string
+	Err     io.Writer
+	Printer Printer
+	Open    func() (*migration.Migrator, error)
+}
+
+// Open opens the migrator of the storage configured by cfg, refusing storages without changesets.
+func Open(cfg *config.Config, log *zap.Logger) (*migration.Migrator, error) {
+	settings, ok := cfg.Storage.DriverSettings.(config.MigratableSettings)
+	if !ok {
+		return nil, fmt.Errorf("storage type %s has no migrations", cfg.Storage.Type)
+	}
+
+	return migration.NewMigrator(cfg, settings.MigrationDSN(), log)
+}
+
+// Run runs the action named by the first argument, "up" when it is omitted or a flag.
+func (c *Command) Run(args []string) error {
+	flags := c.newFlagSet("migrate", Usage)
+
+	action := "up"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	run, ok := map[string]func(args []string) error{
+		"up":      c.up,
+		"down":    c.down,
+		"goto":    c.gotoVersion,
+		"version": c.version,
+		"force":   c.force,
+	}[action]
+	if !ok {
+		flags.Usage()
+		return ErrUsage
+	}
+
+	return run(args)
+}
+
+func (c *Command) up(args []string) error {
+	flags := c.newFlagSet("migrate up", "[--dry-run] [N]")
+	dryRun := flags.Bool("dry-run", false, "list the pending changesets without applying them")
+
+	if err := parseMaxArgs(flags, args, 1); err != nil {
+		return err
+	}
+
+	n, err := parseCount(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	migrator, err := c.Open()
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		pending, err := migrator.Pending()
+		if err != nil {
+			return err
+		}
+
+		if n > 0 && n < len(pending) {
+			pending = pending[:n]
+		}
+
+		return c.Printer.PrintChangesets(pending)
+	}
+
+	if err := migrator.Up(n); err != nil {
+		return err
+	}
+
+	return c.printVersion(migrator)
+}
+
+func (c *Command) down(args []string) error {
+	flags := c.newFlagSet("migrate down", "(N | --all)")
+	all := flags.Bool("all", false, "roll back every applied changeset")
+
+	if err := parseMaxArgs(flags, args, 1); err != nil {
+		return err
+	}
+
+	// Rolling back everything drops all links, so it has to be asked for explicitly.
+	if *all == (flags.NArg() == 1) {
+		flags.Usage()
+		return ErrUsage
+	}
+
+	n, err := parseCount(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	migrator, err := c.Open()
+	if err != nil {
+		return err
+	}
+
+	if err := migrator.Down(n); err != nil {
+		return err
+	}
+
+	return c.printVersion(migrator)
+}
+
+func (c *Command) gotoVersion(args []string) error {
+	flags := c.newFlagSet("migrate goto", "V")
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+
+	version, err := strconv.ParseUint(flags.Arg(0), 10, 0)
+	if err != nil {
+		return fmt.Errorf("invalid version %q", flags.Arg(0))
+	}
+
+	migrator, err := c.Open()
+	if err != nil {
+		return err
+	}
+
+	if err := migrator.Goto(uint(version)); err != nil {
+		return err
+	}
+
+	return c.printVersion(migrator)
+}
+
+func (c *Command) version(args []string) error {
+	flags := c.newFlagSet("migrate version", "")
+	if err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	migrator, err := c.Open()
+	if err != nil {
+		return err
+	}
+
+	return c.printVersion(migrator)
+}
+
+func (c *Command) force(args []string) error {
+	flags := c.newFlagSet("migrate force", "V")
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+
+	version, err := strconv.Atoi(flags.Arg(0))
+	if err != nil || version < -1 {
+		return fmt.Errorf("invalid version %q, expected a version or -1 for none", flags.Arg(0))
+	}
+
+	migrator, err := c.Open()
+	if err != nil {
+		return err
+	}
+
+	if err := migrator.Force(version); err != nil {
+		return err
+	}
+
+	return c.printVersion(migrator)
+}
+
+func (c *Command) printVersion(migrator *migration.Migrator) error {
+	version, dirty, err := migrator.Version()
+	if errors.Is(err, migration.ErrNoVersion) {
+		return c.Printer.PrintMigrationVersion(nil, false)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return c.Printer.PrintMigrationVersion(&version, dirty)
+}
+
+func (c *Command) newFlagSet(name, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.Err)
+	flags.Usage = func() {
+		fmt.Fprintf(c.Err, "Usage: %s %s %s\n", c.Program, name, usage)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// parseArgs parses flags and requires exactly want positional arguments.
+func parseArgs(flags *flag.FlagSet, args []string, want int) error {
+	if err := flags.Parse(args); err != nil {
+		return ErrUsage
+	}
+
+	if flags.NArg() != want {
+		flags.Usage()
+		return ErrUsage
+	}
+
+	return nil
+}
+
+// parseMaxArgs parses flags and allows at most limit positional arguments.
+func parseMaxArgs(flags *flag.FlagSet, args []string, limit int) error {
+	if err := flags.Parse(args); err != nil {
+		return ErrUsage
+	}
+
+	if flags.NArg() > limit {
+		flags.Usage()
+		return ErrUsage
+	}
+
+	return nil
+}
+
+// parseCount parses an optional positive number of changesets, "" means all of them.
+func parseCount(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid number of changesets %q", s)
+	}
+
+	return n, nil
+}
+
+// TextPrinter prints the outcome of the migrate command as plain text.
+type TextPrinter struct {
+	Out io.Writer
+}
+
+func (p *TextPrinter) PrintChangesets(changesets []migration.Changeset) error {
+	if len(changesets) == 0 {
+		_, err := fmt.Fprintln(p.Out, "no pending changesets")
+		return err
+	}
+
+	w := tabwriter.NewWriter(p.Out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tCHANGESET")
+
+	for _, changeset := range changesets {
+		fmt.Fprintf(w, "%d\t%s\n", changeset.Version, changeset.Identifier)
+	}
+
+	return w.Flush()
+}
+
+func (p *TextPrinter) PrintMigrationVersion(version *uint, dirty bool) error {
+	if version == nil {
+		_, err := fmt.Fprintln(p.Out, "no changesets applied")
+		return err
+	}
+
+	message := fmt.Sprintf("version %d", *version)
+	if dirty {
+		message += " (dirty, fix the database and run migrate force)"
+	}
+
+	_, err := fmt.Fprintln(p.Out, message)
+
+	return err
+}
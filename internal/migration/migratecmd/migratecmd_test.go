@@ -0,0 +1,74 @@
+package migratecmd_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/AFK068/compressor/internal/migration"
+	"github.com/AFK068/compressor/internal/migration/migratecmd"
+	"github.com/stretchr/testify/assert"
+)
+
+var errOpened = errors.New("opened")
+
+func newCommand(opened *bool) (*migratecmd.Command, *bytes.Buffer) {
+	var out bytes.Buffer
+
+	return &migratecmd.Command{
+		Program: "test",
+		Err:     &out,
+		Printer: &migratecmd.TextPrinter{Out: &out},
+		Open: func() (*migration.Migrator, error) {
+			*opened = true
+			return nil, errOpened
+		},
+	}, &out
+}
+
+func Test_Run_DefaultsToUp_Success(t *testing.T) {
+	for _, args := range [][]string{nil, {"--dry-run"}, {"up", "2"}} {
+		var opened bool
+
+		command, _ := newCommand(&opened)
+
+		assert.ErrorIs(t, command.Run(args), errOpened, args)
+		assert.True(t, opened, args)
+	}
+}
+
+func Test_Run_InvalidArgs_Failure(t *testing.T) {
+	for _, args := range [][]string{{"sideways"}, {"down"}, {"down", "1", "--all"}, {"goto"}, {"version", "1"}} {
+		var opened bool
+
+		command, out := newCommand(&opened)
+
+		assert.ErrorIs(t, command.Run(args), migratecmd.ErrUsage, args)
+		assert.False(t, opened, args)
+		assert.Contains(t, out.String(), "Usage: test migrate", args)
+	}
+}
+
+func Test_Run_InvalidCount_Failure(t *testing.T) {
+	var opened bool
+
+	command, _ := newCommand(&opened)
+
+	assert.EqualError(t, command.Run([]string{"down", "0"}), `invalid number of changesets "0"`)
+	assert.False(t, opened)
+}
+
+func Test_TextPrinter_Success(t *testing.T) {
+	var out bytes.Buffer
+
+	printer := &migratecmd.TextPrinter{Out: &out}
+
+	assert.NoError(t, printer.PrintChangesets([]migration.Changeset{{Version: 4, Identifier: "url_dedup"}}))
+	assert.NoError(t, printer.PrintMigrationVersion(nil, false))
+
+	version := uint(4)
+	assert.NoError(t, printer.PrintMigrationVersion(&version, true))
+
+	assert.Equal(t, "VERSION  CHANGESET\n4        url_dedup\nno changesets applied\n"+
+		"version 4 (dirty, fix the database and run migrate force)\n", out.String())
+}
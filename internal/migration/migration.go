@@ -3,9 +3,14 @@ package migration
 import (
 	"errors"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/migrations"
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"go.uber.org/zap"
 
 	_ "github.com/golang-migrate/migrate/v4/database/postgres" //nolint
@@ -13,24 +18,51 @@ import (
 	_ "github.com/jackc/pgx/v5"
 )
 
-func RunMigration(cfg *config.Config, log *zap.Logger) error {
-	log.Info("Running migration")
+// ErrNoVersion is returned by Migrator.Version when no changeset has been applied yet.
+var ErrNoVersion = migrate.ErrNilVersion
 
-	migrator, err := migrate.New(
-		fmt.Sprintf("file://%s", cfg.Migration.MigrationsPath),
-		cfg.GetPostgresConnectionString(),
-	)
+// Changeset is a migration in the changesets directory, e.g. version 1 and identifier "link_metadata".
+type Changeset struct {
+	Version    uint
+	Identifier string
+}
 
+// Migrator applies, rolls back and inspects the embedded changesets, or the ones in
+// cfg.Migration.MigrationsPath when it is set, in the database at databaseURL.
+type Migrator struct {
+	migrate *migrate.Migrate
+	cfg     *config.Config
+	log     *zap.Logger
+}
+
+func NewMigrator(cfg *config.Config, databaseURL string, log *zap.Logger) (*Migrator, error) {
+	src, err := openSource(cfg)
 	if err != nil {
-		return fmt.Errorf("creating migrator: %w", err)
+		return nil, err
 	}
 
-	if err := migrator.Up(); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			log.Info("no migrations to apply")
-			return nil
-		}
+	m, err := migrate.NewWithSourceInstance(sourceName(cfg), src, databaseURL)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("creating migrator: %w", err), src.Close())
+	}
 
+	return &Migrator{
+		migrate: m,
+		cfg:     cfg,
+		log:     log,
+	}, nil
+}
+
+func RunMigration(cfg *config.Config, databaseURL string, log *zap.Logger) error {
+	log.Info("Running migration")
+
+	migrator, err := NewMigrator(cfg, databaseURL, log)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	if err := migrator.Up(0); err != nil {
 		return fmt.Errorf("applying migrations: %w", err)
 	}
 
@@ -38,3 +70,155 @@ func RunMigration(cfg *config.Config, log *zap.Logger) error {
 
 	return nil
 }
+
+// Up applies the next n pending changesets, or all of them when n is 0.
+func (m *Migrator) Up(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid number of changesets %d", n)
+	}
+
+	if n == 0 {
+		return m.noChangeIsOK(m.migrate.Up())
+	}
+
+	return m.noChangeIsOK(m.migrate.Steps(n))
+}
+
+// Down rolls back the last n applied changesets, or all of them when n is 0.
+func (m *Migrator) Down(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid number of changesets %d", n)
+	}
+
+	if n == 0 {
+		return m.noChangeIsOK(m.migrate.Down())
+	}
+
+	return m.noChangeIsOK(m.migrate.Steps(-n))
+}
+
+// Goto applies or rolls back changesets until version is the applied version.
+func (m *Migrator) Goto(version uint) error {
+	return m.noChangeIsOK(m.migrate.Migrate(version))
+}
+
+// Version returns the applied version and whether its changeset failed half way. It returns
+// ErrNoVersion when no changeset has been applied.
+func (m *Migrator) Version() (version uint, dirty bool, err error) {
+	return m.migrate.Version()
+}
+
+// Force sets the applied version without running any changeset and clears the dirty flag,
+// so a failed changeset can be retried after fixing the database by hand. -1 means no version.
+func (m *Migrator) Force(version int) error {
+	return m.migrate.Force(version)
+}
+
+// Pending returns the changesets Up would apply, in order.
+func (m *Migrator) Pending() ([]Changeset, error) {
+	changesets, err := Changesets(m.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	version, _, err := m.Version()
+	if errors.Is(err, ErrNoVersion) {
+		return changesets, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]Changeset, 0, len(changesets))
+
+	for _, changeset := range changesets {
+		if changeset.Version > version {
+			pending = append(pending, changeset)
+		}
+	}
+
+	return pending, nil
+}
+
+func (m *Migrator) Close() {
+	sourceErr, databaseErr := m.migrate.Close()
+	if err := errors.Join(sourceErr, databaseErr); err != nil {
+		m.log.Error("Failed to close migrator", zap.Error(err))
+	}
+}
+
+func (m *Migrator) noChangeIsOK(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		m.log.Info("no migrations to apply")
+		return nil
+	}
+
+	return err
+}
+
+// Changesets lists every up changeset the migrator of cfg applies, in order.
+func Changesets(cfg *config.Config) ([]Changeset, error) {
+	driver, err := openSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer driver.Close()
+
+	var changesets []Changeset
+
+	version, err := driver.First()
+
+	for err == nil {
+		var (
+			r          io.ReadCloser
+			identifier string
+		)
+
+		r, identifier, err = driver.ReadUp(version)
+		if err != nil {
+			return nil, fmt.Errorf("reading changeset %d: %w", version, err)
+		}
+
+		_ = r.Close()
+
+		changesets = append(changesets, Changeset{Version: version, Identifier: identifier})
+
+		version, err = driver.Next(version)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("listing changesets: %w", err)
+	}
+
+	return changesets, nil
+}
+
+// openSource opens the changesets embedded into the binary, or the directory
+// cfg.Migration.MigrationsPath when it is set.
+func openSource(cfg *config.Config) (source.Driver, error) {
+	var (
+		driver source.Driver
+		err    error
+	)
+
+	if cfg.Migration.MigrationsPath != "" {
+		driver, err = source.Open("file://" + cfg.Migration.MigrationsPath)
+	} else {
+		driver, err = iofs.New(migrations.Changesets, migrations.ChangesetsDir)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("opening migrations source: %w", err)
+	}
+
+	return driver, nil
+}
+
+func sourceName(cfg *config.Config) string {
+	if cfg.Migration.MigrationsPath != "" {
+		return "file"
+	}
+
+	return "iofs"
+}
@@ -0,0 +1,30 @@
+package migration_test
+
+import (
+	"testing"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/migration"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Changesets_Success(t *testing.T) {
+	changesets, err := migration.Changesets(&config.Config{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []migration.Changeset{
+		{Version: 0, Identifier: "initial_urls"},
+		{Version: 1, Identifier: "link_metadata"},
+		{Version: 2, Identifier: "idempotency_keys"},
+		{Version: 3, Identifier: "link_disabled"},
+		{Version: 4, Identifier: "url_dedup"},
+		{Version: 5, Identifier: "id_leases"},
+		{Version: 6, Identifier: "idempotency_headers"},
+	}, changesets)
+}
+
+func Test_Changesets_PathOverride_Success(t *testing.T) {
+	changesets, err := migration.Changesets(&config.Config{Migration: config.Migration{MigrationsPath: t.TempDir()}})
+	assert.NoError(t, err)
+	assert.Empty(t, changesets)
+}
@@ -7,30 +7,71 @@ import (
 	"github.com/AFK068/compressor/internal/config"
 	"github.com/AFK068/compressor/internal/infrastructure/httpapi/compressorapi"
 	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 
+	admintypes "github.com/AFK068/compressor/internal/api/openapi/compressor/admin"
 	compressortypes "github.com/AFK068/compressor/internal/api/openapi/compressor/v1"
+	linktypes "github.com/AFK068/compressor/internal/api/openapi/compressor/v2"
+)
+
+const (
+	LinksAPIBaseURL = "/v2"
+	AdminAPIBaseURL = "/admin"
+	MetricsPath     = "/metrics"
 )
 
 type Compressor struct {
-	Config  *config.Config
-	Handler *compressorapi.Handler
-	Echo    *echo.Echo
-	logger  *zap.Logger
+	Config       *config.Config
+	Handler      *compressorapi.Handler
+	LinksHandler *compressorapi.LinksHandler
+	AdminHandler *compressorapi.AdminHandler
+	Idempotency  *compressorapi.Idempotency
+	Metrics      *prometheus.Registry
+	Echo         *echo.Echo
+	logger       *zap.Logger
 }
 
-func NewCompressor(cfg *config.Config, handler *compressorapi.Handler, logger *zap.Logger) *Compressor {
+func NewCompressor(
+	cfg *config.Config,
+	handler *compressorapi.Handler,
+	linksHandler *compressorapi.LinksHandler,
+	adminHandler *compressorapi.AdminHandler,
+	idempotency *compressorapi.Idempotency,
+	metrics *prometheus.Registry,
+	logger *zap.Logger,
+) *Compressor {
+	e := echo.New()
+	e.HTTPErrorHandler = compressorapi.HTTPErrorHandler
+
 	return &Compressor{
-		Config:  cfg,
-		Handler: handler,
-		Echo:    echo.New(),
-		logger:  logger,
+		Config:       cfg,
+		Handler:      handler,
+		LinksHandler: linksHandler,
+		AdminHandler: adminHandler,
+		Idempotency:  idempotency,
+		Metrics:      metrics,
+		Echo:         e,
+		logger:       logger,
 	}
 }
 
 func (c *Compressor) Start() error {
+	c.Echo.Use(c.Idempotency.Middleware("/url", LinksAPIBaseURL+"/links"))
+
+	c.Echo.GET(MetricsPath, echo.WrapHandler(promhttp.HandlerFor(c.Metrics, promhttp.HandlerOpts{})))
+
 	compressortypes.RegisterHandlers(c.Echo, c.Handler)
+	linktypes.RegisterHandlersWithBaseURL(c.Echo, c.LinksHandler, LinksAPIBaseURL)
+
+	if c.Config.Admin.Token != "" {
+		admin := c.Echo.Group(AdminAPIBaseURL, compressorapi.AdminAuth(c.Config.Admin.Token))
+		admintypes.RegisterHandlers(admin, c.AdminHandler)
+	} else {
+		c.logger.Info("Admin API is disabled, set admin.token to enable it")
+	}
 
 	return c.Echo.Start(":" + c.Config.Shortener.Port)
 }
@@ -43,6 +84,8 @@ func (c *Compressor) Stop() error {
 }
 
 func (c *Compressor) RegisterHooks(lc fx.Lifecycle, log *zap.Logger) {
+	c.Idempotency.RegisterHooks(lc, log)
+
 	lc.Append(fx.Hook{
 		OnStart: func(context.Context) error {
 			log.Info("Starting compressor server")
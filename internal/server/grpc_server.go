@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"net"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/infrastructure/grpcapi"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/reflection"
+
+	compressorv1 "github.com/AFK068/compressor/internal/api/grpc/compressor/v1"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type GRPC struct {
+	Config  *config.Config
+	Server  *grpc.Server
+	Health  *health.Server
+	Service *grpcapi.Service
+	logger  *zap.Logger
+}
+
+func NewGRPC(cfg *config.Config, service *grpcapi.Service, logger *zap.Logger) *GRPC {
+	srv := grpc.NewServer(grpc.UnaryInterceptor(grpcapi.AdminAuth(cfg.Admin.Token)))
+	healthServer := health.NewServer()
+
+	compressorv1.RegisterCompressorServiceServer(srv, service)
+	healthpb.RegisterHealthServer(srv, healthServer)
+	reflection.Register(srv)
+
+	return &GRPC{
+		Config:  cfg,
+		Server:  srv,
+		Health:  healthServer,
+		Service: service,
+		logger:  logger,
+	}
+}
+
+func (g *GRPC) Serve(listener net.Listener) error {
+	g.Health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	g.Health.SetServingStatus(compressorv1.CompressorService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	return g.Server.Serve(listener)
+}
+
+func (g *GRPC) Stop() {
+	g.Health.Shutdown()
+	g.Server.GracefulStop()
+}
+
+func (g *GRPC) RegisterHooks(lc fx.Lifecycle, log *zap.Logger) {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			log.Info("Starting gRPC server", zap.String("port", g.Config.Shortener.GRPCPort))
+
+			listener, err := net.Listen("tcp", ":"+g.Config.Shortener.GRPCPort)
+			if err != nil {
+				return err
+			}
+
+			go func() {
+				if err := g.Serve(listener); err != nil {
+					log.Error("Failed to start gRPC server", zap.Error(err))
+				}
+			}()
+
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			log.Info("Stopping gRPC server")
+
+			g.Stop()
+
+			return nil
+		},
+	})
+}
@@ -0,0 +1,80 @@
+package testcontainer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	DefaultDynamoDBLocalImage = "amazon/dynamodb-local:2.5.2"
+
+	dynamoDBLocalPort = "8000/tcp"
+)
+
+type DynamoDBTestcontainer struct {
+	testcontainers.Container
+}
+
+// NewDynamoDBTestcontainer starts DynamoDB Local, which keeps the tables in memory.
+func NewDynamoDBTestcontainer(ctx context.Context) (*DynamoDBTestcontainer, error) {
+	req := testcontainers.ContainerRequest{
+		Image:        DefaultDynamoDBLocalImage,
+		ExposedPorts: []string{dynamoDBLocalPort},
+		Cmd:          []string{"-jar", "DynamoDBLocal.jar", "-inMemory"},
+		WaitingFor:   wait.ForListeningPort(dynamoDBLocalPort),
+	}
+
+	dynamo, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error starting DynamoDB Local container: %w", err)
+	}
+
+	return &DynamoDBTestcontainer{Container: dynamo}, nil
+}
+
+// SetupTestDynamoDBContainer returns a client of the container. DynamoDB Local accepts any
+// credentials.
+func (d *DynamoDBTestcontainer) SetupTestDynamoDBContainer(ctx context.Context) (*dynamodb.DynamoDB, CleanFunc, error) {
+	contextWithTimeout, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	host, err := d.Host(contextWithTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	port, err := d.MappedPort(contextWithTimeout, nat.Port(dynamoDBLocalPort))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sess, err := session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithEndpoint(fmt.Sprintf("http://%s:%s", host, port.Port())).
+		WithCredentials(credentials.NewStaticCredentials("local", "local", "")),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clean := func() error {
+		contextWithTimeout, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		return d.Terminate(contextWithTimeout)
+	}
+
+	return dynamodb.New(sess), clean, nil
+}
@@ -0,0 +1,91 @@
+package testcontainer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	DefaultMinIOImage = "minio/minio:RELEASE.2024-10-13T13-34-11Z"
+
+	minioPort     = "9000/tcp"
+	minioUser     = "minioadmin"
+	minioPassword = "minioadmin"
+)
+
+type MinIOTestcontainer struct {
+	testcontainers.Container
+}
+
+func NewMinIOTestcontainer(ctx context.Context) (*MinIOTestcontainer, error) {
+	req := testcontainers.ContainerRequest{
+		Image:        DefaultMinIOImage,
+		ExposedPorts: []string{minioPort},
+		Cmd:          []string{"server", "/data"},
+		Env: map[string]string{
+			"MINIO_ROOT_USER":     minioUser,
+			"MINIO_ROOT_PASSWORD": minioPassword,
+		},
+		WaitingFor: wait.ForHTTP("/minio/health/ready").WithPort(minioPort),
+	}
+
+	minio, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error starting MinIO container: %w", err)
+	}
+
+	return &MinIOTestcontainer{Container: minio}, nil
+}
+
+// SetupTestMinIOContainer creates the bucket and returns a client of the container.
+func (m *MinIOTestcontainer) SetupTestMinIOContainer(ctx context.Context, bucket string) (*s3.S3, CleanFunc, error) {
+	contextWithTimeout, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	host, err := m.Host(contextWithTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	port, err := m.MappedPort(contextWithTimeout, nat.Port(minioPort))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sess, err := session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithEndpoint(fmt.Sprintf("http://%s:%s", host, port.Port())).
+		WithS3ForcePathStyle(true).
+		WithCredentials(credentials.NewStaticCredentials(minioUser, minioPassword, "")),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client := s3.New(sess)
+
+	if _, err := client.CreateBucketWithContext(contextWithTimeout, &s3.CreateBucketInput{Bucket: aws.String(bucket)}); err != nil {
+		return nil, nil, err
+	}
+
+	clean := func() error {
+		contextWithTimeout, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		return m.Terminate(contextWithTimeout)
+	}
+
+	return client, clean, nil
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/infrastructure/repository"
 	"github.com/AFK068/compressor/internal/migration"
 	"github.com/AFK068/compressor/pkg/logger"
 	"github.com/docker/go-connections/nat"
@@ -28,15 +29,16 @@ const (
 )
 
 func NewPostgresTestcontainerContainer(ctx context.Context, cfg *config.Config) (*PostgresTestcontainer, error) {
-	portWithTCP := fmt.Sprintf("%s/tcp", cfg.Storage.Port)
+	settings := cfg.Storage.DriverSettings.(*repository.PostgresSettings)
+	portWithTCP := fmt.Sprintf("%s/tcp", settings.Port)
 
 	req := testcontainers.ContainerRequest{
 		Image:        DefaultTestContainerImage,
 		ExposedPorts: []string{portWithTCP},
 		Env: map[string]string{
-			"POSTGRES_USER":     cfg.Storage.User,
-			"POSTGRES_PASSWORD": cfg.Storage.Password,
-			"POSTGRES_DB":       cfg.Storage.DatabaseName,
+			"POSTGRES_USER":     settings.User,
+			"POSTGRES_PASSWORD": settings.Password,
+			"POSTGRES_DB":       settings.DatabaseName,
 		},
 		WaitingFor: wait.ForListeningPort(nat.Port(portWithTCP)),
 	}
@@ -72,7 +74,7 @@ func (p *PostgresTestcontainer) SetupTestPostgresContainer(ctx context.Context)
 		return nil, nil, err
 	}
 
-	dbPool, err := pgxpool.New(ctx, p.Config.GetPostgresConnectionString())
+	dbPool, err := pgxpool.New(ctx, p.ConnectionString())
 	if err != nil {
 		return nil, nil, err
 	}
@@ -91,7 +93,7 @@ func (p *PostgresTestcontainer) SetupTestPostgresContainer(ctx context.Context)
 }
 
 func (p *PostgresTestcontainer) Start(ctx context.Context) (int, error) {
-	port, err := p.Container.MappedPort(ctx, nat.Port(p.Config.Storage.Port))
+	port, err := p.Container.MappedPort(ctx, nat.Port(p.settings().Port))
 	if err != nil {
 		return 0, err
 	}
@@ -107,9 +109,18 @@ func (p *PostgresTestcontainer) Stop() error {
 }
 
 func (p *PostgresTestcontainer) Migrate(log *zap.Logger) error {
-	return migration.RunMigration(p.Config, log)
+	return migration.RunMigration(p.Config, p.ConnectionString(), log)
 }
 
 func (p *PostgresTestcontainer) SetMappedPort(mappedPort int) {
-	p.Config.Storage.Port = strconv.Itoa(mappedPort)
+	p.settings().Port = strconv.Itoa(mappedPort)
+}
+
+// ConnectionString returns the connection string of the container, once its port is mapped.
+func (p *PostgresTestcontainer) ConnectionString() string {
+	return p.settings().ConnectionString()
+}
+
+func (p *PostgresTestcontainer) settings() *repository.PostgresSettings {
+	return p.Config.Storage.DriverSettings.(*repository.PostgresSettings)
 }
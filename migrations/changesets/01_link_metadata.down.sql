@@ -0,0 +1,4 @@
+ALTER TABLE urls
+    DROP COLUMN IF EXISTS owner,
+    DROP COLUMN IF EXISTS expires_at,
+    DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,4 @@
+ALTER TABLE urls
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN expires_at TIMESTAMPTZ,
+    ADD COLUMN owner TEXT;
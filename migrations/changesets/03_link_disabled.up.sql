@@ -0,0 +1 @@
+ALTER TABLE urls ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,7 @@
+DROP INDEX idx_urls_url_hash;
+
+ALTER TABLE urls
+    DROP COLUMN duplicate_of,
+    DROP COLUMN url_hash;
+
+DROP FUNCTION urls_url_hash(TEXT);
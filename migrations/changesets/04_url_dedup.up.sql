@@ -0,0 +1,23 @@
+-- convert_to is only stable because it depends on the database encoding, which never changes for
+-- an existing database, so the hash can be declared immutable and used in a generated column.
+CREATE FUNCTION urls_url_hash(url TEXT) RETURNS BYTEA
+    LANGUAGE SQL IMMUTABLE STRICT PARALLEL SAFE
+    AS $$ SELECT sha256(convert_to(url, 'UTF8')) $$;
+
+-- Rows created by concurrent requests for the same url are merged into the oldest one. The others
+-- keep resolving, since their codes were handed out, but are no longer returned for the url.
+ALTER TABLE urls
+    ADD COLUMN url_hash BYTEA GENERATED ALWAYS AS (urls_url_hash(url)) STORED,
+    ADD COLUMN duplicate_of BIGINT REFERENCES urls (id) ON DELETE CASCADE;
+
+UPDATE urls
+SET duplicate_of = canonical.id
+FROM (
+    SELECT url_hash, min(id) AS id
+    FROM urls
+    GROUP BY url_hash
+    HAVING count(*) > 1
+) AS canonical
+WHERE urls.url_hash = canonical.url_hash AND urls.id <> canonical.id;
+
+CREATE UNIQUE INDEX idx_urls_url_hash ON urls (url_hash) WHERE duplicate_of IS NULL;
@@ -0,0 +1,5 @@
+-- Links created from leased blocks may be beyond the sequence.
+SELECT setval('urls_id_seq', next_id, false) FROM id_allocator;
+
+DROP TABLE id_returned_blocks;
+DROP TABLE id_allocator;
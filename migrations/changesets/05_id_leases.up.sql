@@ -0,0 +1,18 @@
+-- Ids are leased in blocks by the allocators of the running processes instead of being drawn from
+-- urls_id_seq one by one. The sequence is no longer advanced, but still backs the column default.
+CREATE TABLE id_allocator (
+    singleton BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (singleton),
+    next_id BIGINT NOT NULL
+);
+
+INSERT INTO id_allocator (next_id)
+SELECT GREATEST(
+    (SELECT COALESCE(max(id) + 1, 0) FROM urls),
+    (SELECT CASE WHEN is_called THEN last_value + 1 ELSE last_value END FROM urls_id_seq)
+);
+
+-- Blocks given back by allocators on shutdown, leased again before new ones.
+CREATE TABLE id_returned_blocks (
+    start_id BIGINT PRIMARY KEY,
+    end_id BIGINT NOT NULL CHECK (end_id > start_id)
+);
@@ -0,0 +1,2 @@
+-- Headers of the stored response other than Content-Type, e.g. Location, replayed with it.
+ALTER TABLE idempotency_keys ADD COLUMN headers JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,10 @@
+// Package migrations embeds the database changesets, so the binaries do not depend on files on disk.
+package migrations
+
+import "embed"
+
+// ChangesetsDir is the directory of Changesets holding the *.up.sql and *.down.sql files.
+const ChangesetsDir = "changesets"
+
+//go:embed changesets/*.sql
+var Changesets embed.FS
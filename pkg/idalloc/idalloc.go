@@ -0,0 +1,157 @@
+package idalloc
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+const DefaultBlockSize = 100
+
+// ErrExhausted is returned when every id below the limit has been leased.
+var ErrExhausted = errors.New("ids exhausted")
+
+// Block is the range of ids [Start, End).
+type Block struct {
+	Start uint64 `json:"start"`
+	End   uint64 `json:"end"`
+}
+
+func (b Block) Len() uint64 {
+	return b.End - b.Start
+}
+
+// Leaser keeps track of the blocks of ids leased by allocators, which may run in other processes.
+type Leaser interface {
+	// Lease returns a block of at most size ids below limit that no other lease contains, preferring
+	// the blocks given back by Return. It returns ErrExhausted when there is none left.
+	Lease(ctx context.Context, size, limit uint64) (Block, error)
+	// Return makes the blocks available to later leases.
+	Return(ctx context.Context, blocks []Block) error
+	// Advance makes new blocks start at next or later. Returned blocks below next are leased as is.
+	Advance(ctx context.Context, next uint64) error
+}
+
+// Allocator hands out ids from blocks leased from a Leaser, so most ids are allocated without a
+// round trip to the leaser. It is safe for concurrent use.
+type Allocator struct {
+	leaser    Leaser
+	limit     uint64
+	blockSize uint64
+
+	mu       sync.Mutex
+	block    Block
+	released []uint64
+}
+
+type Option func(a *Allocator)
+
+// WithBlockSize sets how many ids are leased at once, DefaultBlockSize by default. Larger blocks
+// make leases rarer, at the cost of more ids skipped when the process exits without Close.
+func WithBlockSize(size uint64) Option {
+	return func(a *Allocator) {
+		a.blockSize = max(size, 1)
+	}
+}
+
+// New returns an allocator of the ids below limit.
+func New(leaser Leaser, limit uint64, opts ...Option) *Allocator {
+	a := &Allocator{
+		leaser:    leaser,
+		limit:     limit,
+		blockSize: DefaultBlockSize,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// Next returns an id no other call to Next returns, unless it was given back by Release. Callers
+// wait for each other while a new block is leased.
+func (a *Allocator) Next(ctx context.Context) (uint64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if n := len(a.released); n > 0 {
+		id := a.released[n-1]
+		a.released = a.released[:n-1]
+
+		return id, nil
+	}
+
+	if a.block.Len() == 0 {
+		block, err := a.leaser.Lease(ctx, a.blockSize, a.limit)
+		if err != nil {
+			return 0, err
+		}
+
+		a.block = block
+	}
+
+	id := a.block.Start
+	a.block.Start++
+
+	return id, nil
+}
+
+// Release gives back an id returned by Next that was not used, so Next returns it again.
+func (a *Allocator) Release(id uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.released = append(a.released, id)
+}
+
+// Advance makes the ids below next, which were stored without the allocator, unlikely to be
+// handed out. Ids of the current block and of returned blocks still may be, so callers must handle
+// an id that is already taken by asking for another one.
+func (a *Allocator) Advance(ctx context.Context, next uint64) error {
+	return a.leaser.Advance(ctx, next)
+}
+
+// Close returns the ids that were leased but not handed out, so other allocators lease them. The
+// allocator leases a new block if it is used after Close.
+func (a *Allocator) Close(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	blocks := unusedBlocks(a.block, a.released)
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	if err := a.leaser.Return(ctx, blocks); err != nil {
+		return err
+	}
+
+	a.block, a.released = Block{}, nil
+
+	return nil
+}
+
+// unusedBlocks merges the released ids and the rest of the current block into blocks.
+func unusedBlocks(current Block, released []uint64) []Block {
+	ids := slices.Clone(released)
+	slices.Sort(ids)
+
+	var blocks []Block
+
+	for _, id := range slices.Compact(ids) {
+		if n := len(blocks); n > 0 && blocks[n-1].End == id {
+			blocks[n-1].End++
+			continue
+		}
+
+		blocks = append(blocks, Block{Start: id, End: id + 1})
+	}
+
+	if current.Len() > 0 {
+		blocks = append(blocks, current)
+	}
+
+	return blocks
+}
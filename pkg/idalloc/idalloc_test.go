@@ -0,0 +1,137 @@
+package idalloc_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/AFK068/compressor/pkg/idalloc"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Next_Concurrent_Success(t *testing.T) {
+	const goroutines, perGoroutine = 16, 100
+
+	ctx := context.Background()
+	allocator := idalloc.New(idalloc.NewMemoryLeaser(), goroutines*perGoroutine, idalloc.WithBlockSize(7))
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[uint64]bool)
+		wg   sync.WaitGroup
+	)
+
+	for range goroutines {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for range perGoroutine {
+				id, err := allocator.Next(ctx)
+				assert.NoError(t, err)
+
+				mu.Lock()
+				assert.False(t, seen[id], "id %d handed out twice", id)
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Len(t, seen, goroutines*perGoroutine)
+
+	_, err := allocator.Next(ctx)
+	assert.ErrorIs(t, err, idalloc.ErrExhausted)
+}
+
+func Test_Release_Success(t *testing.T) {
+	ctx := context.Background()
+	allocator := idalloc.New(idalloc.NewMemoryLeaser(), 10)
+
+	id, err := allocator.Next(ctx)
+	assert.NoError(t, err)
+
+	allocator.Release(id)
+
+	again, err := allocator.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, id, again)
+}
+
+func Test_Close_ReturnsUnusedIDs_Success(t *testing.T) {
+	ctx := context.Background()
+	leaser := idalloc.NewMemoryLeaser()
+
+	first := idalloc.New(leaser, 100, idalloc.WithBlockSize(10))
+
+	for range 3 {
+		_, err := first.Next(ctx)
+		assert.NoError(t, err)
+	}
+
+	first.Release(1)
+	assert.NoError(t, first.Close(ctx))
+
+	second := idalloc.New(leaser, 100, idalloc.WithBlockSize(10))
+
+	var ids []uint64
+
+	for range 9 {
+		id, err := second.Next(ctx)
+		assert.NoError(t, err)
+
+		ids = append(ids, id)
+	}
+
+	assert.Equal(t, []uint64{1, 3, 4, 5, 6, 7, 8, 9, 10}, ids)
+}
+
+func Test_Advance_Success(t *testing.T) {
+	ctx := context.Background()
+	allocator := idalloc.New(idalloc.NewMemoryLeaser(), 100)
+
+	assert.NoError(t, allocator.Advance(ctx, 42))
+
+	id, err := allocator.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(42), id)
+}
+
+func Test_FileLeaser_Restart_Success(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ids.json")
+
+	first := idalloc.New(idalloc.NewFileLeaser(path), 100, idalloc.WithBlockSize(10))
+
+	id, err := first.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), id)
+
+	// The process exits without Close, so the rest of its block is skipped.
+	second := idalloc.New(idalloc.NewFileLeaser(path), 100, idalloc.WithBlockSize(10))
+
+	id, err = second.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), id)
+
+	assert.NoError(t, second.Close(ctx))
+
+	third := idalloc.New(idalloc.NewFileLeaser(path), 100, idalloc.WithBlockSize(10))
+
+	id, err = third.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(11), id)
+}
+
+func Test_FileLeaser_Corrupted_Failure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ids.json")
+	assert.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+
+	_, err := idalloc.New(idalloc.NewFileLeaser(path), 100).Next(context.Background())
+	assert.ErrorContains(t, err, "parsing id leases")
+}
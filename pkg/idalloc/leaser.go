@@ -0,0 +1,174 @@
+package idalloc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// leases is the state of a MemoryLeaser or a FileLeaser.
+type leases struct {
+	Next     uint64  `json:"next"`
+	Returned []Block `json:"returned,omitempty"`
+}
+
+func (l *leases) lease(size, limit uint64) (Block, error) {
+	for i, returned := range l.Returned {
+		if returned.Start >= limit {
+			continue
+		}
+
+		block := Block{Start: returned.Start, End: returned.Start + min(size, returned.Len(), limit-returned.Start)}
+
+		if block.End == returned.End {
+			l.Returned = append(l.Returned[:i], l.Returned[i+1:]...)
+		} else {
+			l.Returned[i].Start = block.End
+		}
+
+		return block, nil
+	}
+
+	if l.Next >= limit {
+		return Block{}, ErrExhausted
+	}
+
+	block := Block{Start: l.Next, End: l.Next + min(size, limit-l.Next)}
+	l.Next = block.End
+
+	return block, nil
+}
+
+func (l *leases) advance(next uint64) {
+	l.Next = max(l.Next, next)
+}
+
+// MemoryLeaser leases blocks of ids to the allocators of a single process.
+type MemoryLeaser struct {
+	mu     sync.Mutex
+	leases leases
+}
+
+func NewMemoryLeaser() *MemoryLeaser {
+	return &MemoryLeaser{}
+}
+
+func (m *MemoryLeaser) Lease(_ context.Context, size, limit uint64) (Block, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.leases.lease(size, limit)
+}
+
+func (m *MemoryLeaser) Return(_ context.Context, blocks []Block) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.leases.Returned = append(m.leases.Returned, blocks...)
+
+	return nil
+}
+
+func (m *MemoryLeaser) Advance(_ context.Context, next uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.leases.advance(next)
+
+	return nil
+}
+
+// FileLeaser keeps the leases in a JSON file, so ids handed out before a restart are not handed out
+// again. The file is replaced atomically on every change. It must not be shared by processes
+// running at the same time.
+type FileLeaser struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileLeaser(path string) *FileLeaser {
+	return &FileLeaser{path: path}
+}
+
+func (f *FileLeaser) Lease(_ context.Context, size, limit uint64) (block Block, err error) {
+	err = f.update(func(l *leases) (err error) {
+		block, err = l.lease(size, limit)
+		return err
+	})
+
+	return block, err
+}
+
+func (f *FileLeaser) Return(_ context.Context, blocks []Block) error {
+	return f.update(func(l *leases) error {
+		l.Returned = append(l.Returned, blocks...)
+		return nil
+	})
+}
+
+func (f *FileLeaser) Advance(_ context.Context, next uint64) error {
+	return f.update(func(l *leases) error {
+		l.advance(next)
+		return nil
+	})
+}
+
+// update reads the leases, applies fn and writes them back unless fn fails. A missing file holds
+// no leases.
+func (f *FileLeaser) update(fn func(l *leases) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var l leases
+
+	data, err := os.ReadFile(f.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("reading id leases: %w", err)
+	default:
+		if err := json.Unmarshal(data, &l); err != nil {
+			return fmt.Errorf("parsing id leases %s: %w", f.path, err)
+		}
+	}
+
+	if err := fn(&l); err != nil {
+		return err
+	}
+
+	return f.write(&l)
+}
+
+func (f *FileLeaser) write(l *leases) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing id leases: %w", err)
+	}
+
+	defer os.Remove(tmp.Name()) //nolint // Fails once the file is renamed.
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if err = errors.Join(err, tmp.Close()); err != nil {
+		return fmt.Errorf("writing id leases: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("writing id leases: %w", err)
+	}
+
+	return nil
+}
@@ -8,7 +8,13 @@ import (
 )
 
 func New() *zap.Logger {
+	return NewWithLevel(zap.NewAtomicLevelAt(zap.InfoLevel))
+}
+
+// NewWithLevel creates a logger whose minimum level follows level, so it can be changed while running.
+func NewWithLevel(level zap.AtomicLevel) *zap.Logger {
 	zapConfig := zap.NewProductionConfig()
+	zapConfig.Level = level
 
 	// Setup config.
 	zapConfig.EncoderConfig.EncodeTime = zapcore.RFC3339TimeEncoder
@@ -9,4 +9,7 @@ var (
 	ErrInvalidStringLength   = errors.New("invalid string length")
 	ErrInvalidCharacter      = errors.New("invalid character in string")
 	ErrNumberOverflow        = errors.New("number overflow, too large to encode")
+	ErrDuplicateCharacter    = errors.New("alphabet must not contain duplicate characters")
+	ErrNonASCIIAlphabet      = errors.New("alphabet must only contain ASCII characters")
+	ErrCapacityOverflow      = errors.New("alphabet size to the power of length overflows uint64")
 )
@@ -1,6 +1,12 @@
 package shortener
 
-import "strings"
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+	"unicode/utf8"
+)
 
 type Shortener struct {
 	Alphabet string
@@ -8,13 +14,18 @@ type Shortener struct {
 	Length   uint64
 }
 
+// NewShortener returns every problem with alphabet and length at once, joined into one error.
 func NewShortener(alphabet string, length uint64) (*Shortener, error) {
+	errs := []error{ValidateAlphabet(alphabet)}
+
 	if length == 0 {
-		return nil, ErrInvalidLength
+		errs = append(errs, ErrInvalidLength)
+	} else if _, err := Capacity(uint64(len(alphabet)), length); err != nil {
+		errs = append(errs, err)
 	}
 
-	if alphabet == "" {
-		return nil, ErrInvalidLengthAlphabet
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
 
 	return &Shortener{
@@ -24,13 +35,71 @@ func NewShortener(alphabet string, length uint64) (*Shortener, error) {
 	}, nil
 }
 
-func (s *Shortener) Encode(num uint64) (string, error) {
-	maxValue := uint64(1)
-	for i := uint64(0); i < s.Length; i++ {
-		maxValue *= s.Base
+// ValidateAlphabet checks that alphabet is a non-empty set of ASCII characters, since codes are
+// encoded byte by byte and a repeated character would make two numbers share a code.
+func ValidateAlphabet(alphabet string) error {
+	if alphabet == "" {
+		return ErrInvalidLengthAlphabet
+	}
+
+	var (
+		errs       []error
+		duplicates []byte
+	)
+
+	seen := make(map[byte]struct{}, len(alphabet))
+
+	for i := 0; i < len(alphabet); i++ {
+		c := alphabet[i]
+
+		if c >= utf8.RuneSelf {
+			errs = append(errs, ErrNonASCIIAlphabet)
+			break
+		}
+
+		if _, ok := seen[c]; ok && !strings.ContainsRune(string(duplicates), rune(c)) {
+			duplicates = append(duplicates, c)
+		}
+
+		seen[c] = struct{}{}
+	}
+
+	if len(duplicates) > 0 {
+		errs = append(errs, fmt.Errorf("%w: %q", ErrDuplicateCharacter, duplicates))
 	}
 
-	if num >= maxValue {
+	return errors.Join(errs...)
+}
+
+// Capacity returns the number of distinct codes, base^length. It fails when that number does not
+// fit into uint64, because Encode and Decode could not represent every code.
+func Capacity(base, length uint64) (uint64, error) {
+	capacity := uint64(1)
+
+	for i := uint64(0); i < length; i++ {
+		hi, lo := bits.Mul64(capacity, base)
+		if hi != 0 {
+			return 0, fmt.Errorf("%w: %d^%d", ErrCapacityOverflow, base, length)
+		}
+
+		capacity = lo
+	}
+
+	return capacity, nil
+}
+
+// Capacity returns the number of distinct codes the shortener can produce.
+func (s *Shortener) Capacity() uint64 {
+	capacity, err := Capacity(s.Base, s.Length)
+	if err != nil {
+		return 0
+	}
+
+	return capacity
+}
+
+func (s *Shortener) Encode(num uint64) (string, error) {
+	if num >= s.Capacity() {
 		return "", ErrNumberOverflow
 	}
 
@@ -64,3 +64,27 @@ func Test_Decode_Success(t *testing.T) {
 
 	assert.Equal(t, uint64(4), result)
 }
+
+func Test_NewShortener_DuplicateCharacter_Failure(t *testing.T) {
+	_, err := shortener.NewShortener("abca", 5)
+	assert.ErrIs(t, err, shortener.ErrDuplicateCharacter)
+}
+
+func Test_NewShortener_NonASCIIAlphabet_Failure(t *testing.T) {
+	_, err := shortener.NewShortener("abcé", 5)
+	assert.ErrIs(t, err, shortener.ErrNonASCIIAlphabet)
+}
+
+func Test_NewShortener_CapacityOverflow_Failure(t *testing.T) {
+	_, err := shortener.NewShortener("ab", 64)
+	assert.ErrIs(t, err, shortener.ErrCapacityOverflow)
+
+	_, err = shortener.NewShortener("ab", 63)
+	assert.Nil(t, err)
+}
+
+func Test_Capacity_Success(t *testing.T) {
+	capacity, err := shortener.Capacity(3, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(243), capacity)
+}
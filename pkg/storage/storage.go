@@ -0,0 +1,104 @@
+// Package storage is the registry of the storage backends, in the style of database/sql: a driver
+// registers under the name used as storage.type, usually from the init function of its package,
+// and is built into a binary by importing that package for its side effects:
+//
+//	import _ "example.com/compressor-redis"
+//
+// The built-in drivers are registered by the internal/infrastructure/repository package.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/domain"
+	"go.uber.org/zap"
+)
+
+// Backend is implemented by every storage backend.
+type Backend interface {
+	Repository
+	IdempotencyStore
+	Transactor
+
+	// Close returns the ids leased by the backend but not used.
+	Close(ctx context.Context) error
+}
+
+// Options are passed to Driver.Open.
+type Options struct {
+	// Config is the configuration of the service. The settings of a driver that implements
+	// SettingsDriver are in Config.Storage.DriverSettings.
+	Config    *Config
+	Shortener Shortener
+	Logger    *zap.Logger
+}
+
+// Driver opens the backend of a storage type.
+type Driver interface {
+	// Open creates the backend. The returned close function returns the unused ids and releases the
+	// resources held by the backend.
+	Open(ctx context.Context, opts Options) (Backend, func(), error)
+}
+
+// SettingsDriver is implemented by drivers with settings of their own. They are read from the
+// storage.<name> section of the configuration file and from the environment, using the yaml, env
+// and env-default tags of the settings, and checked by their Validate method when they have one.
+// Settings holding secrets implement Redacted, see config.SettingsRedactor.
+type SettingsDriver interface {
+	Driver
+
+	// NewSettings returns a pointer to new settings, holding the defaults of the driver.
+	NewSettings() any
+}
+
+// DriverFunc is a Driver without settings of its own.
+type DriverFunc func(ctx context.Context, opts Options) (Backend, func(), error)
+
+func (f DriverFunc) Open(ctx context.Context, opts Options) (Backend, func(), error) {
+	return f(ctx, opts)
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]Driver{}
+)
+
+// Register makes driver available as the storage type name. Like sql.Register, it panics when
+// driver is nil or a driver is already registered under name.
+func Register(name string, driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if driver == nil {
+		panic("storage: Register driver is nil")
+	}
+
+	if _, ok := drivers[name]; ok {
+		panic("storage: Register called twice for driver " + name)
+	}
+
+	drivers[name] = driver
+
+	var newSettings func() any
+	if settingsDriver, ok := driver.(SettingsDriver); ok {
+		newSettings = settingsDriver.NewSettings
+	}
+
+	config.RegisterStorageType(domain.RepositoryType(name), newSettings)
+}
+
+// Open creates the backend of the driver registered as cfg.Storage.Type.
+func Open(ctx context.Context, cfg *Config, shortener Shortener, log *zap.Logger) (Backend, func(), error) {
+	driversMu.RLock()
+	driver, ok := drivers[string(cfg.Storage.Type)]
+	driversMu.RUnlock()
+
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown storage type %q, the driver may not be imported", cfg.Storage.Type)
+	}
+
+	return driver.Open(ctx, Options{Config: cfg, Shortener: shortener, Logger: log})
+}
@@ -0,0 +1,112 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/infrastructure/repository/inmemoryrepo"
+	"github.com/AFK068/compressor/pkg/shortener"
+	"github.com/AFK068/compressor/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const testConfig = `
+shortener:
+    alphabet: "abcdefgh"
+    length: 4
+storage:
+    type: "test"
+    max_size: 100
+    test:
+        capacity: 20
+`
+
+type testSettings struct {
+	Capacity uint64 `yaml:"capacity" env:"TEST_STORAGE_CAPACITY"`
+	Owner    string `yaml:"owner" env:"TEST_STORAGE_OWNER" env-default:"team-a"`
+}
+
+func (s *testSettings) Validate() error {
+	if s.Capacity == 0 {
+		return errors.New("capacity must be greater than 0")
+	}
+
+	return nil
+}
+
+// testDriver opens an inmemory repository of the configured capacity.
+type testDriver struct{}
+
+func (testDriver) NewSettings() any {
+	return &testSettings{}
+}
+
+func (testDriver) Open(_ context.Context, opts storage.Options) (storage.Backend, func(), error) {
+	settings := opts.Config.Storage.DriverSettings.(*testSettings)
+
+	return inmemoryrepo.New(opts.Shortener, settings.Capacity), func() {}, nil
+}
+
+func init() {
+	storage.Register("test", testDriver{})
+}
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func Test_Open_DriverSettings_Success(t *testing.T) {
+	t.Setenv("TEST_STORAGE_OWNER", "team-b")
+
+	cfg, err := config.NewConfig(writeConfig(t, testConfig))
+	assert.NoError(t, err)
+	assert.Equal(t, &testSettings{Capacity: 20, Owner: "team-b"}, cfg.Storage.DriverSettings)
+
+	s, err := shortener.NewShortener(cfg.Shortener.Alphabet, cfg.Shortener.Length)
+	assert.NoError(t, err)
+
+	backend, closeBackend, err := storage.Open(context.Background(), cfg, s, zap.NewNop())
+	assert.NoError(t, err)
+
+	defer closeBackend()
+
+	stats, err := backend.Stats(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(20), stats.Capacity)
+}
+
+func Test_NewConfig_InvalidDriverSettings_Failure(t *testing.T) {
+	t.Setenv("TEST_STORAGE_CAPACITY", "0")
+
+	_, err := config.NewConfig(writeConfig(t, testConfig))
+
+	var validationErr *config.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Fields, 1)
+	assert.Equal(t, "storage.test", validationErr.Fields[0].Path)
+}
+
+func Test_Open_UnknownType_Failure(t *testing.T) {
+	cfg := &config.Config{Storage: config.Storage{Type: "missing"}}
+
+	_, _, err := storage.Open(context.Background(), cfg, nil, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func Test_Register_Twice_Failure(t *testing.T) {
+	assert.Panics(t, func() {
+		storage.Register("test", testDriver{})
+	})
+
+	assert.Panics(t, func() {
+		storage.Register("other", nil)
+	})
+}
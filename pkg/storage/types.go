@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"github.com/AFK068/compressor/internal/config"
+	"github.com/AFK068/compressor/internal/domain"
+	"github.com/AFK068/compressor/internal/domain/apperrors"
+)
+
+// The types a driver implements and returns. They are aliases of the internal ones, so drivers
+// in other modules, which cannot import internal packages, can name them.
+type (
+	Config = config.Config
+
+	Repository       = domain.Repository
+	IdempotencyStore = domain.IdempotencyStore
+	Transactor       = domain.Transactor
+	Shortener        = domain.Shortener
+
+	Link              = domain.Link
+	ListLinksParams   = domain.ListLinksParams
+	Stats             = domain.Stats
+	IdempotencyRecord = domain.IdempotencyRecord
+)
+
+// The errors a backend returns, which the handlers map to responses, e.g. ErrURLNotFound to 404.
+type (
+	ErrURLNotFound               = apperrors.ErrURLNotFound
+	ErrLinkConflict              = apperrors.ErrLinkConflict
+	ErrRepositoryIsFull          = apperrors.ErrRepositoryIsFull
+	ErrIdempotencyRecordNotFound = apperrors.ErrIdempotencyRecordNotFound
+	ErrNotSupported              = apperrors.ErrNotSupported
+)
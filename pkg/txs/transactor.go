@@ -3,29 +3,105 @@ package txs
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const (
+	DefaultMaxAttempts = 3
+	DefaultRetryDelay  = 10 * time.Millisecond
+
+	// SQLSTATE codes of the failures a transaction can be retried after as a whole.
+	codeSerializationFailure = "40001"
+	codeDeadlockDetected     = "40P01"
+)
+
 type txKey struct{}
 
 func injectTx(ctx context.Context, tx pgx.Tx) context.Context {
 	return context.WithValue(ctx, txKey{}, tx)
 }
 
+// InTransaction reports whether ctx carries a transaction started by WithTransaction.
+func InTransaction(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return ok
+}
+
 type TxBeginner struct {
-	db *pgxpool.Pool
+	db          *pgxpool.Pool
+	txOptions   pgx.TxOptions
+	maxAttempts int
+	retryDelay  time.Duration
 }
 
-func NewTxBeginner(db *pgxpool.Pool) *TxBeginner {
-	return &TxBeginner{
-		db: db,
+type Option func(t *TxBeginner)
+
+// WithTxOptions sets the isolation level and access mode of the transactions, read committed by default.
+func WithTxOptions(txOptions pgx.TxOptions) Option {
+	return func(t *TxBeginner) {
+		t.txOptions = txOptions
 	}
 }
 
-func (t *TxBeginner) WithTransaction(ctx context.Context, txFunc func(ctx context.Context) error) (err error) {
-	tx, err := t.db.Begin(ctx)
+// WithRetry sets how many times a transaction is run when it fails with a serialization failure
+// or a deadlock, and the delay before the first retry, which doubles with every retry.
+func WithRetry(maxAttempts int, delay time.Duration) Option {
+	return func(t *TxBeginner) {
+		t.maxAttempts = max(maxAttempts, 1)
+		t.retryDelay = delay
+	}
+}
+
+func NewTxBeginner(db *pgxpool.Pool, opts ...Option) *TxBeginner {
+	t := &TxBeginner{
+		db:          db,
+		maxAttempts: DefaultMaxAttempts,
+		retryDelay:  DefaultRetryDelay,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// WithTransaction runs txFunc in a transaction that is committed when txFunc returns nil and rolled
+// back otherwise. Queries made through GetQuerier with the context passed to txFunc join the
+// transaction. When ctx already carries a transaction, txFunc joins it instead of starting a new
+// one, and the outermost WithTransaction commits or rolls back.
+//
+// A transaction failing with a serialization failure or a deadlock is run again from the start, so
+// txFunc must not have side effects outside the database.
+func (t *TxBeginner) WithTransaction(ctx context.Context, txFunc func(ctx context.Context) error) error {
+	if InTransaction(ctx) {
+		return txFunc(ctx)
+	}
+
+	delay := t.retryDelay
+
+	for attempt := 1; ; attempt++ {
+		err := t.runTransaction(ctx, txFunc)
+		if err == nil || attempt >= t.maxAttempts || !IsRetryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+	}
+}
+
+func (t *TxBeginner) runTransaction(ctx context.Context, txFunc func(ctx context.Context) error) (err error) {
+	tx, err := t.db.BeginTx(ctx, t.txOptions)
 	if err != nil {
 		return err
 	}
@@ -43,3 +119,14 @@ func (t *TxBeginner) WithTransaction(ctx context.Context, txFunc func(ctx contex
 
 	return tx.Commit(ctx)
 }
+
+// IsRetryable reports whether err is a serialization failure or a deadlock, after which the whole
+// transaction can be run again.
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	return pgErr.Code == codeSerializationFailure || pgErr.Code == codeDeadlockDetected
+}
@@ -0,0 +1,28 @@
+package txs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/AFK068/compressor/pkg/txs"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_IsRetryable_Success(t *testing.T) {
+	assert.True(t, txs.IsRetryable(&pgconn.PgError{Code: "40001"}))
+	assert.True(t, txs.IsRetryable(fmt.Errorf("creating link: %w", &pgconn.PgError{Code: "40P01"})))
+	assert.True(t, txs.IsRetryable(errors.Join(&pgconn.PgError{Code: "40001"}, errors.New("rollback failed"))))
+}
+
+func Test_IsRetryable_Failure(t *testing.T) {
+	assert.False(t, txs.IsRetryable(&pgconn.PgError{Code: "23505"}))
+	assert.False(t, txs.IsRetryable(context.Canceled))
+	assert.False(t, txs.IsRetryable(nil))
+}
+
+func Test_InTransaction_Failure(t *testing.T) {
+	assert.False(t, txs.InTransaction(context.Background()))
+}